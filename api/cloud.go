@@ -0,0 +1,210 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/dihedron/slumberd/pointer"
+	"github.com/dihedron/slumberd/timex"
+)
+
+// Cloud actions taken by a server on itself, from the one releasing the most
+// resources (and taking longest to resume from) to the one releasing the
+// least. A server cannot shelve and offload itself, since shelving powers it
+// off before it can ask for the offload.
+const (
+	// ActionShelve shelves the server, which the cloud then offloads from its
+	// hypervisor after its shelved_offload_time.
+	ActionShelve = "shelve"
+	// ActionStop stops the server, which keeps it on its hypervisor.
+	ActionStop = "stop"
+	// ActionSuspend saves the memory of the server to the disk of its
+	// hypervisor.
+	ActionSuspend = "suspend"
+	// ActionPause keeps the server in the memory of its hypervisor.
+	ActionPause = "pause"
+)
+
+// Actions are the cloud actions, cheapest first.
+var Actions = []string{ActionShelve, ActionStop, ActionSuspend, ActionPause}
+
+// DefaultResumeTimes are the typical times it takes for a server to be back
+// after each action.
+var DefaultResumeTimes = map[string]timex.Duration{
+	ActionShelve:  timex.Duration(5 * time.Minute),
+	ActionStop:    timex.Duration(2 * time.Minute),
+	ActionSuspend: timex.Duration(30 * time.Second),
+	ActionPause:   timex.Duration(5 * time.Second),
+}
+
+// DefaultCloudTimeout is how long the cloud has to complete the action.
+const DefaultCloudTimeout = 10 * time.Minute
+
+// Cloud configures how the daemon reaches the OpenStack API to act on the
+// server it runs on, and which action it takes.
+type Cloud struct {
+	// Name is the cloud in clouds.yaml (with its secrets in secure.yaml) to
+	// authenticate with; $OS_CLOUD if empty.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// CloudsFile is the path of clouds.yaml; if empty, it is looked for in
+	// $OS_CLIENT_CONFIG_FILE, the current directory, ~/.config/openstack and
+	// /etc/openstack.
+	CloudsFile string `json:"clouds_file,omitempty" yaml:"clouds_file,omitempty"`
+	// Credentials is the path of an openrc file with the OS_* variables,
+	// used if no cloud is named; the environment is used if empty.
+	Credentials string `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	// Region overrides the region of the compute endpoint.
+	Region string `json:"region,omitempty" yaml:"region,omitempty"`
+	// Interface overrides the interface of the compute endpoint: public,
+	// internal or admin.
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty"`
+	// Endpoint is the URL of the compute API, used instead of the one in
+	// the service catalog.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// CACert is the path of a PEM bundle of the CAs of a private cloud.
+	CACert string `json:"cacert,omitempty" yaml:"cacert,omitempty"`
+	// Insecure disables the verification of the cloud's certificates.
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+	// Action is what the server does to itself, one of Actions; if empty,
+	// the cheapest action meeting the ResumeSLA, or stop if there is none.
+	Action string `json:"action,omitempty" yaml:"action,omitempty"`
+	// ResumeSLA is the longest time users may wait for the server to be back.
+	ResumeSLA *timex.Duration `json:"resume_sla,omitempty" yaml:"resume_sla,omitempty"`
+	// ResumeTimes override the DefaultResumeTimes of some actions, as
+	// measured on the cloud.
+	ResumeTimes map[string]timex.Duration `json:"resume_times,omitempty" yaml:"resume_times,omitempty"`
+	// Timeout bounds the time the cloud has to complete the action.
+	Timeout *timex.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Validate checks the settings and fills in the defaults, choosing the
+// action if it is not set.
+func (c *Cloud) Validate() error {
+	for action := range c.ResumeTimes {
+		if !slices.Contains(Actions, action) {
+			return fmt.Errorf("invalid cloud action %q in resume times", action)
+		}
+	}
+	switch {
+	case c.Action == "shelve-offload":
+		return fmt.Errorf("invalid cloud action %q: a server cannot offload itself, use shelve and the cloud offloads it after its shelved_offload_time", c.Action)
+	case c.Action != "" && !slices.Contains(Actions, c.Action):
+		return fmt.Errorf("invalid cloud action %q: must be one of shelve, stop, suspend or pause", c.Action)
+	case c.Action == "" && c.ResumeSLA != nil:
+		action, err := Cheapest(time.Duration(*c.ResumeSLA), c.resumeTimes())
+		if err != nil {
+			return err
+		}
+		c.Action = action
+	case c.Action == "":
+		c.Action = ActionStop
+	}
+	if c.Timeout == nil || *c.Timeout <= 0 {
+		c.Timeout = pointer.To(timex.Duration(DefaultCloudTimeout))
+	}
+	if c.Credentials != "" {
+		if _, err := os.Stat(c.Credentials); err != nil {
+			return fmt.Errorf("invalid credentials file: %w", err)
+		}
+	}
+	if c.CloudsFile != "" {
+		if _, err := os.Stat(c.CloudsFile); err != nil {
+			return fmt.Errorf("invalid clouds file: %w", err)
+		}
+	}
+	if c.CACert != "" {
+		if _, err := os.Stat(c.CACert); err != nil {
+			return fmt.Errorf("invalid CA bundle: %w", err)
+		}
+	}
+	if c.Interface != "" && !slices.Contains(interfaces, c.Interface) {
+		return fmt.Errorf("invalid cloud interface %q: must be one of public, internal or admin", c.Interface)
+	}
+	if c.Endpoint != "" {
+		if u, err := url.Parse(c.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid cloud endpoint %q", c.Endpoint)
+		}
+	}
+	return nil
+}
+
+// interfaces are the interfaces of the endpoints in the service catalog.
+var interfaces = []string{"public", "internal", "admin"}
+
+// resumeTimes returns the resume times, with the overrides applied.
+func (c *Cloud) resumeTimes() map[string]time.Duration {
+	times := map[string]time.Duration{}
+	for action, d := range DefaultResumeTimes {
+		times[action] = time.Duration(d)
+	}
+	for action, d := range c.ResumeTimes {
+		times[action] = time.Duration(d)
+	}
+	return times
+}
+
+// Cheapest returns the action releasing the most resources whose resume
+// time meets the SLA.
+func Cheapest(sla time.Duration, resumeTimes map[string]time.Duration) (string, error) {
+	for _, action := range Actions {
+		if d, ok := resumeTimes[action]; ok && d <= sla {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("no cloud action resumes within %s", sla)
+}
+
+// Client returns a client authenticated with the named cloud in clouds.yaml,
+// or else with the OS_* variables in the credentials file or in the
+// environment, reaching the compute endpoint as configured.
+func (c *Cloud) Client() (*OpenStackClient, error) {
+	lookup := os.Getenv
+	name := c.Name
+	if name == "" && c.Credentials == "" {
+		name = os.Getenv("OS_CLOUD")
+	}
+	switch {
+	case name != "":
+		vars, err := LoadCloud(name, c.CloudsFile)
+		if err != nil {
+			return nil, err
+		}
+		lookup = lookupIn(vars)
+	case c.Credentials != "":
+		vars, err := ReadCredentials(c.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		lookup = lookupIn(vars)
+	}
+	opts, err := authOptions(lookup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth options: %w", err)
+	}
+	return newOpenStackClient(opts, c.connection(lookup))
+}
+
+// connection returns the connection settings in the variables, with the
+// configured overrides.
+func (c *Cloud) connection(lookup func(string) string) connection {
+	conn := connectionFrom(lookup)
+	if c.Region != "" {
+		conn.region = c.Region
+	}
+	if c.Interface != "" {
+		conn.availability = c.Interface
+	}
+	if c.Endpoint != "" {
+		conn.endpoint = c.Endpoint
+	}
+	if c.CACert != "" {
+		conn.cacert = c.CACert
+	}
+	if c.Insecure {
+		conn.insecure = true
+	}
+	return conn
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/pointer"
+	"github.com/dihedron/slumberd/timex"
+)
+
+func TestCloudAction(t *testing.T) {
+	tests := []struct {
+		name          string
+		cloud         Cloud
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "default",
+			expected: ActionStop,
+		},
+		{
+			name:     "explicit",
+			cloud:    Cloud{Action: ActionShelve, ResumeSLA: pointer.To(timex.Duration(time.Second))},
+			expected: ActionShelve,
+		},
+		{
+			name:     "generous SLA",
+			cloud:    Cloud{ResumeSLA: pointer.To(timex.Duration(time.Hour))},
+			expected: ActionShelve,
+		},
+		{
+			name:     "morning SLA",
+			cloud:    Cloud{ResumeSLA: pointer.To(timex.Duration(3 * time.Minute))},
+			expected: ActionStop,
+		},
+		{
+			name: "measured resume times",
+			cloud: Cloud{
+				ResumeSLA:   pointer.To(timex.Duration(3 * time.Minute)),
+				ResumeTimes: map[string]timex.Duration{ActionShelve: timex.Duration(150 * time.Second)},
+			},
+			expected: ActionShelve,
+		},
+		{
+			name:          "impossible SLA",
+			cloud:         Cloud{ResumeSLA: pointer.To(timex.Duration(time.Second))},
+			expectedError: "no cloud action resumes within 1s",
+		},
+		{
+			name:          "invalid action",
+			cloud:         Cloud{Action: "hibernate"},
+			expectedError: `invalid cloud action "hibernate": must be one of shelve, stop, suspend or pause`,
+		},
+		{
+			name:          "shelve-offload",
+			cloud:         Cloud{Action: "shelve-offload"},
+			expectedError: `invalid cloud action "shelve-offload": a server cannot offload itself, use shelve and the cloud offloads it after its shelved_offload_time`,
+		},
+		{
+			name:          "invalid interface",
+			cloud:         Cloud{Interface: "private"},
+			expectedError: `invalid cloud interface "private": must be one of public, internal or admin`,
+		},
+		{
+			name:          "invalid endpoint",
+			cloud:         Cloud{Endpoint: "nova.example.com"},
+			expectedError: `invalid cloud endpoint "nova.example.com"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cloud.Validate()
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("expected error %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.cloud.Action != tt.expected {
+				t.Errorf("expected action %q, got %q", tt.expected, tt.cloud.Action)
+			}
+			if tt.cloud.Timeout == nil || time.Duration(*tt.cloud.Timeout) != DefaultCloudTimeout {
+				t.Errorf("expected default timeout, got %v", tt.cloud.Timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// cloudsEntry is a cloud in clouds.yaml.
+type cloudsEntry struct {
+	Auth struct {
+		AuthURL                     string `yaml:"auth_url"`
+		Username                    string `yaml:"username"`
+		UserID                      string `yaml:"user_id"`
+		Password                    string `yaml:"password"`
+		ProjectID                   string `yaml:"project_id"`
+		ProjectName                 string `yaml:"project_name"`
+		UserDomainID                string `yaml:"user_domain_id"`
+		UserDomainName              string `yaml:"user_domain_name"`
+		DomainID                    string `yaml:"domain_id"`
+		DomainName                  string `yaml:"domain_name"`
+		ApplicationCredentialID     string `yaml:"application_credential_id"`
+		ApplicationCredentialName   string `yaml:"application_credential_name"`
+		ApplicationCredentialSecret string `yaml:"application_credential_secret"`
+	} `yaml:"auth"`
+	RegionName string `yaml:"region_name"`
+	Interface  string `yaml:"interface"`
+	CACert     string `yaml:"cacert"`
+	Verify     *bool  `yaml:"verify"`
+}
+
+// variables returns the settings of the cloud as the equivalent OS_*
+// variables, leaving out the unset ones.
+func (e *cloudsEntry) variables() map[string]string {
+	vars := map[string]string{
+		"OS_AUTH_URL":                      e.Auth.AuthURL,
+		"OS_USERNAME":                      e.Auth.Username,
+		"OS_USERID":                        e.Auth.UserID,
+		"OS_PASSWORD":                      e.Auth.Password,
+		"OS_PROJECT_ID":                    e.Auth.ProjectID,
+		"OS_PROJECT_NAME":                  e.Auth.ProjectName,
+		"OS_USER_DOMAIN_ID":                e.Auth.UserDomainID,
+		"OS_USER_DOMAIN_NAME":              e.Auth.UserDomainName,
+		"OS_DOMAIN_ID":                     e.Auth.DomainID,
+		"OS_DOMAIN_NAME":                   e.Auth.DomainName,
+		"OS_APPLICATION_CREDENTIAL_ID":     e.Auth.ApplicationCredentialID,
+		"OS_APPLICATION_CREDENTIAL_NAME":   e.Auth.ApplicationCredentialName,
+		"OS_APPLICATION_CREDENTIAL_SECRET": e.Auth.ApplicationCredentialSecret,
+		"OS_REGION_NAME":                   e.RegionName,
+		"OS_INTERFACE":                     e.Interface,
+		"OS_CACERT":                        e.CACert,
+	}
+	if e.Verify != nil {
+		vars["OS_INSECURE"] = strconv.FormatBool(!*e.Verify)
+	}
+	for name, value := range vars {
+		if value == "" {
+			delete(vars, name)
+		}
+	}
+	return vars
+}
+
+// cloudsDirs are the directories searched for clouds.yaml and secure.yaml,
+// in order.
+func cloudsDirs() []string {
+	dirs := []string{"."}
+	if config, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(config, "openstack"))
+	}
+	return append(dirs, "/etc/openstack")
+}
+
+// findFile returns the path of the first file with the given name in the
+// search directories, or an empty path if there is none.
+func findFile(name string) string {
+	for _, dir := range cloudsDirs() {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// LoadCloud returns the OS_* variables equivalent to the settings of the
+// named cloud in clouds.yaml, merged with those in secure.yaml (which holds
+// the secrets and takes precedence). The files are those at the given path
+// and next to it, or else the ones in $OS_CLIENT_CONFIG_FILE and
+// $OS_CLIENT_SECURE_FILE, or in the current directory, the user's
+// ~/.config/openstack or /etc/openstack.
+func LoadCloud(name string, path string) (map[string]string, error) {
+	secure := ""
+	switch {
+	case path != "":
+		secure = filepath.Join(filepath.Dir(path), "secure.yaml")
+	case os.Getenv("OS_CLIENT_CONFIG_FILE") != "":
+		path = os.Getenv("OS_CLIENT_CONFIG_FILE")
+	default:
+		path = findFile("clouds.yaml")
+	}
+	if secure == "" {
+		if secure = os.Getenv("OS_CLIENT_SECURE_FILE"); secure == "" {
+			secure = findFile("secure.yaml")
+		}
+	}
+	if path == "" {
+		return nil, errors.New("no clouds.yaml found")
+	}
+
+	clouds, err := readClouds(path)
+	if err != nil {
+		return nil, err
+	}
+	cloud, ok := clouds[name]
+	if !ok {
+		return nil, fmt.Errorf("no cloud %q in %s", name, path)
+	}
+	if secure != "" {
+		secrets, err := readClouds(secure)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if s, ok := secrets[name].(map[string]any); ok {
+			merge(cloud.(map[string]any), s)
+		}
+	}
+
+	// go through YAML again to decode the merged settings
+	data, err := yaml.Marshal(cloud)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud %q: %w", name, err)
+	}
+	var entry cloudsEntry
+	if err := yaml.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid cloud %q in %s: %w", name, path, err)
+	}
+	return entry.variables(), nil
+}
+
+// readClouds returns the clouds in a clouds.yaml or secure.yaml file.
+func readClouds(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clouds file: %w", err)
+	}
+	var file struct {
+		Clouds map[string]any `yaml:"clouds"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid clouds file %s: %w", path, err)
+	}
+	for name, cloud := range file.Clouds {
+		if _, ok := cloud.(map[string]any); !ok {
+			return nil, fmt.Errorf("invalid cloud %q in %s", name, path)
+		}
+	}
+	return file.Clouds, nil
+}
+
+// merge merges the values of src into dst, recursively.
+func merge(dst, src map[string]any) {
+	for key, value := range src {
+		if s, ok := value.(map[string]any); ok {
+			if d, ok := dst[key].(map[string]any); ok {
+				merge(d, s)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
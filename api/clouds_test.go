@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const cloudsYAML = `clouds:
+  private:
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      username: developer
+      project_name: dev-vms
+      user_domain_name: Default
+    region_name: RegionTwo
+    interface: internal
+    cacert: /etc/ssl/private-cloud.pem
+  appcred:
+    auth_type: v3applicationcredential
+    auth:
+      auth_url: https://keystone.example.com:5000/v3
+      application_credential_id: 21dced0f
+    verify: false
+`
+
+const secureYAML = `clouds:
+  private:
+    auth:
+      password: secret
+  appcred:
+    auth:
+      application_credential_secret: s3cr3t
+`
+
+func TestLoadCloud(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clouds.yaml")
+	if err := os.WriteFile(path, []byte(cloudsYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secure.yaml"), []byte(secureYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		expected      map[string]string
+		expectedError string
+	}{
+		{
+			name: "private",
+			expected: map[string]string{
+				"OS_AUTH_URL":         "https://keystone.example.com:5000/v3",
+				"OS_USERNAME":         "developer",
+				"OS_PASSWORD":         "secret",
+				"OS_PROJECT_NAME":     "dev-vms",
+				"OS_USER_DOMAIN_NAME": "Default",
+				"OS_REGION_NAME":      "RegionTwo",
+				"OS_INTERFACE":        "internal",
+				"OS_CACERT":           "/etc/ssl/private-cloud.pem",
+			},
+		},
+		{
+			name: "appcred",
+			expected: map[string]string{
+				"OS_AUTH_URL":                      "https://keystone.example.com:5000/v3",
+				"OS_APPLICATION_CREDENTIAL_ID":     "21dced0f",
+				"OS_APPLICATION_CREDENTIAL_SECRET": "s3cr3t",
+				"OS_INSECURE":                      "true",
+			},
+		},
+		{
+			name:          "missing",
+			expectedError: `no cloud "missing" in ` + path,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars, err := LoadCloud(tt.name, path)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("expected error %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(vars) != len(tt.expected) {
+				t.Errorf("expected %d variables, got %v", len(tt.expected), vars)
+			}
+			for name, value := range tt.expected {
+				if vars[name] != value {
+					t.Errorf("expected %s=%q, got %q", name, value, vars[name])
+				}
+			}
+			lookup := func(name string) string { return vars[name] }
+			if _, err := authOptions(lookup); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCloudConnection(t *testing.T) {
+	vars := map[string]string{
+		"OS_REGION_NAME": "RegionTwo",
+		"OS_INTERFACE":   "publicURL",
+		"OS_INSECURE":    "false",
+	}
+	lookup := func(name string) string { return vars[name] }
+
+	conn := (&Cloud{}).connection(lookup)
+	if conn != (connection{region: "RegionTwo", availability: "public"}) {
+		t.Errorf("unexpected connection: %+v", conn)
+	}
+	c := &Cloud{Region: "RegionThree", Interface: "internal", Endpoint: "https://nova.example.com/v2.1", Insecure: true}
+	conn = c.connection(lookup)
+	expected := connection{region: "RegionThree", availability: "internal", endpoint: "https://nova.example.com/v2.1", insecure: true}
+	if conn != expected {
+		t.Errorf("expected %+v, got %+v", expected, conn)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	cacert := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(cacert, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(conn connection) error {
+		config, err := conn.tlsConfig()
+		if err != nil {
+			return err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := get(connection{}); err == nil {
+		t.Error("expected the certificate not to be trusted")
+	}
+	if err := get(connection{cacert: cacert}); err != nil {
+		t.Errorf("unexpected error with CA bundle: %v", err)
+	}
+	if err := get(connection{insecure: true}); err != nil {
+		t.Errorf("unexpected error when insecure: %v", err)
+	}
+	if _, err := (connection{cacert: srv.URL}).tlsConfig(); err == nil {
+		t.Error("expected error for missing CA bundle")
+	}
+}
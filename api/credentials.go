@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// ReadCredentials reads the OS_* variables from an openrc file, as
+// downloaded from the OpenStack dashboard: lines are "NAME=value", optionally
+// preceded by "export" and with quoted values; comments, blank lines, other
+// shell commands and values expanding shell variables are ignored.
+func ReadCredentials(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open credentials file: %w", err)
+	}
+	defer file.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || !strings.HasPrefix(name, "OS_") {
+			continue
+		}
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if strings.HasPrefix(value, "$") {
+			// e.g. the password read at the prompt
+			continue
+		}
+		vars[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	return vars, nil
+}
+
+// authOptions returns the authentication options in the OS_* variables
+// returned by lookup, like openstack.AuthOptionsFromEnv does for the
+// environment.
+func authOptions(lookup func(string) string) (gophercloud.AuthOptions, error) {
+	opts := gophercloud.AuthOptions{
+		IdentityEndpoint:            lookup("OS_AUTH_URL"),
+		UserID:                      lookup("OS_USERID"),
+		Username:                    lookup("OS_USERNAME"),
+		Password:                    lookup("OS_PASSWORD"),
+		TenantID:                    lookup("OS_TENANT_ID"),
+		TenantName:                  lookup("OS_TENANT_NAME"),
+		DomainID:                    lookup("OS_DOMAIN_ID"),
+		DomainName:                  lookup("OS_DOMAIN_NAME"),
+		ApplicationCredentialID:     lookup("OS_APPLICATION_CREDENTIAL_ID"),
+		ApplicationCredentialName:   lookup("OS_APPLICATION_CREDENTIAL_NAME"),
+		ApplicationCredentialSecret: lookup("OS_APPLICATION_CREDENTIAL_SECRET"),
+	}
+	if v := lookup("OS_PROJECT_ID"); v != "" {
+		opts.TenantID = v
+	}
+	if v := lookup("OS_PROJECT_NAME"); v != "" {
+		opts.TenantName = v
+	}
+	// openrc files of Keystone v3 name the domain of the user
+	if opts.DomainID == "" && opts.DomainName == "" {
+		opts.DomainID = lookup("OS_USER_DOMAIN_ID")
+		opts.DomainName = lookup("OS_USER_DOMAIN_NAME")
+	}
+
+	switch {
+	case opts.IdentityEndpoint == "":
+		return opts, fmt.Errorf("missing OS_AUTH_URL")
+	case opts.ApplicationCredentialID != "" || opts.ApplicationCredentialName != "":
+		if opts.ApplicationCredentialSecret == "" {
+			return opts, fmt.Errorf("missing OS_APPLICATION_CREDENTIAL_SECRET")
+		}
+		if opts.ApplicationCredentialID == "" && opts.UserID == "" && opts.Username == "" {
+			return opts, fmt.Errorf("missing OS_USERID or OS_USERNAME")
+		}
+	case opts.UserID == "" && opts.Username == "":
+		return opts, fmt.Errorf("missing OS_USERID or OS_USERNAME")
+	case opts.Password == "":
+		return opts, fmt.Errorf("missing OS_PASSWORD")
+	}
+	return opts, nil
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const openrc = `#!/usr/bin/env bash
+# To use an OpenStack cloud you need to authenticate against the Identity
+# service named keystone.
+export OS_AUTH_URL=https://keystone.example.com:5000/v3
+export OS_PROJECT_ID=0123456789abcdef
+export OS_PROJECT_NAME="dev-vms"
+export OS_USER_DOMAIN_NAME='Default'
+unset OS_TENANT_ID
+export OS_USERNAME="developer"
+echo "Please enter your OpenStack Password for project $OS_PROJECT_NAME as user $OS_USERNAME: "
+read -sr OS_PASSWORD_INPUT
+export OS_PASSWORD=$OS_PASSWORD_INPUT
+export OS_REGION_NAME="RegionTwo"
+`
+
+func TestCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openrc.sh")
+	if err := os.WriteFile(path, []byte(openrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := ReadCredentials(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"OS_AUTH_URL":         "https://keystone.example.com:5000/v3",
+		"OS_PROJECT_ID":       "0123456789abcdef",
+		"OS_PROJECT_NAME":     "dev-vms",
+		"OS_USER_DOMAIN_NAME": "Default",
+		"OS_USERNAME":         "developer",
+		"OS_REGION_NAME":      "RegionTwo",
+	}
+	if len(vars) != len(expected) {
+		t.Errorf("expected %d variables, got %v", len(expected), vars)
+	}
+	for name, value := range expected {
+		if vars[name] != value {
+			t.Errorf("expected %s=%q, got %q", name, value, vars[name])
+		}
+	}
+
+	// the password is not in the file
+	lookup := func(name string) string { return vars[name] }
+	if _, err := authOptions(lookup); err == nil || err.Error() != "missing OS_PASSWORD" {
+		t.Errorf("expected missing password, got %v", err)
+	}
+	vars["OS_PASSWORD"] = "secret"
+	opts, err := authOptions(lookup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.TenantID != "0123456789abcdef" || opts.DomainName != "Default" || opts.Username != "developer" {
+		t.Errorf("unexpected auth options %+v", opts)
+	}
+}
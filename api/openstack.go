@@ -2,14 +2,23 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/pauseunpause"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/shelveunshelve"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/startstop"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/suspendresume"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 )
 
@@ -17,33 +26,172 @@ type OpenStackClient struct {
 	client *gophercloud.ServiceClient
 	mu     sync.RWMutex
 	cache  map[string]string // userID -> serverID
+	// poll is the initial interval between status polls.
+	poll time.Duration
 }
 
+// NewOpenStackClient returns a client authenticated with the OS_* variables
+// in the environment.
 func NewOpenStackClient() (*OpenStackClient, error) {
 	opts, err := openstack.AuthOptionsFromEnv()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get auth options: %w", err)
 	}
+	return newOpenStackClient(opts, connectionFrom(os.Getenv))
+}
 
-	provider, err := openstack.AuthenticatedClient(opts)
+// NewOpenStackClientFromFile returns a client authenticated with the OS_*
+// variables in an openrc file, so that the daemon does not depend on its
+// environment; variables missing from the file are read from the environment.
+func NewOpenStackClientFromFile(path string) (*OpenStackClient, error) {
+	vars, err := ReadCredentials(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to authenticate: %w", err)
+		return nil, err
+	}
+	lookup := lookupIn(vars)
+	opts, err := authOptions(lookup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth options from %s: %w", path, err)
+	}
+	return newOpenStackClient(opts, connectionFrom(lookup))
+}
+
+// connection holds how the compute endpoint is found and reached.
+type connection struct {
+	// region is the region of the endpoint; any region if empty.
+	region string
+	// availability is the interface of the endpoint: public, internal or
+	// admin; public if empty.
+	availability string
+	// endpoint, if set, is used instead of the one in the catalog.
+	endpoint string
+	// cacert is the path of a PEM bundle of CAs trusted besides the system
+	// ones.
+	cacert string
+	// insecure disables the verification of server certificates.
+	insecure bool
+}
+
+// connectionFrom returns the connection settings in the OS_REGION_NAME,
+// OS_INTERFACE, OS_CACERT and OS_INSECURE variables returned by lookup.
+func connectionFrom(lookup func(string) string) connection {
+	insecure, _ := strconv.ParseBool(lookup("OS_INSECURE"))
+	return connection{
+		region:       lookup("OS_REGION_NAME"),
+		availability: strings.TrimSuffix(lookup("OS_INTERFACE"), "URL"),
+		cacert:       lookup("OS_CACERT"),
+		insecure:     insecure,
 	}
+}
 
-	client, err := openstack.NewComputeV2(provider, gophercloud.EndpointOpts{
-		Region: "RegionOne", // Should probably be configurable
-	})
+// lookupIn returns a lookup of the variables, falling back to the environment.
+func lookupIn(vars map[string]string) func(string) string {
+	return func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	}
+}
+
+// tlsConfig returns the TLS configuration trusting the CA bundle, or nil if
+// the defaults do.
+func (c connection) tlsConfig() (*tls.Config, error) {
+	if c.cacert == "" && !c.insecure {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: c.insecure}
+	if c.cacert != "" {
+		pem, err := os.ReadFile(c.cacert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA bundle %s", c.cacert)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func newOpenStackClient(opts gophercloud.AuthOptions, conn connection) (*OpenStackClient, error) {
+	provider, err := openstack.NewClient(opts.IdentityEndpoint)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create compute client: %w", err)
+		return nil, fmt.Errorf("failed to create provider client: %w", err)
+	}
+	config, err := conn.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		provider.HTTPClient = http.Client{Transport: transport}
+	}
+	// the daemon runs for long, past the expiry of its token
+	opts.AllowReauth = true
+	if err := openstack.Authenticate(provider, opts); err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
 	}
 
+	var client *gophercloud.ServiceClient
+	if conn.endpoint != "" {
+		client = &gophercloud.ServiceClient{
+			ProviderClient: provider,
+			Endpoint:       gophercloud.NormalizeURL(conn.endpoint),
+			Type:           "compute",
+		}
+	} else {
+		client, err = openstack.NewComputeV2(provider, gophercloud.EndpointOpts{
+			Region:       conn.region,
+			Availability: gophercloud.Availability(conn.availability),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create compute client: %w", err)
+		}
+	}
+	slog.Debug("compute endpoint", "endpoint", client.Endpoint, "region", conn.region, "interface", conn.availability)
+
 	return &OpenStackClient{
 		client: client,
 		cache:  make(map[string]string),
+		poll:   time.Second,
 	}, nil
 }
 
-func (c *OpenStackClient) getServerID(ctx context.Context, userID string) (string, error) {
+// compute returns the compute client with the given context, which cancels
+// its requests; gophercloud takes the context from the provider client, so
+// the latter is copied. Reauthentication refreshes the token of the original
+// provider client, unless another copy did it already, and the token is then
+// shared with the copy, so that the failed request is retried with it.
+func (c *OpenStackClient) compute(ctx context.Context) *gophercloud.ServiceClient {
+	original := c.client.ProviderClient
+	provider := *original
+	provider.Context = ctx
+	if reauth := original.ReauthFunc; reauth != nil {
+		// the copy shares the reauthentication lock with the original, which
+		// must not be reauthenticated through its own Reauthenticate
+		provider.ReauthFunc = func() error {
+			if original.Token() == provider.Token() {
+				if err := reauth(); err != nil {
+					return err
+				}
+			}
+			provider.CopyTokenFrom(original)
+			return nil
+		}
+	}
+	client := *c.client
+	client.ProviderClient = &provider
+	return &client
+}
+
+// ServerID returns the ID of the server tagged with the given user.
+func (c *OpenStackClient) ServerID(ctx context.Context, userID string) (string, error) {
 	c.mu.RLock()
 	id, ok := c.cache[userID]
 	c.mu.RUnlock()
@@ -56,7 +204,7 @@ func (c *OpenStackClient) getServerID(ctx context.Context, userID string) (strin
 		Tags: fmt.Sprintf("slumber-user-id=%s", userID),
 	}
 
-	allPages, err := servers.List(c.client, listOpts).AllPages()
+	allPages, err := servers.List(c.compute(ctx), listOpts).AllPages()
 	if err != nil {
 		return "", fmt.Errorf("failed to list servers: %w", err)
 	}
@@ -79,47 +227,134 @@ func (c *OpenStackClient) getServerID(ctx context.Context, userID string) (strin
 }
 
 func (c *OpenStackClient) Start(ctx context.Context, userID string) error {
-	id, err := c.getServerID(ctx, userID)
+	id, err := c.ServerID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	res := startstop.Start(c.client, id)
+	res := startstop.Start(c.compute(ctx), id)
 	return res.ExtractErr()
 }
 
 func (c *OpenStackClient) Stop(ctx context.Context, userID string) error {
-	id, err := c.getServerID(ctx, userID)
+	id, err := c.ServerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return c.StopServer(ctx, id)
+}
+
+// StopServer stops the server with the given ID.
+func (c *OpenStackClient) StopServer(ctx context.Context, id string) error {
+	res := startstop.Stop(c.compute(ctx), id)
+	return res.ExtractErr()
+}
+
+func (c *OpenStackClient) Shelve(ctx context.Context, userID string) error {
+	id, err := c.ServerID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	res := startstop.Stop(c.client, id)
+	return c.ShelveServer(ctx, id)
+}
+
+// ShelveServer shelves the server with the given ID.
+func (c *OpenStackClient) ShelveServer(ctx context.Context, id string) error {
+	res := shelveunshelve.Shelve(c.compute(ctx), id)
+	return res.ExtractErr()
+}
+
+func (c *OpenStackClient) ShelveOffload(ctx context.Context, userID string) error {
+	id, err := c.ServerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return c.ShelveOffloadServer(ctx, id)
+}
+
+// ShelveOffloadServer removes the shelved server with the given ID from its
+// hypervisor.
+func (c *OpenStackClient) ShelveOffloadServer(ctx context.Context, id string) error {
+	res := shelveunshelve.ShelveOffload(c.compute(ctx), id)
+	return res.ExtractErr()
+}
+
+func (c *OpenStackClient) Unshelve(ctx context.Context, userID string) error {
+	id, err := c.ServerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	res := shelveunshelve.Unshelve(c.compute(ctx), id, shelveunshelve.UnshelveOpts{})
+	return res.ExtractErr()
+}
+
+func (c *OpenStackClient) Suspend(ctx context.Context, userID string) error {
+	id, err := c.ServerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return c.SuspendServer(ctx, id)
+}
+
+// SuspendServer suspends the server with the given ID.
+func (c *OpenStackClient) SuspendServer(ctx context.Context, id string) error {
+	res := suspendresume.Suspend(c.compute(ctx), id)
+	return res.ExtractErr()
+}
+
+func (c *OpenStackClient) Resume(ctx context.Context, userID string) error {
+	id, err := c.ServerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	res := suspendresume.Resume(c.compute(ctx), id)
 	return res.ExtractErr()
 }
 
 func (c *OpenStackClient) Pause(ctx context.Context, userID string) error {
-	id, err := c.getServerID(ctx, userID)
+	id, err := c.ServerID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	res := pauseunpause.Pause(c.client, id)
+	return c.PauseServer(ctx, id)
+}
+
+// PauseServer pauses the server with the given ID.
+func (c *OpenStackClient) PauseServer(ctx context.Context, id string) error {
+	res := pauseunpause.Pause(c.compute(ctx), id)
 	return res.ExtractErr()
 }
 
 func (c *OpenStackClient) Unpause(ctx context.Context, userID string) error {
-	id, err := c.getServerID(ctx, userID)
+	id, err := c.ServerID(ctx, userID)
 	if err != nil {
 		return err
 	}
-	res := pauseunpause.Unpause(c.client, id)
+	res := pauseunpause.Unpause(c.compute(ctx), id)
 	return res.ExtractErr()
 }
 
+// ActOnServer takes one of the Actions on the server with the given ID.
+func (c *OpenStackClient) ActOnServer(ctx context.Context, id string, action string) error {
+	switch action {
+	case ActionStop:
+		return c.StopServer(ctx, id)
+	case ActionShelve:
+		return c.ShelveServer(ctx, id)
+	case ActionSuspend:
+		return c.SuspendServer(ctx, id)
+	case ActionPause:
+		return c.PauseServer(ctx, id)
+	default:
+		return fmt.Errorf("invalid cloud action %q", action)
+	}
+}
+
 func (c *OpenStackClient) Status(ctx context.Context, userID string) (string, error) {
-	id, err := c.getServerID(ctx, userID)
+	id, err := c.ServerID(ctx, userID)
 	if err != nil {
 		return "", err
 	}
-	server, err := servers.Get(c.client, id).Extract()
+	server, err := servers.Get(c.compute(ctx), id).Extract()
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestComputeReauth(t *testing.T) {
+	c := testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"server": {"id": "8c1b", "status": "ACTIVE"}}`))
+	}))
+	provider := c.client.ProviderClient
+	provider.UseTokenLock()
+	provider.SetToken("expired")
+	reauth := 0
+	provider.ReauthFunc = func() error {
+		reauth++
+		provider.SetToken("fresh")
+		return nil
+	}
+
+	// the request is retried with the new token, which is kept for the next
+	for range 2 {
+		status, err := c.Status(context.Background(), "developer")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status != "ACTIVE" {
+			t.Errorf("expected ACTIVE, got %q", status)
+		}
+	}
+	if reauth != 1 {
+		t.Errorf("expected 1 reauthentication, got %d", reauth)
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/extensions/extendedstatus"
+	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
+)
+
+const (
+	// maxPoll is the longest interval between status polls.
+	maxPoll = 15 * time.Second
+	// idlePolls is how many polls a server may stay without a task before it
+	// is considered not to be moving towards the awaited status, since the
+	// API may accept an action a little before setting the task.
+	idlePolls = 3
+)
+
+// server is a server with its task state.
+type server struct {
+	servers.Server
+	extendedstatus.ServerExtendedStatusExt
+}
+
+// StatusError is returned when a server ends up in a status other than the
+// awaited one.
+type StatusError struct {
+	// ID is the ID of the server.
+	ID string
+	// Status is the status of the server.
+	Status string
+	// Awaited are the awaited statuses.
+	Awaited []string
+	// Fault is the message of the server fault, if any.
+	Fault string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("server %s is %s, not %v", e.ID, e.Status, e.Awaited)
+	if e.Fault != "" {
+		msg += ": " + e.Fault
+	}
+	return msg
+}
+
+// WaitForStatus waits until the server of the given user has the given
+// status (e.g. ACTIVE or SHUTOFF), polling with an increasing interval; it
+// fails if the server goes into ERROR, if its task ends in another status,
+// or when the context is done.
+func (c *OpenStackClient) WaitForStatus(ctx context.Context, userID string, status string) error {
+	id, err := c.ServerID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return c.waitForServerStatus(ctx, id, status)
+}
+
+// WaitForServerStatus is like WaitForStatus, for the server with the given ID.
+func (c *OpenStackClient) WaitForServerStatus(ctx context.Context, id string, status string) error {
+	return c.waitForServerStatus(ctx, id, status)
+}
+
+func (c *OpenStackClient) waitForServerStatus(ctx context.Context, id string, statuses ...string) error {
+	poll := c.poll
+	if poll <= 0 {
+		poll = time.Second
+	}
+	var tasked bool
+	idle := 0
+	for {
+		var s server
+		if err := servers.Get(c.compute(ctx), id).ExtractInto(&s); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("server %s not %v: %w", id, statuses, ctx.Err())
+			}
+			return fmt.Errorf("failed to get server %s: %w", id, err)
+		}
+		slog.Debug("server status", "id", id, "status", s.Status, "task_state", s.TaskState, "awaited", statuses)
+		switch {
+		case s.Status == "ERROR":
+			return &StatusError{ID: id, Status: s.Status, Awaited: statuses, Fault: s.Fault.Message}
+		case s.TaskState != "":
+			tasked = true
+			idle = 0
+		case slices.Contains(statuses, s.Status):
+			return nil
+		case tasked:
+			// the task is over, and the server is not where it should be
+			return &StatusError{ID: id, Status: s.Status, Awaited: statuses, Fault: s.Fault.Message}
+		default:
+			if idle++; idle >= idlePolls {
+				return &StatusError{ID: id, Status: s.Status, Awaited: statuses, Fault: s.Fault.Message}
+			}
+		}
+
+		timer := time.NewTimer(poll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("server %s not %v: %w", id, statuses, ctx.Err())
+		case <-timer.C:
+		}
+		poll = min(2*poll, maxPoll)
+	}
+}
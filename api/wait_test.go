@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+)
+
+// nova serves a server whose status and task state go through the given
+// steps, one per request, staying at the last one.
+type nova struct {
+	mu    sync.Mutex
+	steps [][2]string
+	calls int
+}
+
+func (n *nova) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	step := n.steps[min(n.calls, len(n.steps)-1)]
+	n.calls++
+	n.mu.Unlock()
+	task := "null"
+	if step[1] != "" {
+		task = fmt.Sprintf("%q", step[1])
+	}
+	fault := ""
+	if step[0] == "ERROR" {
+		fault = `, "fault": {"code": 500, "message": "No valid host was found."}`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"server": {"id": "8c1b", "status": %q, "OS-EXT-STS:task_state": %s%s}}`, step[0], task, fault)
+}
+
+func testClient(t *testing.T, handler http.Handler) *OpenStackClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &OpenStackClient{
+		client: &gophercloud.ServiceClient{
+			ProviderClient: &gophercloud.ProviderClient{},
+			Endpoint:       srv.URL + "/",
+		},
+		cache: map[string]string{"developer": "8c1b"},
+		poll:  time.Millisecond,
+	}
+}
+
+func TestWaitForStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		steps  [][2]string
+		status string
+		fault  string
+	}{
+		{
+			name:   "stopping",
+			steps:  [][2]string{{"ACTIVE", ""}, {"ACTIVE", "powering-off"}, {"ACTIVE", "powering-off"}, {"SHUTOFF", ""}},
+			status: "SHUTOFF",
+		},
+		{
+			name:   "already there",
+			steps:  [][2]string{{"ACTIVE", ""}},
+			status: "ACTIVE",
+		},
+		{
+			name:   "status before task end",
+			steps:  [][2]string{{"SHELVED", "shelving_offloading"}, {"SHELVED_OFFLOADED", ""}},
+			status: "SHELVED_OFFLOADED",
+		},
+		{
+			name:   "error",
+			steps:  [][2]string{{"SHUTOFF", "powering-on"}, {"ERROR", ""}},
+			status: "ACTIVE",
+			fault:  "server 8c1b is ERROR, not [ACTIVE]: No valid host was found.",
+		},
+		{
+			name:   "task ends elsewhere",
+			steps:  [][2]string{{"ACTIVE", "suspending"}, {"ACTIVE", ""}},
+			status: "SUSPENDED",
+			fault:  "server 8c1b is ACTIVE, not [SUSPENDED]",
+		},
+		{
+			name:   "nothing happens",
+			steps:  [][2]string{{"ACTIVE", ""}},
+			status: "SHUTOFF",
+			fault:  "server 8c1b is ACTIVE, not [SHUTOFF]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testClient(t, &nova{steps: tt.steps})
+			err := c.WaitForStatus(context.Background(), "developer", tt.status)
+			if tt.fault == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || err.Error() != tt.fault {
+				t.Errorf("expected %q, got %v", tt.fault, err)
+			}
+		})
+	}
+}
+
+func TestWaitForStatusContext(t *testing.T) {
+	c := testClient(t, &nova{steps: [][2]string{{"ACTIVE", "powering-off"}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.WaitForServerStatus(ctx, "8c1b", "SHUTOFF"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	// requests are cancelled as well
+	c = testClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Status(ctx, "developer"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dihedron/slumberd/api"
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/power"
+)
+
+// backend returns the power backend selected in the configuration; the cloud
+// backend stops the server with the given instance UUID.
+func backend(cfg *configuration.Configuration, instanceID string) power.Backend {
+	kernel := power.NewKernel(*cfg.Proc, *cfg.Sys)
+	switch *cfg.Backend {
+	case "systemctl":
+		return &power.Systemctl{Kernel: kernel}
+	case "shutdown":
+		return &power.Classic{Sys: *cfg.Sys, Kernel: kernel}
+	case "cloud":
+		return &power.Cloud{
+			Stopper: power.StopperFunc(func(ctx context.Context) error {
+				return selfStop(ctx, cfg.Cloud, instanceID)
+			}),
+			Action:  configuration.CloudActions[cfg.Cloud.Action],
+			Timeout: time.Duration(*cfg.Cloud.Timeout),
+		}
+	default:
+		return &power.Logind{Kernel: kernel}
+	}
+}
+
+// selfStop asks OpenStack to take the configured action on the server the
+// daemon runs on, identified by its instance UUID: the servers tagged with
+// the user may be others of theirs, so they are not looked up instead.
+func selfStop(ctx context.Context, cloud *api.Cloud, instanceID string) error {
+	if instanceID == "" {
+		return errors.New("cannot identify the server: instance UUID unknown, the metadata service could not be read")
+	}
+	client, err := cloud.Client()
+	if err != nil {
+		return err
+	}
+	slog.Info("asking the cloud to act on the server", "action", cloud.Action, "server", instanceID)
+	return client.ActOnServer(ctx, instanceID, cloud.Action)
+}
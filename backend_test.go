@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dihedron/slumberd/api"
+)
+
+func TestSelfStopUnknownInstance(t *testing.T) {
+	// without its UUID, the server is not looked up by the tag of the user,
+	// which other servers may have
+	err := selfStop(context.Background(), &api.Cloud{Action: api.ActionStop}, "")
+	if err == nil || !strings.Contains(err.Error(), "instance UUID unknown") {
+		t.Errorf("expected an error for an unknown instance UUID, got %v", err)
+	}
+}
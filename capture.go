@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/capture"
+)
+
+// CaptureCommand snapshots the inputs detectors use into a tar archive, for
+// later inspection or replay.
+type CaptureCommand struct {
+	// Configuration is the configuration file for the daemon.
+	Configuration configuration.Configuration `short:"c" long:"configuration" description:"Configuration file" required:"true" default:"/home/developer/packages.yaml"`
+	// Output is the path of the archive to create.
+	Output string `short:"o" long:"output" description:"Path of the capture archive" default:"slumberd-capture.tar"`
+	// Redact replaces process arguments and remote hosts with placeholders.
+	Redact bool `short:"r" long:"redact" description:"Redact process arguments (except for editor servers) and remote hosts and addresses"`
+}
+
+// Execute runs the capture command.
+func (cmd *CaptureCommand) Execute(args []string) error {
+	slog.Info("capturing detection inputs", "output", cmd.Output, "redact", cmd.Redact)
+
+	f, err := os.Create(path.Clean(cmd.Output))
+	if err != nil {
+		slog.Error("failed to create capture archive", "path", cmd.Output, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to create capture archive: %v\n", err)
+		return err
+	}
+	defer f.Close()
+
+	if err := capture.Write(f, source(&cmd.Configuration), &cmd.Configuration, cmd.Redact); err != nil {
+		slog.Error("failed to write capture archive", "path", cmd.Output, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to write capture archive: %v\n", err)
+		return err
+	}
+	fmt.Printf("detection inputs captured to %s\n", cmd.Output)
+	return nil
+}
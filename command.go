@@ -1,17 +1,14 @@
 package main
 
 import (
-	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"sync"
 	"syscall"
-	"time"
 
 	"github.com/dihedron/slumberd/configuration"
-	"github.com/dihedron/slumberd/internal/detect"
+	"github.com/dihedron/slumberd/timex"
 	"github.com/fsnotify/fsnotify"
 )
 
@@ -29,20 +26,41 @@ func (cmd *Command) Execute(args []string) error {
 	slog.Info("starting daemon with configuration",
 		"timeout", cmd.Configuration.Timeout,
 		"frequency", cmd.Configuration.Frequency,
+		"warning", cmd.Configuration.Warning,
+		"grace", cmd.Configuration.Grace,
+		"boot_grace", cmd.Configuration.BootGrace,
+		"min_uptime", cmd.Configuration.MinUptime,
 		"packages", *cmd.Configuration.Packages,
 		"debounce", *cmd.Configuration.Debounce,
+		"sockets", *cmd.Configuration.Sockets,
+		"proc", *cmd.Configuration.Proc,
+		"sessions", *cmd.Configuration.Sessions,
+		"detectors", cmd.Configuration.Detectors,
+		"state", *cmd.Configuration.State,
+		"idle_samples", *cmd.Configuration.IdleSamples,
+		"active_samples", *cmd.Configuration.ActiveSamples,
+		"failure_policy", *cmd.Configuration.FailurePolicy,
+		"schedule", cmd.Configuration.Schedule != nil,
+		"dev", *cmd.Configuration.Dev,
+		"wall", *cmd.Configuration.Wall,
+		"warn_at", cmd.Configuration.WarnAt,
+		"webhooks", len(cmd.Configuration.Webhooks),
+		"email", cmd.Configuration.Email != nil,
+		"run_dir", *cmd.Configuration.RunDir,
+		"pre_action_hooks", cmd.Configuration.Hooks.PreAction,
+		"post_resume_hooks", cmd.Configuration.Hooks.PostResume,
+		"actions", cmd.Configuration.Actions,
+		"backend", *cmd.Configuration.Backend,
+		"wake_alarm", *cmd.Configuration.WakeAlarm,
+		"cloud_action", cmd.Configuration.Cloud.Action,
+		"cloud_name", cmd.Configuration.Cloud.Name,
+		"cloud_region", cmd.Configuration.Cloud.Region,
 	)
 
 	// set up signal handling for graceful shutdown
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
-	// set up ticker to run every frequency and check for active editors
-	timeout := time.Duration(*cmd.Configuration.Timeout)
-	frequency := time.Duration(*cmd.Configuration.Frequency)
-	ticker := time.NewTicker(frequency)
-	defer ticker.Stop()
-
 	// set up filesystem inotify watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -54,59 +72,11 @@ func (cmd *Command) Execute(args []string) error {
 		return err
 	}
 	defer watcher.Close()
-	var timer *time.Timer
-	var timerLock sync.Mutex
-
-	lastActive := time.Now()
 
-	for {
-		select {
-		case <-signals:
-			slog.Info("received termination signal, shutting down")
-			fmt.Println("received termination signal, shutting down...")
-			return nil
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return fmt.Errorf("watcher closed")
-			}
-			slog.Info("event received", "event", event.Name, "operation", event.Op)
-			if event.Name == *cmd.Configuration.Packages {
-				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-					timerLock.Lock()
-					// stop any existing timer (resetting the countdown)
-					if timer != nil {
-						timer.Stop()
-					}
-					// start a new timer
-					timer = time.AfterFunc(time.Duration(*cmd.Configuration.Debounce), func() {
-						slog.Info("file activity settled", "path", *cmd.Configuration.Packages)
-						// TODO: read the file and install packages
-					})
-					timerLock.Unlock()
-				}
-			}
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return fmt.Errorf("watcher closed")
-			}
-			slog.Error("error from filesystem inotify watcher", "error", err)
-		case <-ticker.C:
-			editors := detect.IsAnyEditorActive2("/proc")
-			if editors {
-				slog.Info("editor sessions active")
-				fmt.Println("editor sessions active...")
-				lastActive = time.Now()
-			} else {
-				idleTime := time.Since(lastActive)
-				slog.Info("no active editor sessions", "idle", idleTime.String())
-				fmt.Printf("no active editor sessions... idle: %s\n", idleTime.String())
-				if idleTime > timeout {
-					slog.Warn("idle timeout reached, shutting down...")
-					fmt.Println("shutting down...")
-					//power.Shutdown()
-					return nil
-				}
-			}
-		}
+	d, err := newDaemon(&cmd.Configuration, timex.RealClock, source(&cmd.Configuration))
+	if err != nil {
+		return err
 	}
+	d.backend = backend(&cmd.Configuration, d.instanceID)
+	return d.run(signals, watcher.Events, watcher.Errors)
 }
@@ -4,18 +4,84 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/dihedron/rawdata"
+	"github.com/dihedron/slumberd/api"
+	"github.com/dihedron/slumberd/internal/detect"
+	"github.com/dihedron/slumberd/internal/hooks"
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/internal/instance"
+	"github.com/dihedron/slumberd/internal/notify"
+	"github.com/dihedron/slumberd/internal/power"
+	"github.com/dihedron/slumberd/internal/schedule"
+	"github.com/dihedron/slumberd/internal/status"
 	"github.com/dihedron/slumberd/pointer"
 	"github.com/dihedron/slumberd/timex"
 )
 
+// DefaultStateFile is where the daemon persists its state by default.
+const DefaultStateFile = "/var/lib/slumberd/state.json"
+
+// DefaultWarnAt are the default lead times of the warnings sent before a
+// power action.
+var DefaultWarnAt = []timex.Duration{
+	timex.Duration(10 * time.Minute),
+	timex.Duration(5 * time.Minute),
+	timex.Duration(time.Minute),
+}
+
+// DefaultActions is the default chain of power actions: the first one that
+// is available is taken.
+var DefaultActions = []power.Action{power.PowerOff}
+
+// CloudActions are the power actions the cloud actions amount to for the
+// system: stopping and shelving the server power it off, so that it boots
+// again when started or unshelved, suspending it saves its memory to disk and
+// pausing it keeps its memory, so that it resumes where it was.
+var CloudActions = map[string]power.Action{
+	api.ActionShelve:  power.PowerOff,
+	api.ActionStop:    power.PowerOff,
+	api.ActionSuspend: power.Hibernate,
+	api.ActionPause:   power.Suspend,
+}
+
 type Configuration struct {
-	Packages  *string         `json:"packages,omitempty" yaml:"packages,omitempty"`
-	Debounce  *timex.Duration `json:"debounce,omitempty" yaml:"debounce,omitempty"`
-	Timeout   *timex.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
-	Frequency *timex.Duration `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+	Packages      *string            `json:"packages,omitempty" yaml:"packages,omitempty"`
+	Debounce      *timex.Duration    `json:"debounce,omitempty" yaml:"debounce,omitempty"`
+	Timeout       *timex.Duration    `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Frequency     *timex.Duration    `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+	Warning       *timex.Duration    `json:"warning,omitempty" yaml:"warning,omitempty"`
+	Grace         *timex.Duration    `json:"grace,omitempty" yaml:"grace,omitempty"`
+	BootGrace     *timex.Duration    `json:"boot_grace,omitempty" yaml:"boot_grace,omitempty"`
+	MinUptime     *timex.Duration    `json:"min_uptime,omitempty" yaml:"min_uptime,omitempty"`
+	Sockets       *string            `json:"sockets,omitempty" yaml:"sockets,omitempty"`
+	Proc          *string            `json:"proc,omitempty" yaml:"proc,omitempty"`
+	Sys           *string            `json:"sys,omitempty" yaml:"sys,omitempty"`
+	Sessions      *string            `json:"sessions,omitempty" yaml:"sessions,omitempty"`
+	Detectors     []string           `json:"detectors,omitempty" yaml:"detectors,omitempty"`
+	State         *string            `json:"state,omitempty" yaml:"state,omitempty"`
+	IdleSamples   *int               `json:"idle_samples,omitempty" yaml:"idle_samples,omitempty"`
+	ActiveSamples *int               `json:"active_samples,omitempty" yaml:"active_samples,omitempty"`
+	FailurePolicy *string            `json:"failure_policy,omitempty" yaml:"failure_policy,omitempty"`
+	Schedule      *schedule.Schedule `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	Dev           *string            `json:"dev,omitempty" yaml:"dev,omitempty"`
+	Wall          *bool              `json:"wall,omitempty" yaml:"wall,omitempty"`
+	WarnAt        []timex.Duration   `json:"warn_at,omitempty" yaml:"warn_at,omitempty"`
+	WarnMessage   *string            `json:"warn_message,omitempty" yaml:"warn_message,omitempty"`
+	UserID        *string            `json:"user_id,omitempty" yaml:"user_id,omitempty"`
+	MetadataURL   *string            `json:"metadata_url,omitempty" yaml:"metadata_url,omitempty"`
+	Webhooks      []*notify.Webhook  `json:"webhooks,omitempty" yaml:"webhooks,omitempty"`
+	Email         *notify.Email      `json:"email,omitempty" yaml:"email,omitempty"`
+	RunDir        *string            `json:"run_dir,omitempty" yaml:"run_dir,omitempty"`
+	Hooks         *hooks.Hooks       `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+	Actions       []power.Action     `json:"actions,omitempty" yaml:"actions,omitempty"`
+	Backend       *string            `json:"backend,omitempty" yaml:"backend,omitempty"`
+	WakeAlarm     *string            `json:"wake_alarm,omitempty" yaml:"wake_alarm,omitempty"`
+	WakeLead      *timex.Duration    `json:"wake_lead,omitempty" yaml:"wake_lead,omitempty"`
+	Cloud         *api.Cloud         `json:"cloud,omitempty" yaml:"cloud,omitempty"`
 }
 
 // UnmarshalFlag unmarshals a file path into the Configuration variable.
@@ -47,6 +113,186 @@ func (c *Configuration) UnmarshalFlag(value string) error {
 		slog.Warn("no or invalid frequency specified, using default", "frequency", c.Frequency, "default", timex.Duration(1*time.Minute))
 		c.Frequency = pointer.To(timex.Duration(time.Minute))
 	}
+	if c.Warning == nil || *c.Warning < 0 {
+		slog.Warn("no or invalid warning lead time specified, using default", "warning", c.Warning, "default", timex.Duration(5*time.Minute))
+		c.Warning = pointer.To(timex.Duration(5 * time.Minute))
+	}
+	if c.Grace == nil || *c.Grace < 0 {
+		slog.Warn("no or invalid grace period specified, using default", "grace", c.Grace, "default", timex.Duration(1*time.Minute))
+		c.Grace = pointer.To(timex.Duration(time.Minute))
+	}
+	if c.BootGrace == nil || *c.BootGrace < 0 {
+		slog.Warn("no or invalid boot grace period specified, using default", "boot_grace", c.BootGrace, "default", timex.Duration(10*time.Minute))
+		c.BootGrace = pointer.To(timex.Duration(10 * time.Minute))
+	}
+	if c.MinUptime == nil || *c.MinUptime < 0 {
+		slog.Warn("no or invalid minimum uptime specified, using default", "min_uptime", c.MinUptime, "default", timex.Duration(30*time.Minute))
+		c.MinUptime = pointer.To(timex.Duration(30 * time.Minute))
+	}
+	if c.Proc == nil || *c.Proc == "" {
+		slog.Warn("no proc path specified, using default", "default", "/proc")
+		c.Proc = pointer.To("/proc")
+	}
+	if c.Sys == nil || *c.Sys == "" {
+		slog.Warn("no sys path specified, using default", "default", "/sys")
+		c.Sys = pointer.To("/sys")
+	}
+	// netlink sees the connections of the network namespace the daemon runs
+	// in, which is not that of a procfs mounted elsewhere (e.g. the host's)
+	foreign := filepath.Clean(*c.Proc) != "/proc"
+	if c.Sockets == nil || *c.Sockets == "" {
+		sockets := detect.SocketsNetlink
+		if foreign {
+			sockets = detect.SocketsProcFS
+		}
+		slog.Warn("no sockets backend specified, using default", "default", sockets, "proc", *c.Proc)
+		c.Sockets = pointer.To(sockets)
+	}
+	if *c.Sockets != detect.SocketsNetlink && *c.Sockets != detect.SocketsProcFS {
+		slog.Error("invalid sockets backend", "sockets", *c.Sockets)
+		return fmt.Errorf("invalid sockets backend %q: must be %q or %q", *c.Sockets, detect.SocketsNetlink, detect.SocketsProcFS)
+	}
+	if foreign && *c.Sockets == detect.SocketsNetlink {
+		slog.Error("netlink sockets backend with a foreign proc filesystem", "proc", *c.Proc)
+		return fmt.Errorf("sockets backend %q cannot be used with proc %s, whose network namespace it does not see: use %q", detect.SocketsNetlink, *c.Proc, detect.SocketsProcFS)
+	}
+	if c.Sessions == nil || *c.Sessions == "" {
+		slog.Warn("no sessions path specified, using default", "default", "/run/systemd/sessions")
+		c.Sessions = pointer.To("/run/systemd/sessions")
+	}
+	if len(c.Detectors) == 0 {
+		slog.Warn("no detectors specified, using default", "default", []string{"vscode"})
+		c.Detectors = []string{"vscode"}
+	}
+	if c.State == nil || *c.State == "" {
+		slog.Warn("no state file specified, using default", "default", DefaultStateFile)
+		c.State = pointer.To(DefaultStateFile)
+	}
+	if c.IdleSamples == nil || *c.IdleSamples <= 0 {
+		slog.Warn("no or invalid number of idle samples specified, using default", "idle_samples", c.IdleSamples, "default", 2)
+		c.IdleSamples = pointer.To(2)
+	}
+	if c.ActiveSamples == nil || *c.ActiveSamples <= 0 {
+		slog.Warn("no or invalid number of active samples specified, using default", "active_samples", c.ActiveSamples, "default", 1)
+		c.ActiveSamples = pointer.To(1)
+	}
+	if c.FailurePolicy == nil || *c.FailurePolicy == "" {
+		slog.Warn("no failure policy specified, using default", "default", idle.FailOpen)
+		c.FailurePolicy = pointer.To(string(idle.FailOpen))
+	}
+	if _, err := idle.ParsePolicy(*c.FailurePolicy); err != nil {
+		slog.Error("invalid failure policy", "failure_policy", *c.FailurePolicy, "error", err)
+		return err
+	}
+	if _, err := detect.Lookup(c.Detectors...); err != nil {
+		slog.Error("invalid detectors", "detectors", c.Detectors, "error", err)
+		return fmt.Errorf("invalid detectors: %w", err)
+	}
+	if c.Dev == nil || *c.Dev == "" {
+		slog.Warn("no dev path specified, using default", "default", "/dev")
+		c.Dev = pointer.To("/dev")
+	}
+	if c.Wall == nil {
+		slog.Warn("no wall broadcast setting specified, using default", "default", true)
+		c.Wall = pointer.To(true)
+	}
+	if len(c.WarnAt) == 0 {
+		slog.Warn("no warning countdown specified, using default", "default", DefaultWarnAt)
+		c.WarnAt = slices.Clone(DefaultWarnAt)
+	}
+	for _, at := range c.WarnAt {
+		if at <= 0 {
+			slog.Error("invalid warning countdown", "warn_at", c.WarnAt)
+			return fmt.Errorf("invalid warning countdown: lead time %s must be positive", at.String())
+		}
+	}
+	if c.WarnMessage == nil || *c.WarnMessage == "" {
+		slog.Warn("no warning message specified, using default")
+		c.WarnMessage = pointer.To(notify.DefaultTemplate)
+	}
+	if _, err := notify.ParseTemplate("warning message", *c.WarnMessage); err != nil {
+		slog.Error("invalid warning message", "error", err)
+		return err
+	}
+	if c.RunDir == nil || *c.RunDir == "" {
+		slog.Warn("no runtime directory specified, using default", "default", status.DefaultDir)
+		c.RunDir = pointer.To(status.DefaultDir)
+	}
+	if c.Hooks == nil {
+		slog.Warn("no hooks specified, using default", "pre_action", hooks.DefaultPreAction, "post_resume", hooks.DefaultPostResume)
+		c.Hooks = &hooks.Hooks{}
+	}
+	if err := c.Hooks.Validate(); err != nil {
+		slog.Error("invalid hooks", "error", err)
+		return fmt.Errorf("invalid hooks: %w", err)
+	}
+	if len(c.Actions) == 0 {
+		slog.Warn("no power actions specified, using default", "default", DefaultActions)
+		c.Actions = slices.Clone(DefaultActions)
+	}
+	for _, action := range c.Actions {
+		if _, err := power.ParseAction(string(action)); err != nil {
+			slog.Error("invalid power actions", "actions", c.Actions, "error", err)
+			return fmt.Errorf("invalid power actions: %w", err)
+		}
+	}
+	if (slices.Contains(c.Actions, power.Hibernate) || slices.Contains(c.Actions, power.HybridSleep)) && !slices.Contains(c.Actions, power.PowerOff) {
+		slog.Warn("hibernation may not be feasible, falling back to poweroff", "actions", c.Actions)
+		c.Actions = append(c.Actions, power.PowerOff)
+	}
+	if c.Backend == nil || *c.Backend == "" {
+		slog.Warn("no power backend specified, using default", "default", "logind")
+		c.Backend = pointer.To("logind")
+	}
+	if !slices.Contains(power.Backends, *c.Backend) {
+		slog.Error("invalid power backend", "backend", *c.Backend)
+		return fmt.Errorf("invalid power backend %q: must be one of logind, systemctl, shutdown or cloud", *c.Backend)
+	}
+	if c.Cloud == nil {
+		c.Cloud = &api.Cloud{}
+	}
+	if err := c.Cloud.Validate(); err != nil {
+		slog.Error("invalid cloud settings", "error", err)
+		return fmt.Errorf("invalid cloud settings: %w", err)
+	}
+	if *c.Backend == "cloud" {
+		// the cloud takes its own action, whatever the chain
+		action := CloudActions[c.Cloud.Action]
+		if !slices.Equal(c.Actions, []power.Action{action}) {
+			slog.Info("power actions replaced by the one of the cloud", "actions", c.Actions, "cloud_action", c.Cloud.Action, "action", action)
+		}
+		c.Actions = []power.Action{action}
+	}
+	if c.WakeAlarm == nil {
+		slog.Warn("no wake alarm specified, using default", "default", power.DefaultWakeAlarm)
+		c.WakeAlarm = pointer.To(power.DefaultWakeAlarm)
+	}
+	if c.WakeLead == nil || *c.WakeLead < 0 {
+		slog.Warn("no or invalid wake lead time specified, using default", "wake_lead", c.WakeLead, "default", timex.Duration(5*time.Minute))
+		c.WakeLead = pointer.To(timex.Duration(5 * time.Minute))
+	}
+	if c.MetadataURL == nil {
+		slog.Warn("no metadata service URL specified, using default", "default", instance.DefaultMetadataURL)
+		c.MetadataURL = pointer.To(instance.DefaultMetadataURL)
+	}
+	for i, webhook := range c.Webhooks {
+		if err := webhook.Validate(); err != nil {
+			slog.Error("invalid webhook", "index", i, "error", err)
+			return fmt.Errorf("invalid webhook %d: %w", i+1, err)
+		}
+	}
+	if c.Email != nil {
+		if err := c.Email.Validate(); err != nil {
+			slog.Error("invalid email settings", "error", err)
+			return fmt.Errorf("invalid email settings: %w", err)
+		}
+	}
+	if c.Schedule != nil {
+		if err := c.Schedule.Validate(); err != nil {
+			slog.Error("invalid schedule", "error", err)
+			return fmt.Errorf("invalid schedule: %w", err)
+		}
+	}
 
 	// check that the packages file exists and is readable
 	if _, err := os.Stat(*c.Packages); err != nil {
@@ -64,6 +310,15 @@ func (c *Configuration) UnmarshalFlag(value string) error {
 	}
 	f.Close()
 
+	// check that the proc filesystem is available
+	if info, err := os.Stat(*c.Proc); err != nil {
+		slog.Error("error checking proc filesystem", "path", *c.Proc, "error", err)
+		return fmt.Errorf("error checking proc filesystem %s: %w", *c.Proc, err)
+	} else if !info.IsDir() {
+		slog.Error("proc filesystem is not a directory", "path", *c.Proc)
+		return fmt.Errorf("proc filesystem %s is not a directory", *c.Proc)
+	}
+
 	// check that activity monitor values are valid
 	timeout := time.Duration(*c.Timeout)
 	frequency := time.Duration(*c.Frequency)
@@ -71,6 +326,10 @@ func (c *Configuration) UnmarshalFlag(value string) error {
 		slog.Warn("frequency is greater than timeout, setting timeout to frequency", "frequency", frequency, "timeout", timeout)
 		*c.Timeout = timex.Duration(frequency)
 	}
+	if *c.Warning > *c.Timeout {
+		slog.Warn("warning lead time is greater than timeout, setting warning lead time to timeout", "warning", *c.Warning, "timeout", *c.Timeout)
+		*c.Warning = *c.Timeout
+	}
 
 	return nil
 }
@@ -2,10 +2,12 @@ package configuration
 
 import (
 	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/dihedron/slumberd/internal/power"
 	"github.com/dihedron/slumberd/pointer"
 	"github.com/dihedron/slumberd/timex"
 )
@@ -101,3 +103,213 @@ func TestConfigurationCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigurationSchedule(t *testing.T) {
+	packages, err := os.CreateTemp("", "packages-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(packages.Name())
+	packages.Close()
+
+	tests := []struct {
+		name          string
+		schedule      string
+		expectedError string
+	}{
+		{
+			name: "Valid schedule",
+			schedule: `
+  timezone: Europe/Rome
+  rules:
+    - days: mon-fri
+      from: "08:00"
+      to: "19:00"
+      timeout: 1h
+  holidays: [12-25, 2026-04-06]
+  curfew:
+    cron: "30 23 * * mon-fri"
+`,
+		},
+		{
+			name:          "Invalid time zone",
+			schedule:      "\n  timezone: Mars/Olympus_Mons\n",
+			expectedError: "invalid time zone",
+		},
+		{
+			name:          "Invalid time of day",
+			schedule:      "\n  blackouts:\n    - from: \"25:00\"\n",
+			expectedError: "invalid time of day",
+		},
+		{
+			name:          "Invalid weekday",
+			schedule:      "\n  blackouts:\n    - days: mon-fry\n",
+			expectedError: "invalid day of the week",
+		},
+		{
+			name:          "Invalid cron expression",
+			schedule:      "\n  curfew:\n    cron: \"61 * * * *\"\n",
+			expectedError: "invalid cron expression",
+		},
+		{
+			name:          "Invalid holiday",
+			schedule:      "\n  holidays: [02-30]\n",
+			expectedError: "invalid date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile, err := os.CreateTemp("", "config-*.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(configFile.Name())
+			configContent := "packages: " + packages.Name() + "\nschedule:" + tt.schedule
+			if _, err := configFile.WriteString(configContent); err != nil {
+				t.Fatal(err)
+			}
+			configFile.Close()
+
+			c := &Configuration{}
+			err = c.UnmarshalFlag(configFile.Name())
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestConfigurationSockets(t *testing.T) {
+	packages, err := os.CreateTemp("", "packages-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(packages.Name())
+	packages.Close()
+	proc := t.TempDir()
+
+	tests := []struct {
+		name          string
+		config        string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "Default",
+			config:   "proc: /proc\n",
+			expected: "netlink",
+		},
+		{
+			name:     "Foreign proc",
+			config:   "proc: " + proc + "\n",
+			expected: "procfs",
+		},
+		{
+			name:     "Foreign proc with procfs",
+			config:   "proc: " + proc + "\nsockets: procfs\n",
+			expected: "procfs",
+		},
+		{
+			name:          "Foreign proc with netlink",
+			config:        "proc: " + proc + "\nsockets: netlink\n",
+			expectedError: "cannot be used with proc " + proc,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile, err := os.CreateTemp("", "config-*.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(configFile.Name())
+			if _, err := configFile.WriteString("packages: " + packages.Name() + "\n" + tt.config); err != nil {
+				t.Fatal(err)
+			}
+			configFile.Close()
+
+			c := &Configuration{}
+			err = c.UnmarshalFlag(configFile.Name())
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if *c.Sockets != tt.expected {
+				t.Errorf("expected sockets %q, got %q", tt.expected, *c.Sockets)
+			}
+		})
+	}
+}
+
+func TestConfigurationCloudActions(t *testing.T) {
+	packages, err := os.CreateTemp("", "packages-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(packages.Name())
+	packages.Close()
+
+	tests := []struct {
+		name     string
+		config   string
+		expected []power.Action
+	}{
+		{
+			name:     "Local backend",
+			config:   "actions: [suspend, poweroff]\ncloud:\n  action: pause\n",
+			expected: []power.Action{power.Suspend, power.PowerOff},
+		},
+		{
+			name:     "Stop",
+			config:   "backend: cloud\n",
+			expected: []power.Action{power.PowerOff},
+		},
+		{
+			name:     "Shelve",
+			config:   "backend: cloud\nactions: [hibernate]\ncloud:\n  action: shelve\n",
+			expected: []power.Action{power.PowerOff},
+		},
+		{
+			name:     "Suspend",
+			config:   "backend: cloud\ncloud:\n  action: suspend\n",
+			expected: []power.Action{power.Hibernate},
+		},
+		{
+			name:     "Pause",
+			config:   "backend: cloud\nactions: [poweroff]\ncloud:\n  action: pause\n",
+			expected: []power.Action{power.Suspend},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile, err := os.CreateTemp("", "config-*.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(configFile.Name())
+			if _, err := configFile.WriteString("packages: " + packages.Name() + "\n" + tt.config); err != nil {
+				t.Fatal(err)
+			}
+			configFile.Close()
+
+			c := &Configuration{}
+			if err := c.UnmarshalFlag(configFile.Name()); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !slices.Equal(c.Actions, tt.expected) {
+				t.Errorf("expected actions %v, got %v", tt.expected, c.Actions)
+			}
+		})
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+	"github.com/dihedron/slumberd/internal/hooks"
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/internal/instance"
+	"github.com/dihedron/slumberd/internal/notify"
+	"github.com/dihedron/slumberd/internal/power"
+	"github.com/dihedron/slumberd/internal/state"
+	"github.com/dihedron/slumberd/internal/status"
+	"github.com/dihedron/slumberd/timex"
+	"github.com/fsnotify/fsnotify"
+)
+
+// postponeCommand is the command users are told to run to postpone the
+// power action.
+const postponeCommand = "slumberd postpone"
+
+// notifyTimeout bounds the time a notifier may take to deliver a message,
+// and the time left to the notifiers to deliver their messages once the
+// power action has been taken.
+const notifyTimeout = 10 * time.Second
+
+// metadataTimeout bounds the time spent reading the instance metadata at
+// startup, on hosts that may not have a metadata service.
+const metadataTimeout = 3 * time.Second
+
+// maxPostpone caps the time users without administrative rights can postpone
+// the power action by with a single request.
+const maxPostpone = 4 * time.Hour
+
+// daemon samples the configured detectors on every tick of its clock, drives
+// the idle state machine with the outcome and takes the power action when it
+// is due; all time-dependent behaviour goes through the clock, so that tests
+// can simulate days of activity in no time.
+type daemon struct {
+	cfg       *configuration.Configuration
+	clock     timex.Clock
+	src       *detect.Source
+	detectors []detect.Detector
+	// backend takes the power action; it must be set before running the
+	// daemon.
+	backend power.Backend
+
+	hysteresis *idle.Hysteresis
+	// idleSince is the first of the idle samples the hysteresis still
+	// reports as active.
+	idleSince time.Time
+	machine   *idle.Machine
+	bootID    string
+	bootTime  time.Time
+	// upSince is when the system booted or last resumed from sleep: the
+	// curfew applies to a system that has been up since before it.
+	upSince time.Time
+	// resumed is set if the system is back up after a power action taken
+	// at downSince.
+	resumed   bool
+	downSince time.Time
+	// asleep is set once the system was put to sleep (suspended or
+	// hibernated) at downSince, until the first sample after it resumes.
+	asleep bool
+
+	host   string
+	userID string
+	// instanceID is the UUID of the cloud server, from the instance metadata.
+	instanceID    string
+	notifications *notify.Dispatcher
+	countdown     *notify.Countdown
+	// curfewCountdown tracks the warnings of the curfew, which are sent
+	// whether or not users are active.
+	curfewCountdown *notify.Countdown
+	// due is why the power action is due, for the notification sent once it
+	// is taken.
+	due string
+	// action is the power action last taken, for the post-resume hooks.
+	action power.Action
+
+	timer     timex.Timer
+	timerLock sync.Mutex
+}
+
+// newDaemon returns a daemon reading its inputs from the given source, with
+// the idle clock resumed from the state file, if any.
+func newDaemon(cfg *configuration.Configuration, clock timex.Clock, src *detect.Source) (*daemon, error) {
+	detectors, err := detect.Lookup(cfg.Detectors...)
+	if err != nil {
+		slog.Error("error looking up detectors", "error", err)
+		return nil, err
+	}
+	src.Clock = clock
+
+	d := &daemon{
+		cfg:       cfg,
+		clock:     clock,
+		src:       src,
+		detectors: detectors,
+		hysteresis: idle.NewHysteresis(
+			*cfg.IdleSamples,
+			*cfg.ActiveSamples,
+			idle.Policy(*cfg.FailurePolicy),
+		),
+		machine: idle.New(
+			time.Duration(*cfg.Timeout),
+			time.Duration(*cfg.Warning),
+			time.Duration(*cfg.Grace),
+			clock.Now(),
+		),
+	}
+
+	// resume the idle clock from the persisted state, if any
+	if d.bootID, err = src.Proc.BootID(); err != nil {
+		slog.Warn("error reading boot id", "error", err)
+	}
+	if d.bootTime, err = src.Proc.BootTime(); err != nil {
+		slog.Warn("error reading boot time", "error", err)
+	}
+	d.upSince = d.bootTime
+	err = state.Update(*cfg.State, func(s *state.State) error {
+		if s.BootID != "" && s.BootID != d.bootID && s.Phase == idle.Acting {
+			slog.Info("system resumed after power action", "since", s.Since)
+			d.resumed = true
+			d.downSince = s.Since
+			// the boot id survives sleep, so the system was powered off
+			d.action = power.PowerOff
+		}
+		if !s.LastActive.IsZero() {
+			s.Sanitize(d.bootID, d.bootTime, clock.Now())
+			slog.Info("resuming idle clock from state file", "last_active", s.LastActive, "phase", s.Phase, "since", s.Since)
+			d.machine.Restore(s.Phase, s.Since, s.LastActive)
+			d.hysteresis.Reset(s.Phase == idle.Active)
+		}
+		d.save(s)
+		return nil
+	})
+	if err != nil {
+		slog.Error("error restoring state, starting idle clock now", "path", *cfg.State, "error", err)
+	}
+
+	if err := status.Prepare(*cfg.RunDir); err != nil {
+		slog.Warn("error preparing runtime directory, shell prompts and postpone requests will not work", "error", err)
+	}
+	d.host, _ = os.Hostname()
+	if cfg.UserID != nil {
+		d.userID = *cfg.UserID
+	}
+	// the instance may be tagged with the user it belongs to, and the cloud
+	// backend stops the server by its UUID
+	cloud := cfg.Backend != nil && *cfg.Backend == "cloud"
+	if (cfg.UserID == nil || cloud) && cfg.MetadataURL != nil && *cfg.MetadataURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+		if m, err := instance.Fetch(ctx, *cfg.MetadataURL); err != nil {
+			slog.Warn("error reading instance metadata", "error", err)
+			if cloud {
+				slog.Warn("the cloud backend cannot stop the server without its instance UUID")
+			}
+		} else {
+			d.instanceID = m.UUID
+			if cfg.UserID == nil {
+				d.userID = m.UserID()
+				slog.Info("user id read from instance metadata", "user_id", d.userID, "instance", m.UUID)
+			}
+		}
+		cancel()
+	}
+	warnAt := make([]time.Duration, len(cfg.WarnAt))
+	for i, at := range cfg.WarnAt {
+		warnAt[i] = time.Duration(at)
+	}
+	d.countdown = notify.NewCountdown(warnAt...)
+	d.curfewCountdown = notify.NewCountdown(warnAt...)
+	var notifiers []notify.Notifier
+	if *cfg.Wall {
+		template, err := notify.ParseTemplate("warning message", *cfg.WarnMessage)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, &notify.Wall{
+			Dev:      *cfg.Dev,
+			Sessions: src.Sessions,
+			Template: template,
+			Now:      clock.Now,
+		})
+	}
+	for _, webhook := range cfg.Webhooks {
+		notifiers = append(notifiers, webhook)
+	}
+	if cfg.Email != nil {
+		notifiers = append(notifiers, cfg.Email)
+	}
+	d.notifications = notify.NewDispatcher(notifyTimeout, notifiers...)
+
+	d.machine.Subscribe(func(event idle.Event) {
+		slog.Info("idle state transition", "event", event)
+		fmt.Printf("%s -> %s: %s (idle: %s, remaining: %s)\n", event.From, event.To, event.Reason, event.Idle.Round(time.Second), event.Remaining.Round(time.Second))
+		m := d.message(notify.Transition, event.Time, d.next(), event.Remaining, event.Reason)
+		m.From = event.From
+		m.To = event.To
+		m.Idle = event.Idle
+		d.notifications.Send(m)
+	})
+	return d, nil
+}
+
+// run samples the detectors on every tick until a termination signal is
+// received or the system is powered off, and debounces the changes to the
+// packages file reported on events.
+func (d *daemon) run(signals <-chan os.Signal, events <-chan fsnotify.Event, errors <-chan error) error {
+	// set up ticker to run every frequency and check for active editors
+	ticker := d.clock.NewTicker(time.Duration(*d.cfg.Frequency))
+	defer ticker.Stop()
+
+	if d.resumed {
+		d.resume()
+	}
+
+	for {
+		select {
+		case <-signals:
+			slog.Info("received termination signal, shutting down")
+			fmt.Println("received termination signal, shutting down...")
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return fmt.Errorf("watcher closed")
+			}
+			d.changed(event)
+		case err, ok := <-errors:
+			if !ok {
+				return fmt.Errorf("watcher closed")
+			}
+			slog.Error("error from filesystem inotify watcher", "error", err)
+		case <-ticker.C():
+			if d.tick() && d.act() {
+				d.flush()
+				return nil
+			}
+		}
+	}
+}
+
+// changed handles a filesystem event, (re)starting the debounce timer if the
+// packages file was written.
+func (d *daemon) changed(event fsnotify.Event) {
+	slog.Info("event received", "event", event.Name, "operation", event.Op)
+	if event.Name != *d.cfg.Packages || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+		return
+	}
+	d.timerLock.Lock()
+	defer d.timerLock.Unlock()
+	// stop any existing timer (resetting the countdown)
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	// start a new timer
+	d.timer = d.clock.AfterFunc(time.Duration(*d.cfg.Debounce), func() {
+		slog.Info("file activity settled", "path", *d.cfg.Packages)
+		// TODO: read the file and install packages
+	})
+}
+
+// tick takes a sample and feeds it to the idle state machine; it returns true
+// if the power action is due and must be taken now.
+func (d *daemon) tick() bool {
+	now := d.clock.Now()
+	if d.asleep {
+		d.awake(now)
+	}
+	d.postpone(now)
+	results := detect.Run(d.src, d.detectors)
+	verdict := detect.Aggregate(results)
+	idleSince := d.idleSince
+	active := d.hysteresis.Observe(verdict)
+	switch streak := d.hysteresis.Streak(); {
+	case active && streak == 1:
+		d.idleSince = now
+	case streak == 0:
+		d.idleSince = time.Time{}
+	}
+	reasons := detect.Evidence(results)
+	if verdict == detect.Unknown {
+		slog.Warn("detectors failed, applying failure policy", "policy", *d.cfg.FailurePolicy, "active", active)
+		if active {
+			reasons = append(reasons, "detectors failed (failure policy: "+*d.cfg.FailurePolicy+")")
+		}
+	}
+	inhibited := false
+	if s, err := state.Load(*d.cfg.State); err != nil {
+		slog.Error("error loading inhibits from state file", "path", *d.cfg.State, "error", err)
+	} else {
+		for _, inhibit := range s.ActiveInhibits(now) {
+			active = true
+			inhibited = true
+			reasons = append(reasons, inhibit.String())
+		}
+	}
+	// the user may take a while to connect after the system is started
+	uptime, uptimeErr := d.src.Proc.Uptime()
+	if uptimeErr != nil {
+		slog.Warn("error reading uptime", "error", uptimeErr)
+	} else if uptime < time.Duration(*d.cfg.BootGrace) {
+		active = true
+		reasons = append(reasons, fmt.Sprintf("boot grace period (up %s)", uptime.Round(time.Second)))
+	}
+	if active {
+		slog.Info("user activity detected", "reasons", reasons)
+	} else {
+		slog.Info("no user activity detected", "idle", now.Sub(d.machine.LastActive()).String())
+	}
+	schedule := d.cfg.Schedule
+	if schedule != nil {
+		// the idle timeout may depend on the time of day
+		timeout, rule := schedule.Timeout(now, time.Duration(*d.cfg.Timeout))
+		if timeout != d.machine.Timeout {
+			slog.Info("idle timeout changed by schedule", "rule", rule, "from", d.machine.Timeout, "to", timeout)
+			d.machine.Timeout = timeout
+			d.machine.Warning = min(time.Duration(*d.cfg.Warning), timeout)
+		}
+	}
+	var (
+		current  idle.State
+		reason   string
+		curfew   time.Time
+		curfewed bool
+	)
+	if schedule != nil && !inhibited && !d.upSince.IsZero() {
+		curfew = schedule.NextCurfew(d.upSince)
+		curfewed = schedule.IsCurfew(now, d.upSince)
+	}
+	if curfewed {
+		reason = "curfew at " + curfew.Format("2006-01-02 15:04 MST")
+		current = d.machine.Force(now, reason)
+	} else {
+		if !active && !idleSince.IsZero() {
+			// the hysteresis just confirmed that activity stopped when the
+			// first idle sample was taken
+			d.machine.Backdate(idleSince)
+		}
+		current = d.machine.Observe(now, active, strings.Join(reasons, "; "))
+		reason = "no user activity since " + d.machine.LastActive().Format("15:04")
+	}
+	d.persist(now)
+	if current == idle.Active {
+		d.countdown.Reset()
+	}
+	switch remaining := d.machine.Deadline().Sub(now); {
+	case current == idle.Acting:
+	case !curfew.IsZero() && (current == idle.Active || curfew.Before(d.machine.Deadline())):
+		// the curfew comes first, and users are warned of it even if active
+		if remaining := curfew.Sub(now); d.curfewCountdown.Due(remaining) {
+			d.warn(remaining, "curfew at "+curfew.Format("2006-01-02 15:04 MST"))
+		}
+	case current != idle.Active && d.countdown.Due(remaining):
+		d.warn(remaining, reason)
+	}
+	d.publish()
+	switch {
+	case current != idle.Acting:
+		return false
+	case uptimeErr == nil && uptime < time.Duration(*d.cfg.MinUptime):
+		slog.Info("minimum uptime not reached, deferring power action", "uptime", uptime, "min_uptime", d.cfg.MinUptime)
+		return false
+	case schedule != nil && schedule.InBlackout(now):
+		slog.Info("in blackout window, deferring power action")
+		return false
+	}
+	if d.cfg.Hooks != nil {
+		if err := d.cfg.Hooks.Run(context.Background(), hooks.StagePreAction, d.environment(now, d.next(), reason)); err != nil {
+			// a veto counts as activity, so the idle clock starts over
+			d.restart(now, err.Error())
+			return false
+		}
+	}
+	d.wake(now)
+	d.due = reason
+	return true
+}
+
+// act takes the first power action of the chain available through the
+// backend, falling back to the next ones if it fails; it returns true if the
+// system is being powered off. If the system is put to sleep instead, the
+// daemon goes on once it resumes; if no action could be taken, the idle clock
+// starts over. Users are told once the action is taken, or that it failed.
+func (d *daemon) act() bool {
+	now := d.clock.Now()
+	slog.Warn("grace period expired, taking power action...", "backend", d.backend.Name(), "actions", d.cfg.Actions)
+	action, err := power.Take(d.backend, d.cfg.Actions)
+	if err != nil {
+		slog.Error("no power action could be taken", "backend", d.backend.Name(), "actions", d.cfg.Actions, "error", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		d.restart(now, "power action failed")
+		d.notifications.Send(d.message(notify.Failure, now, d.next(), d.machine.Deadline().Sub(now), err.Error()))
+		return false
+	}
+	slog.Info("power action taken", "backend", d.backend.Name(), "action", action)
+	fmt.Printf("power action taken: %s\n", action)
+	d.notifications.Send(d.message(notify.Action, now, action, 0, d.due))
+	if action == power.PowerOff {
+		return true
+	}
+	d.asleep = true
+	d.downSince = now
+	d.action = action
+	return false
+}
+
+// awake restarts the idle clock and runs the post-resume hooks once the
+// system is back from sleep; the boot id does not change across suspend and
+// hibernation, so the resume is detected by the first sample taken after the
+// system was put to sleep.
+func (d *daemon) awake(now time.Time) {
+	slog.Info("system resumed after power action", "since", d.downSince)
+	d.asleep = false
+	d.upSince = now
+	d.curfewCountdown.Reset()
+	d.hysteresis.Reset(true)
+	d.idleSince = time.Time{}
+	d.restart(now, "resumed after power action")
+	d.resume()
+}
+
+// restart starts the idle clock over, as if there was activity.
+func (d *daemon) restart(now time.Time, reason string) {
+	d.machine.Observe(now, true, reason)
+	d.countdown.Reset()
+	d.persist(now)
+	d.publish()
+}
+
+// wake programs the RTC wake alarm ahead of the next working hours, so that
+// the system is ready when users come back.
+func (d *daemon) wake(now time.Time) {
+	if d.cfg.Schedule == nil || d.cfg.WakeAlarm == nil || *d.cfg.WakeAlarm == "" {
+		return
+	}
+	start := d.cfg.Schedule.NextWorkStart(now)
+	if start.IsZero() {
+		return
+	}
+	at := start.Add(-time.Duration(*d.cfg.WakeLead))
+	if !at.After(now) {
+		return
+	}
+	alarm := &power.Alarm{Path: *d.cfg.WakeAlarm}
+	if err := alarm.Set(at); err != nil {
+		slog.Warn("failed to set wake alarm", "path", *d.cfg.WakeAlarm, "error", err)
+		return
+	}
+	slog.Info("wake alarm set", "at", at, "working_hours", start)
+}
+
+// resume runs the post-resume hooks, once the system is back up after a
+// power action.
+func (d *daemon) resume() {
+	if d.cfg.Hooks == nil {
+		return
+	}
+	now := d.clock.Now()
+	env := append(d.environment(now, d.action, "resumed after power action"), "SLUMBERD_DOWN_SINCE="+d.downSince.Format(time.RFC3339))
+	if err := d.cfg.Hooks.Run(context.Background(), hooks.StagePostResume, env); err != nil {
+		slog.Error("error running post-resume hooks", "error", err)
+	}
+}
+
+// next returns the power action that would be taken now: the first one of
+// the chain available through the backend, or the first one of the chain if
+// none is, or if there is no backend.
+func (d *daemon) next() power.Action {
+	if len(d.cfg.Actions) == 0 {
+		return power.PowerOff
+	}
+	if d.backend != nil {
+		if action, err := power.Choose(d.backend, d.cfg.Actions); err == nil {
+			return action
+		}
+	}
+	return d.cfg.Actions[0]
+}
+
+// environment returns the variables describing the given power action to
+// hooks.
+func (d *daemon) environment(now time.Time, action power.Action, reason string) []string {
+	return []string{
+		"SLUMBERD_ACTION=" + string(action),
+		"SLUMBERD_REASON=" + reason,
+		"SLUMBERD_HOST=" + d.host,
+		"SLUMBERD_USER_ID=" + d.userID,
+		"SLUMBERD_BOOT_ID=" + d.bootID,
+		"SLUMBERD_LAST_ACTIVE=" + d.machine.LastActive().Format(time.RFC3339),
+		fmt.Sprintf("SLUMBERD_IDLE=%d", int(now.Sub(d.machine.LastActive()).Seconds())),
+	}
+}
+
+// persist saves the idle clock to the state file.
+func (d *daemon) persist(now time.Time) {
+	err := state.Update(*d.cfg.State, func(s *state.State) error {
+		d.save(s)
+		s.Prune(now)
+		return nil
+	})
+	if err != nil {
+		slog.Error("error saving state", "path", *d.cfg.State, "error", err)
+	}
+}
+
+// publish publishes the status of the idle state machine for users' shell
+// prompts.
+func (d *daemon) publish() {
+	published := status.Status{State: d.machine.State()}
+	if published.State != idle.Active {
+		published.Deadline = d.machine.Deadline()
+		published.Action = d.next()
+	}
+	if err := status.Write(*d.cfg.RunDir, published); err != nil {
+		slog.Warn("error publishing status", "error", err)
+	}
+}
+
+// postpone turns the postpone requests users dropped in the runtime
+// directory into inhibits.
+func (d *daemon) postpone(now time.Time) {
+	requests, err := status.Requests(*d.cfg.RunDir)
+	if err != nil {
+		slog.Warn("error reading postpone requests", "error", err)
+	}
+	if len(requests) == 0 {
+		return
+	}
+	err = state.Update(*d.cfg.State, func(s *state.State) error {
+		for _, request := range requests {
+			inhibit := state.Inhibit{
+				Who:   request.User,
+				Why:   "postponed",
+				Since: now,
+				Until: now.Add(min(request.Duration, maxPostpone)),
+			}
+			slog.Info("power action postponed on request", "inhibit", inhibit.String())
+			s.Inhibits = append(s.Inhibits, inhibit)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("error saving postpone requests", "path", *d.cfg.State, "error", err)
+	}
+}
+
+// warn tells users that the power action will be taken after the given
+// time; the notifiers deliver the warning in the background, so that they
+// never delay or prevent the power action.
+func (d *daemon) warn(remaining time.Duration, reason string) {
+	slog.Info("warning users of the power action", "remaining", remaining, "reason", reason)
+	d.notifications.Send(d.message(notify.Warning, d.clock.Now(), d.next(), remaining, reason))
+}
+
+// taken describes what is done to the host by each power action, for the
+// notifications.
+var taken = map[power.Action]string{
+	power.PowerOff:    "powered off",
+	power.Hibernate:   "hibernated",
+	power.Suspend:     "suspended",
+	power.HybridSleep: "put to hybrid sleep",
+}
+
+// message returns a message of the given kind about the current state of the
+// idle state machine and the given power action.
+func (d *daemon) message(kind notify.Kind, now time.Time, action power.Action, remaining time.Duration, reason string) notify.Message {
+	return notify.Message{
+		Kind:       kind,
+		Time:       now,
+		Host:       d.host,
+		UserID:     d.userID,
+		Action:     taken[action],
+		To:         d.machine.State(),
+		Idle:       now.Sub(d.machine.LastActive()),
+		Remaining:  remaining,
+		Deadline:   now.Add(remaining),
+		LastActive: d.machine.LastActive(),
+		Reason:     reason,
+		Postpone:   postponeCommand,
+	}
+}
+
+// flush gives the notifiers a chance to deliver the last messages while the
+// system goes down, without delaying the power action, which is already
+// under way.
+func (d *daemon) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := d.notifications.Wait(ctx); err != nil {
+		slog.Warn("notifications not delivered before exiting", "error", err)
+	}
+}
+
+// save copies the idle clock into the state.
+func (d *daemon) save(s *state.State) {
+	s.BootID = d.bootID
+	s.LastActive = d.machine.LastActive()
+	s.Phase = d.machine.State()
+	s.Since = d.machine.Since()
+}
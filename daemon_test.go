@@ -0,0 +1,786 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+	"github.com/dihedron/slumberd/internal/hooks"
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/internal/notify"
+	"github.com/dihedron/slumberd/internal/power"
+	"github.com/dihedron/slumberd/internal/schedule"
+	"github.com/dihedron/slumberd/internal/state"
+	"github.com/dihedron/slumberd/internal/status"
+	"github.com/dihedron/slumberd/pointer"
+	"github.com/dihedron/slumberd/timex"
+	"github.com/fsnotify/fsnotify"
+)
+
+// host is a simulated host, whose procfs and logind sessions are driven by
+// a fake clock.
+type host struct {
+	clock    *timex.FakeClock
+	boot     time.Time
+	proc     fstest.MapFS
+	sessions fstest.MapFS
+}
+
+func newHost(boot time.Time) *host {
+	h := &host{
+		clock: timex.NewFakeClock(boot),
+		boot:  boot,
+		proc: fstest.MapFS{
+			"sys/kernel/random/boot_id": {Data: []byte("2f6e8a1c-0c1b-4d55-9a53-3c1d7e2b9f10\n")},
+			"stat":                      {Data: fmt.Appendf(nil, "cpu  1 2 3 4\nbtime %d\n", boot.Unix())},
+		},
+		sessions: fstest.MapFS{},
+	}
+	h.update()
+	return h
+}
+
+// update refreshes the uptime to match the fake clock.
+func (h *host) update() {
+	uptime := h.clock.Since(h.boot).Seconds()
+	h.proc["uptime"] = &fstest.MapFile{Data: fmt.Appendf(nil, "%.2f %.2f\n", uptime, uptime)}
+}
+
+// login sets whether the user has an active session.
+func (h *host) login(active bool) {
+	if active {
+		h.sessions["3"] = &fstest.MapFile{Data: []byte("UID=1000\nUSER=developer\nSTATE=active\nCLASS=user\nTYPE=tty\nREMOTE=1\n")}
+	} else {
+		delete(h.sessions, "3")
+	}
+}
+
+func (h *host) source() *detect.Source {
+	return &detect.Source{
+		Proc:     &detect.Proc{FS: h.proc, Sockets: detect.SocketsProcFS},
+		Sessions: h.sessions,
+	}
+}
+
+func testConfiguration(t *testing.T) *configuration.Configuration {
+	t.Helper()
+	return &configuration.Configuration{
+		Packages:      pointer.To(filepath.Join(t.TempDir(), "packages.yaml")),
+		Debounce:      pointer.To(timex.Duration(500 * time.Millisecond)),
+		Timeout:       pointer.To(timex.Duration(30 * time.Minute)),
+		Frequency:     pointer.To(timex.Duration(time.Minute)),
+		Warning:       pointer.To(timex.Duration(5 * time.Minute)),
+		Grace:         pointer.To(timex.Duration(5 * time.Minute)),
+		BootGrace:     pointer.To(timex.Duration(10 * time.Minute)),
+		MinUptime:     pointer.To(timex.Duration(30 * time.Minute)),
+		Sockets:       pointer.To(detect.SocketsProcFS),
+		Detectors:     []string{"sessions"},
+		State:         pointer.To(filepath.Join(t.TempDir(), "state.json")),
+		IdleSamples:   pointer.To(2),
+		ActiveSamples: pointer.To(1),
+		FailurePolicy: pointer.To(string(idle.FailOpen)),
+		Dev:           pointer.To(t.TempDir()),
+		Wall:          pointer.To(true),
+		WarnAt:        configuration.DefaultWarnAt,
+		WarnMessage:   pointer.To(notify.DefaultTemplate),
+		RunDir:        pointer.To(t.TempDir()),
+	}
+}
+
+// simulate ticks the daemon every minute until the given time, with the
+// user logged in during the given windows, and returns when the daemon
+// decided to take the power action, or the zero time if it did not.
+func simulate(t *testing.T, h *host, d *daemon, until time.Time, windows ...[2]string) time.Time {
+	t.Helper()
+	at := func(s string) time.Time {
+		tod, err := timex.ParseTimeOfDay(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tod.On(h.boot)
+	}
+	for h.clock.Now().Before(until) {
+		h.clock.Advance(time.Minute)
+		h.update()
+		now := h.clock.Now()
+		active := false
+		for _, w := range windows {
+			if !now.Before(at(w[0])) && now.Before(at(w[1])) {
+				active = true
+			}
+		}
+		h.login(active)
+		ok := d.tick()
+		// the notifiers read the simulated host in the background
+		d.flush()
+		if ok {
+			return now
+		}
+	}
+	return time.Time{}
+}
+
+func TestDaemonDay(t *testing.T) {
+	// Monday, 5 January 2026, started by the cloud scheduler at 7:00
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 5, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		configure func(cfg *configuration.Configuration)
+		windows   [][2]string
+		inhibit   time.Duration // from 17:00
+		want      time.Time
+	}{
+		{
+			// the boot grace period covers samples until 7:09, then the idle
+			// clock runs for 30m timeout plus 5m grace
+			name: "nobody shows up",
+			want: at(7, 44),
+		},
+		{
+			// lunch is shorter than timeout and grace, so the warning is
+			// cancelled when the user comes back; the first idle sample after
+			// work is still counted as active by the hysteresis
+			name:    "working day",
+			windows: [][2]string{{"07:30", "12:00"}, {"12:30", "17:00"}},
+			want:    at(17, 35),
+		},
+		{
+			// the idle clock starts at the first idle sample, however many
+			// the hysteresis needs to confirm it
+			name: "slow hysteresis",
+			configure: func(cfg *configuration.Configuration) {
+				*cfg.IdleSamples = 5
+			},
+			windows: [][2]string{{"07:30", "17:00"}},
+			want:    at(17, 35),
+		},
+		{
+			name:    "long lunch",
+			windows: [][2]string{{"07:30", "12:00"}, {"13:00", "17:00"}},
+			want:    at(12, 35),
+		},
+		{
+			// without boot grace, the action is due at 7:36 but deferred
+			// until the system has been up for the minimum uptime
+			name: "minimum uptime",
+			configure: func(cfg *configuration.Configuration) {
+				*cfg.BootGrace = 0
+				*cfg.MinUptime = timex.Duration(time.Hour)
+			},
+			want: at(8, 0),
+		},
+		{
+			name: "curfew",
+			configure: func(cfg *configuration.Configuration) {
+				cfg.Schedule = &schedule.Schedule{TimeZone: "UTC", Curfew: &schedule.Curfew{At: timex.TimeOfDay(17*time.Hour + 30*time.Minute)}}
+				if err := cfg.Schedule.Validate(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			windows: [][2]string{{"07:30", "18:00"}},
+			want:    at(17, 30),
+		},
+		{
+			// a shorter idle timeout applies after working hours
+			name: "evening rule",
+			configure: func(cfg *configuration.Configuration) {
+				cfg.Schedule = &schedule.Schedule{TimeZone: "UTC", Rules: []schedule.Rule{
+					{Name: "evening", Window: schedule.Window{From: timex.TimeOfDay(17 * time.Hour), To: timex.TimeOfDay(7 * time.Hour)}, Timeout: pointer.To(timex.Duration(10 * time.Minute))},
+				}}
+				if err := cfg.Schedule.Validate(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			windows: [][2]string{{"07:30", "17:00"}},
+			want:    at(17, 15),
+		},
+		{
+			// the power action is deferred until the blackout ends
+			name: "blackout",
+			configure: func(cfg *configuration.Configuration) {
+				cfg.Schedule = &schedule.Schedule{TimeZone: "UTC", Blackouts: []schedule.Window{
+					{From: timex.TimeOfDay(17 * time.Hour), To: timex.TimeOfDay(18 * time.Hour)},
+				}}
+				if err := cfg.Schedule.Validate(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			windows: [][2]string{{"07:30", "17:00"}},
+			want:    at(18, 0),
+		},
+		{
+			name:    "inhibited after work",
+			windows: [][2]string{{"07:30", "17:00"}},
+			inhibit: 2 * time.Hour,
+			want:    at(19, 34),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHost(boot)
+			cfg := testConfiguration(t)
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			if tt.inhibit > 0 {
+				err := state.Update(*cfg.State, func(s *state.State) error {
+					s.Inhibits = append(s.Inhibits, state.Inhibit{Who: "developer", Since: at(17, 0), Until: at(17, 0).Add(tt.inhibit)})
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			d, err := newDaemon(cfg, h.clock, h.source())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := simulate(t, h, d, at(23, 59), tt.windows...); !got.Equal(tt.want) {
+				t.Errorf("expected the power action at %v, got %v", tt.want.Format("15:04"), got.Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestDaemonPostpone(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 5, hour, minute, 0, 0, time.UTC)
+	}
+	h := newHost(boot)
+	cfg := testConfiguration(t)
+	cfg.Actions = []power.Action{power.Hibernate, power.Suspend}
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.backend = &power.Fake{Unavailable: map[power.Action]error{power.Hibernate: power.ErrNotSupported}}
+
+	// the countdown is published once the warning phase is entered, with the
+	// action that will be taken
+	simulate(t, h, d, at(7, 35))
+	s, err := status.Read(*cfg.RunDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.State != idle.Warning || !s.Deadline.Equal(at(7, 44)) || s.Action != power.Suspend {
+		t.Errorf("expected a warning with deadline 07:44 to suspend, got %s %v %s", s.State, s.Deadline, s.Action)
+	}
+
+	// a user postpones the power action for more than allowed
+	if err := status.Postpone(*cfg.RunDir, 5*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	simulate(t, h, d, at(7, 40))
+	if s, _ := status.Read(*cfg.RunDir); s.State != idle.Active || !s.Deadline.IsZero() {
+		t.Errorf("expected the system to be active, got %s %v", s.State, s.Deadline)
+	}
+	// the inhibit recorded at 7:36 is capped, so the last active sample is at
+	// 11:35
+	if got, want := simulate(t, h, d, at(23, 59)), at(12, 10); !got.Equal(want) {
+		t.Errorf("expected the power action at %v, got %v", want.Format("15:04"), got.Format("15:04"))
+	}
+}
+
+func TestDaemonHooks(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 5, hour, minute, 0, 0, time.UTC)
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	// the hook fails the first time it runs
+	script := fmt.Sprintf("#!/bin/sh\necho \"$SLUMBERD_STAGE $SLUMBERD_IDLE $SLUMBERD_REASON\" >> %s\n[ -e %s.done ] && exit 0\ntouch %s.done\nexit 1\n", log, log, log)
+	for _, stage := range []string{"pre-action.d", "post-resume.d"} {
+		if err := os.Mkdir(filepath.Join(dir, stage), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, stage, "10-save"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := newHost(boot)
+	cfg := testConfiguration(t)
+	cfg.Hooks = &hooks.Hooks{
+		PreAction:  filepath.Join(dir, "pre-action.d"),
+		PostResume: filepath.Join(dir, "post-resume.d"),
+		Veto:       []string{"10-save"},
+	}
+	if err := cfg.Hooks.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the veto at 7:44 counts as activity
+	if got, want := simulate(t, h, d, at(23, 59)), at(8, 19); !got.Equal(want) {
+		t.Errorf("expected the power action at %v, got %v", want.Format("15:04"), got.Format("15:04"))
+	}
+
+	// the system is started again the next morning
+	h = newHost(time.Date(2026, 1, 6, 7, 0, 0, 0, time.UTC))
+	h.proc["sys/kernel/random/boot_id"] = &fstest.MapFile{Data: []byte("5f7e8a12-0d3c-4b8e-9f41-6a2c1d0e7b93\n")}
+	d, err = newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d.resumed {
+		t.Fatalf("expected the daemon to detect the resume")
+	}
+	d.resume()
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"pre-action 2100 no user activity since 07:09",
+		"pre-action 2100 no user activity since 07:44",
+		"post-resume 0 resumed after power action",
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(got, want) {
+		t.Errorf("expected hooks %q, got %q", want, got)
+	}
+}
+
+func TestDaemonResume(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	h := newHost(boot)
+	cfg := testConfiguration(t)
+
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the user works until 9:00, then the daemon is restarted at 9:20
+	if got := simulate(t, h, d, time.Date(2026, 1, 5, 9, 20, 0, 0, time.UTC), [2]string{"07:00", "09:00"}); !got.IsZero() {
+		t.Fatalf("unexpected power action at %v", got)
+	}
+	d, err = newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state := d.machine.State(); state != idle.Idle {
+		t.Errorf("expected the idle clock to resume in %v, got %v", idle.Idle, state)
+	}
+	want := time.Date(2026, 1, 5, 9, 35, 0, 0, time.UTC)
+	if got := simulate(t, h, d, time.Date(2026, 1, 5, 23, 59, 0, 0, time.UTC)); !got.Equal(want) {
+		t.Errorf("expected the power action at %v, got %v", want.Format("15:04"), got.Format("15:04"))
+	}
+}
+
+func TestDaemonRun(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	h := newHost(boot)
+	cfg := testConfiguration(t)
+	cfg.Actions = []power.Action{power.Hibernate, power.PowerOff}
+
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// hibernation is available, but fails
+	fake := &power.Fake{Failures: map[power.Action]error{power.Hibernate: power.ErrInhibited}}
+	d.backend = fake
+	// skip ahead to when the action is due, so that the first tick takes it
+	h.clock.Set(time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC))
+	h.update()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- d.run(nil, nil, nil)
+	}()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []power.Action{power.Hibernate, power.PowerOff}; !slices.Equal(fake.Taken(), want) {
+				t.Errorf("expected actions %v, got %v", want, fake.Taken())
+			}
+			return
+		case <-time.After(time.Millisecond):
+			// the ticker may not have been created yet
+			h.clock.Advance(time.Minute)
+		}
+	}
+}
+
+func TestDaemonActions(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 5, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name    string
+		actions []power.Action
+		fake    *power.Fake
+		taken   []power.Action
+		// announced is the action the pre-action hooks are told of
+		announced power.Action
+		// down is whether the daemon exits to let the system power off
+		down bool
+		// resumed is whether the post-resume hooks run
+		resumed bool
+	}{
+		{
+			name:      "power off",
+			actions:   []power.Action{power.PowerOff},
+			fake:      &power.Fake{},
+			taken:     []power.Action{power.PowerOff},
+			announced: power.PowerOff,
+			down:      true,
+		},
+		{
+			name:      "hibernation unavailable",
+			actions:   []power.Action{power.Hibernate, power.PowerOff},
+			fake:      &power.Fake{Unavailable: map[power.Action]error{power.Hibernate: power.ErrNotSupported}},
+			taken:     []power.Action{power.PowerOff},
+			announced: power.PowerOff,
+			down:      true,
+		},
+		{
+			name:      "suspend",
+			actions:   []power.Action{power.Suspend, power.PowerOff},
+			fake:      &power.Fake{},
+			taken:     []power.Action{power.Suspend},
+			announced: power.Suspend,
+			resumed:   true,
+		},
+		{
+			name:      "suspend fails",
+			actions:   []power.Action{power.Suspend, power.PowerOff},
+			fake:      &power.Fake{Failures: map[power.Action]error{power.Suspend: power.ErrInProgress}},
+			taken:     []power.Action{power.Suspend, power.PowerOff},
+			announced: power.Suspend,
+			down:      true,
+		},
+		{
+			name:      "nothing available",
+			actions:   []power.Action{power.PowerOff},
+			fake:      &power.Fake{Unavailable: map[power.Action]error{power.PowerOff: power.ErrNotPermitted}},
+			announced: power.PowerOff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			log := filepath.Join(dir, "log")
+			script := fmt.Sprintf("#!/bin/sh\necho \"$SLUMBERD_STAGE $SLUMBERD_ACTION $SLUMBERD_DOWN_SINCE\" >> %s\n", log)
+			for _, stage := range []string{"pre-action.d", "post-resume.d"} {
+				if err := os.Mkdir(filepath.Join(dir, stage), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, stage, "10-log"), []byte(script), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h := newHost(boot)
+			cfg := testConfiguration(t)
+			cfg.Actions = tt.actions
+			cfg.Hooks = &hooks.Hooks{PreAction: filepath.Join(dir, "pre-action.d"), PostResume: filepath.Join(dir, "post-resume.d")}
+			if err := cfg.Hooks.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			d, err := newDaemon(cfg, h.clock, h.source())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d.backend = tt.fake
+
+			if got := simulate(t, h, d, at(23, 59)); !got.Equal(at(7, 44)) {
+				t.Fatalf("expected the power action at 07:44, got %v", got.Format("15:04"))
+			}
+			if down := d.act(); down != tt.down {
+				t.Errorf("expected the system going down: %v, got %v", tt.down, down)
+			}
+			if !slices.Equal(tt.fake.Taken(), tt.taken) {
+				t.Errorf("expected actions %v, got %v", tt.taken, tt.fake.Taken())
+			}
+			data, _ := os.ReadFile(log)
+			if got, want := string(data), "pre-action "+string(tt.announced)+" \n"; got != want {
+				t.Errorf("expected pre-action hooks %q, got %q", want, got)
+			}
+			if tt.down {
+				return
+			}
+
+			// the system sleeps through the night, or goes on if nothing
+			// could be done
+			if tt.resumed {
+				h.clock.Advance(10 * time.Hour)
+			}
+			restarted := h.clock.Now()
+			if tt.resumed {
+				// the daemon notices on its first tick after resuming
+				restarted = restarted.Add(time.Minute)
+			}
+			if got, want := simulate(t, h, d, restarted.Add(2*time.Hour)), restarted.Add(35*time.Minute); !got.Equal(want) {
+				t.Errorf("expected the idle clock to start over at %v, got the power action at %v", restarted.Format("15:04"), got.Format("15:04"))
+			}
+			data, _ = os.ReadFile(log)
+			resumed := strings.Contains(string(data), "post-resume suspend "+at(7, 44).Format(time.RFC3339)+"\n")
+			if resumed != tt.resumed {
+				t.Errorf("expected post-resume hooks: %v, got %q", tt.resumed, data)
+			}
+		})
+	}
+}
+
+func TestDaemonCurfewAfterResume(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+	h := newHost(boot)
+	cfg := testConfiguration(t)
+	cfg.Actions = []power.Action{power.Suspend}
+	cfg.Schedule = &schedule.Schedule{TimeZone: "UTC", Curfew: &schedule.Curfew{At: timex.TimeOfDay(17*time.Hour + 30*time.Minute)}}
+	if err := cfg.Schedule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake := &power.Fake{}
+	d.backend = fake
+
+	if got := simulate(t, h, d, boot.Add(12*time.Hour), [2]string{"07:30", "18:00"}); !got.Equal(time.Date(2026, 1, 5, 17, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected the curfew at 17:30, got %v", got.Format("15:04"))
+	}
+	d.act()
+	// the system resumes the next morning, and is up since after the curfew
+	h.clock.Set(time.Date(2026, 1, 6, 8, 0, 0, 0, time.UTC))
+	if got, want := simulate(t, h, d, h.clock.Now().Add(2*time.Hour)), time.Date(2026, 1, 6, 8, 36, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected the power action at %v, got %v", want.Format("15:04"), got.Format("15:04"))
+	}
+}
+
+func TestDaemonDebounce(t *testing.T) {
+	h := newHost(time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC))
+	cfg := testConfiguration(t)
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	write := fsnotify.Event{Name: *cfg.Packages, Op: fsnotify.Write}
+	d.changed(write)
+	h.clock.Advance(300 * time.Millisecond)
+	d.changed(write)
+	d.changed(fsnotify.Event{Name: *cfg.Packages + ".swp", Op: fsnotify.Write})
+	if pending := h.clock.Pending(); pending != 1 {
+		t.Errorf("expected a single debounce timer, got %d", pending)
+	}
+	// the timer was reset by the second write
+	h.clock.Advance(300 * time.Millisecond)
+	if pending := h.clock.Pending(); pending != 1 {
+		t.Errorf("expected the debounce timer to be pending, got %d", pending)
+	}
+	h.clock.Advance(200 * time.Millisecond)
+	if pending := h.clock.Pending(); pending != 0 {
+		t.Errorf("expected the debounce timer to have fired, got %d pending", pending)
+	}
+}
+
+func TestDaemonWarnings(t *testing.T) {
+	countdown := []string{
+		"Mon Jan 5 07:34:00 2026):\r\n\r\nThis system will be suspended in 10 minutes (07:44 UTC): no user activity since 07:09.\r\nSave your work, or run '" + postponeCommand + "' to postpone.",
+		"Mon Jan 5 07:39:00 2026):\r\n\r\nThis system will be suspended in 5 minutes (07:44 UTC)",
+		"Mon Jan 5 07:43:00 2026):\r\n\r\nThis system will be suspended in 1 minute (07:44 UTC)",
+	}
+	events := []string{
+		"transition idle",
+		"transition warning",
+		"warning warning",
+		"transition grace",
+		"warning grace",
+		"warning grace",
+		"transition acting",
+	}
+
+	tests := []struct {
+		name      string
+		configure func(cfg *configuration.Configuration)
+		fake      *power.Fake
+		at        time.Time
+		banners   []string
+		events    []string
+	}{
+		{
+			name:    "suspend",
+			fake:    &power.Fake{},
+			at:      time.Date(2026, 1, 5, 7, 44, 0, 0, time.UTC),
+			banners: append(slices.Clone(countdown), "Mon Jan 5 07:44:00 2026):\r\n\r\nThis system is being suspended now: no user activity since 07:09.\r\n\r\n"),
+			events:  append(slices.Clone(events), "action acting"),
+		},
+		{
+			// users are told that the action failed, rather than taken
+			name:    "failure",
+			fake:    &power.Fake{Unavailable: map[power.Action]error{power.Suspend: power.ErrNotPermitted}},
+			at:      time.Date(2026, 1, 5, 7, 44, 0, 0, time.UTC),
+			banners: append(slices.Clone(countdown), "Mon Jan 5 07:44:00 2026):\r\n\r\nThis system could not be suspended: no power action could be taken through fake: cannot suspend: not permitted by polkit."),
+			events:  append(slices.Clone(events), "transition active", "failure active"),
+		},
+		{
+			// the countdown to the curfew starts while users are still active
+			name: "curfew",
+			configure: func(cfg *configuration.Configuration) {
+				*cfg.MinUptime = 0
+				cfg.Schedule = &schedule.Schedule{TimeZone: "UTC", Curfew: &schedule.Curfew{At: timex.TimeOfDay(7*time.Hour + 15*time.Minute)}}
+				if err := cfg.Schedule.Validate(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			fake: &power.Fake{},
+			at:   time.Date(2026, 1, 5, 7, 15, 0, 0, time.UTC),
+			banners: []string{
+				"Mon Jan 5 07:05:00 2026):\r\n\r\nThis system will be suspended in 10 minutes (07:15 UTC): curfew at 2026-01-05 07:15 UTC.\r\nSave your work, or run '" + postponeCommand + "' to postpone.",
+				"Mon Jan 5 07:10:00 2026):\r\n\r\nThis system will be suspended in 5 minutes (07:15 UTC)",
+				"Mon Jan 5 07:14:00 2026):\r\n\r\nThis system will be suspended in 1 minute (07:15 UTC)",
+				"Mon Jan 5 07:15:00 2026):\r\n\r\nThis system is being suspended now: curfew at 2026-01-05 07:15 UTC.",
+			},
+			events: []string{
+				"warning active",
+				"transition idle",
+				"warning idle",
+				"warning idle",
+				"transition acting",
+				"action acting",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boot := time.Date(2026, 1, 5, 7, 0, 0, 0, time.UTC)
+			h := newHost(boot)
+			// the user is logged in on a terminal, but nothing is going on
+			header := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+			h.proc["net/tcp"] = &fstest.MapFile{Data: []byte(header)}
+			h.proc["net/tcp6"] = &fstest.MapFile{Data: []byte(header)}
+			h.sessions["3"] = &fstest.MapFile{Data: fmt.Appendf(nil, "UID=%d\nUSER=developer\nSTATE=active\nCLASS=user\nTYPE=tty\nTTY=pts/0\n", os.Getuid())}
+
+			cfg := testConfiguration(t)
+			cfg.Detectors = []string{"ssh"}
+			cfg.Actions = []power.Action{power.Suspend}
+			if tt.configure != nil {
+				tt.configure(cfg)
+			}
+			if err := os.Mkdir(filepath.Join(*cfg.Dev, "pts"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			tty := filepath.Join(*cfg.Dev, "pts", "0")
+			if err := os.WriteFile(tty, nil, 0620); err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				lock  sync.Mutex
+				kinds []string
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var m struct {
+					Kind string `json:"kind"`
+					To   string `json:"to"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+					t.Errorf("invalid webhook payload: %v", err)
+				}
+				lock.Lock()
+				defer lock.Unlock()
+				kinds = append(kinds, m.Kind+" "+m.To)
+			}))
+			defer server.Close()
+			webhook := &notify.Webhook{URL: server.URL}
+			if err := webhook.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			cfg.Webhooks = []*notify.Webhook{webhook}
+
+			d, err := newDaemon(cfg, h.clock, h.source())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			d.backend = tt.fake
+			var got time.Time
+			for got.IsZero() {
+				h.clock.Advance(time.Minute)
+				h.update()
+				if d.tick() {
+					got = h.clock.Now()
+					d.act()
+				}
+			}
+			// the last active sample is the last one in the boot grace period
+			if !got.Equal(tt.at) {
+				t.Errorf("expected the power action at %v, got %v", tt.at.Format("15:04"), got.Format("15:04"))
+			}
+
+			d.flush()
+			data, err := os.ReadFile(tty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			banners := strings.Split(string(data), "Broadcast message from slumberd@")[1:]
+			if len(banners) != len(tt.banners) {
+				t.Fatalf("expected %d warnings, got %d: %q", len(tt.banners), len(banners), data)
+			}
+			for i, banner := range banners {
+				if !strings.Contains(banner, tt.banners[i]) {
+					t.Errorf("warning %d: expected %q in %q", i, tt.banners[i], banner)
+				}
+			}
+			if !slices.Equal(kinds, tt.events) {
+				t.Errorf("expected webhook events %q, got %q", tt.events, kinds)
+			}
+		})
+	}
+}
+
+func TestDaemonWake(t *testing.T) {
+	// Friday, 9 January 2026
+	boot := time.Date(2026, 1, 9, 7, 0, 0, 0, time.UTC)
+	h := newHost(boot)
+	cfg := testConfiguration(t)
+	cfg.Schedule = &schedule.Schedule{
+		TimeZone: "UTC",
+		WorkingHours: []schedule.Window{
+			{Days: timex.NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday), From: timex.TimeOfDay(8 * time.Hour), To: timex.TimeOfDay(18 * time.Hour)},
+		},
+	}
+	if err := cfg.Schedule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.WakeAlarm = pointer.To(filepath.Join(t.TempDir(), "wakealarm"))
+	cfg.WakeLead = pointer.To(timex.Duration(10 * time.Minute))
+	d, err := newDaemon(cfg, h.clock, h.source())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// after work on Friday, the system is woken up on Monday morning
+	if got := simulate(t, h, d, time.Date(2026, 1, 9, 23, 59, 0, 0, time.UTC), [2]string{"07:30", "17:00"}); got.IsZero() {
+		t.Fatal("expected the power action")
+	}
+	got, err := (&power.Alarm{Path: *cfg.WakeAlarm}).Get()
+	if want := time.Date(2026, 1, 12, 7, 50, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("expected the wake alarm at %v, got %v, %v", want, got, err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+const (
+	// exitActive is returned by the detect command when activity is detected.
+	exitActive = 0
+	// exitIdle is returned by the detect command when no activity is detected.
+	exitIdle = 2
+	// exitUndetermined is returned by the detect command when some detector
+	// failed and none detected activity; other errors exit with 1.
+	exitUndetermined = 3
+)
+
+// DetectCommand runs every configured detector once and reports what each
+// one found; the exit status is 0 if the system is active, 2 if it is idle,
+// 3 if it could not be determined and 1 on errors.
+type DetectCommand struct {
+	// Configuration is the configuration file for the daemon.
+	Configuration configuration.Configuration `short:"c" long:"configuration" description:"Configuration file" required:"true" default:"/home/developer/packages.yaml"`
+	// JSON prints the results as JSON instead of a table.
+	JSON bool `short:"j" long:"json" description:"Print results as JSON"`
+
+	// src replaces the sources of the configuration, for tests.
+	src *detect.Source
+}
+
+// Execute runs the detect command.
+func (cmd *DetectCommand) Execute(args []string) error {
+	detectors, err := detect.Lookup(cmd.Configuration.Detectors...)
+	if err != nil {
+		slog.Error("error looking up detectors", "error", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+
+	src := cmd.src
+	if src == nil {
+		src = source(&cmd.Configuration)
+	}
+	results := detect.Run(src, detectors)
+
+	if cmd.JSON {
+		data, err := json.MarshalIndent(struct {
+			Active  bool            `json:"active"`
+			Results []detect.Result `json:"results"`
+		}{
+			Active:  detect.IsActive(results),
+			Results: results,
+		}, "", "  ")
+		if err != nil {
+			slog.Error("failed to marshal results", "error", err)
+			fmt.Fprintf(os.Stderr, "failed to marshal results: %v\n", err)
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		printResults(os.Stdout, results)
+	}
+
+	switch {
+	case detect.IsActive(results):
+		return nil
+	case undetermined(results):
+		return exitCode(exitUndetermined)
+	default:
+		return exitCode(exitIdle)
+	}
+}
+
+// Description describes the detect command and its exit status in its help.
+func (cmd *DetectCommand) Description() string {
+	return "Runs every configured detector once and reports what each one found. " +
+		"The exit status is 0 if the system is active, 2 if it is idle, 3 if it could not be determined " +
+		"(some detectors failed and none detected activity) and 1 on errors."
+}
+
+// exitCode is an error carrying the exit status a command wants the process
+// to terminate with.
+type exitCode int
+
+// Error returns a description of the exit code.
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+// unreadable is a file system whose files cannot be read.
+type unreadable struct{}
+
+func (unreadable) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+}
+
+func TestDetectCommand(t *testing.T) {
+	const header = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+	const ssh = "   0: 0F02000A:0016 6433A8C0:E2F4 01 00000000:00000000 00:00000000 00000000     0        0 14468 1 0000000000000000 100 0 0 10 -1\n"
+
+	// the exit statuses are documented in the help of the command
+	tests := []struct {
+		name string
+		proc fs.FS
+		want int
+	}{
+		{
+			name: "active",
+			proc: fstest.MapFS{
+				"net/tcp":  {Data: []byte(header + ssh)},
+				"net/tcp6": {Data: []byte(header)},
+			},
+			want: 0,
+		},
+		{
+			name: "undetermined",
+			proc: unreadable{},
+			want: 3,
+		},
+		{
+			name: "idle",
+			proc: fstest.MapFS{
+				"net/tcp":  {Data: []byte(header)},
+				"net/tcp6": {Data: []byte(header)},
+			},
+			want: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &DetectCommand{
+				Configuration: configuration.Configuration{Detectors: []string{"ssh"}},
+				JSON:          true,
+				src: &detect.Source{
+					Proc:     &detect.Proc{FS: tt.proc, Sockets: detect.SocketsProcFS},
+					Sessions: fstest.MapFS{},
+				},
+			}
+			err := cmd.Execute(nil)
+			got := exitActive
+			var code exitCode
+			if errors.As(err, &code) {
+				got = int(code)
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected exit status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintResults(t *testing.T) {
+	active := detect.Result{Detector: "ssh", Verdict: detect.Active, Evidence: []string{"192.168.51.100:58100"}}
+	idle := detect.Result{Detector: "sessions", Verdict: detect.Idle}
+	failed := detect.Result{Detector: "ttys", Verdict: detect.Unknown, Error: fs.ErrPermission}
+
+	tests := []struct {
+		name    string
+		results []detect.Result
+		want    string
+	}{
+		{"active", []detect.Result{active, failed}, "the system is active"},
+		{"idle", []detect.Result{idle}, "the system is idle"},
+		{"undetermined", []detect.Result{idle, failed}, "it could not be determined whether the system is idle: some detectors failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			printResults(&b, tt.results)
+			if got := strings.TrimSpace(b.String()); !strings.HasSuffix(got, "\n"+tt.want) {
+				t.Errorf("expected verdict %q, got:\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/power"
+)
+
+// DoctorCommand checks which of the configured power actions can be taken,
+// and whether the system can really hibernate, and explains why not; the
+// exit status is 1 if no power action can be taken.
+type DoctorCommand struct {
+	// Configuration is the configuration file for the daemon.
+	Configuration configuration.Configuration `short:"c" long:"configuration" description:"Configuration file" required:"true" default:"/home/developer/packages.yaml"`
+	// JSON prints the report as JSON instead of text.
+	JSON bool `short:"j" long:"json" description:"Print report as JSON"`
+}
+
+// check is the availability of a power action.
+type check struct {
+	Action    power.Action `json:"action"`
+	Available bool         `json:"available"`
+	Reason    string       `json:"reason,omitempty"`
+}
+
+// report is the outcome of the doctor command.
+type report struct {
+	Backend     string             `json:"backend"`
+	Checks      []check            `json:"checks"`
+	Hibernation *power.Hibernation `json:"hibernation"`
+	// Action is the action that would be taken, if any.
+	Action power.Action `json:"action,omitempty"`
+}
+
+// Execute runs the doctor command.
+func (cmd *DoctorCommand) Execute(args []string) error {
+	b := backend(&cmd.Configuration, "")
+
+	r := report{Backend: b.Name(), Hibernation: power.NewKernel(*cmd.Configuration.Proc, *cmd.Configuration.Sys).Hibernation()}
+	for _, action := range cmd.Configuration.Actions {
+		c := check{Action: action, Available: true}
+		if err := b.Can(action); err != nil {
+			c.Available = false
+			c.Reason = err.Error()
+		} else if r.Action == "" {
+			r.Action = action
+		}
+		r.Checks = append(r.Checks, c)
+	}
+
+	if cmd.JSON {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			slog.Error("failed to marshal report", "error", err)
+			fmt.Fprintf(os.Stderr, "failed to marshal report: %v\n", err)
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		printReport(os.Stdout, r)
+	}
+
+	if r.Action == "" {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// printReport prints the power action checks as a table, followed by the
+// hibernation feasibility check and the action that would be taken.
+func printReport(w io.Writer, r report) {
+	fmt.Fprintf(w, "backend: %s\n\n", r.Backend)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tAVAILABLE\tREASON")
+	for _, c := range r.Checks {
+		reason := c.Reason
+		if reason == "" {
+			reason = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%t\t%s\n", c.Action, c.Available, reason)
+	}
+	tw.Flush()
+
+	h := r.Hibernation
+	fmt.Fprintln(w, "\nhibernation:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  memory\t%d MiB (%d MiB in use)\n", h.Memory>>20, h.Used>>20)
+	fmt.Fprintf(tw, "  swap\t%d MiB (%d MiB free)\n", h.Swap>>20, h.SwapFree>>20)
+	fmt.Fprintf(tw, "  states\t%s\n", orDash(strings.Join(h.States, " ")))
+	fmt.Fprintf(tw, "  mode\t%s\n", orDash(h.Mode))
+	fmt.Fprintf(tw, "  resume\t%s\n", orDash(h.Resume))
+	tw.Flush()
+	if h.Feasible() {
+		fmt.Fprintln(w, "  the system can hibernate")
+	}
+	for _, problem := range h.Problems {
+		fmt.Fprintf(w, "  - %s\n", problem)
+	}
+
+	if r.Action != "" {
+		fmt.Fprintf(w, "\npower action: %s\n", r.Action)
+	} else {
+		fmt.Fprintln(w, "\nno power action available")
+	}
+}
+
+// orDash returns the value, or "-" if it is empty.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
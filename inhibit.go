@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/state"
+	"github.com/dihedron/slumberd/timex"
+)
+
+// InhibitCommand records a request that no power action be taken for a
+// while; the daemon treats active inhibits as user activity.
+type InhibitCommand struct {
+	// State is the daemon state file the inhibit is recorded in.
+	State string `short:"s" long:"state" description:"Daemon state file" default:"/var/lib/slumberd/state.json"`
+	// Duration is how long the inhibit lasts.
+	Duration timex.Duration `short:"d" long:"duration" description:"How long to inhibit power actions" default:"1h"`
+	// Reason explains why power actions are inhibited.
+	Reason string `short:"r" long:"reason" description:"Why power actions are inhibited"`
+	// List lists the active inhibits instead of adding one.
+	List bool `short:"l" long:"list" description:"List active inhibits"`
+	// Clear removes all the inhibits instead of adding one.
+	Clear bool `long:"clear" description:"Remove all inhibits"`
+}
+
+// Execute runs the inhibit command.
+func (cmd *InhibitCommand) Execute(args []string) error {
+	if cmd.State == "" {
+		cmd.State = configuration.DefaultStateFile
+	}
+	now := time.Now()
+
+	if cmd.List {
+		s, err := state.Load(cmd.State)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return err
+		}
+		inhibits := s.ActiveInhibits(now)
+		if len(inhibits) == 0 {
+			fmt.Println("no active inhibits")
+		}
+		for _, inhibit := range inhibits {
+			fmt.Println(inhibit.String())
+		}
+		return nil
+	}
+
+	if cmd.Duration <= 0 {
+		err := errors.New("inhibit duration must be positive")
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+
+	inhibit := state.Inhibit{
+		Who:   username(),
+		Why:   cmd.Reason,
+		Since: now,
+		Until: now.Add(time.Duration(cmd.Duration)),
+	}
+	err := state.Update(cmd.State, func(s *state.State) error {
+		s.Prune(now)
+		if cmd.Clear {
+			s.Inhibits = nil
+			return nil
+		}
+		s.Inhibits = append(s.Inhibits, inhibit)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to update state file", "path", cmd.State, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to update state file: %v\n", err)
+		return err
+	}
+
+	if cmd.Clear {
+		fmt.Println("all inhibits removed")
+	} else {
+		fmt.Println(inhibit.String())
+	}
+	return nil
+}
+
+// username returns the name of the user running the command, looking
+// through sudo if needed.
+func username() string {
+	if name, ok := os.LookupEnv("SUDO_USER"); ok && name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -0,0 +1,326 @@
+// Package capture snapshots the inputs detectors read (process command
+// lines, network tables, logind sessions and the configuration) into a tar
+// archive, and reads such archives back as a detection source, so that what
+// the daemon saw on a host can be replayed elsewhere.
+package capture
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+	"github.com/dihedron/slumberd/metadata"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	manifestFile      = "manifest.yaml"
+	configurationFile = "configuration.yaml"
+	procDir           = "proc"
+	sessionsDir       = "sessions"
+)
+
+// redacted replaces sensitive values in redacted archives.
+const redacted = "REDACTED"
+
+// editorRegex matches the command lines that are kept verbatim in redacted
+// archives, because detectors need their arguments.
+var editorRegex = regexp.MustCompile(`vscode-server|code-server|cursor-server|windsurf-server|zed-remote-server|antigravity`)
+
+// Manifest describes when and where an archive was captured.
+type Manifest struct {
+	Time     time.Time `json:"time" yaml:"time"`
+	Host     string    `json:"host" yaml:"host"`
+	Version  string    `json:"version" yaml:"version"`
+	Redacted bool      `json:"redacted" yaml:"redacted"`
+}
+
+// Archive is a capture read back from a tar archive.
+type Archive struct {
+	Manifest      Manifest
+	Configuration configuration.Configuration
+	// Source reads the captured inputs; connections are always enumerated
+	// from the captured procfs tables.
+	Source *detect.Source
+}
+
+// Write snapshots the inputs in the source, along with the configuration,
+// into a tar archive written to w; if redact is true, the arguments of all
+// processes except editor servers, the remote hosts of sessions and the
+// remote addresses of connections are replaced with a placeholder; the
+// secrets in the configuration always are.
+func Write(w io.Writer, src *detect.Source, cfg *configuration.Configuration, redact bool) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	host, _ := os.Hostname()
+	manifest, err := yaml.Marshal(Manifest{
+		Time:     now,
+		Host:     host,
+		Version:  metadata.Version,
+		Redacted: redact,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := add(tw, manifestFile, manifest, now); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(withoutSecrets(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to marshal configuration: %w", err)
+	}
+	if err := add(tw, configurationFile, data, now); err != nil {
+		return err
+	}
+
+	// process command lines
+	pids, err := src.Proc.PIDs()
+	if err != nil {
+		return fmt.Errorf("failed to read processes: %w", err)
+	}
+	for _, pid := range pids {
+		cmdline, err := src.Proc.Cmdline(pid)
+		if err != nil {
+			// the process may have exited in the meantime
+			slog.Debug("skipping process", "pid", pid, "error", err)
+			continue
+		}
+		if redact {
+			cmdline = redactCmdline(cmdline)
+		}
+		if err := add(tw, path.Join(procDir, pid, "cmdline"), cmdline, now); err != nil {
+			return err
+		}
+	}
+
+	// network tables
+	for _, name := range []string{"net/tcp", "net/tcp6"} {
+		data, err := fs.ReadFile(src.Proc.FS, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if redact {
+			data = redactTCP(data)
+		}
+		if err := add(tw, path.Join(procDir, name), data, now); err != nil {
+			return err
+		}
+	}
+
+	// logind sessions
+	if src.Sessions != nil {
+		entries, err := fs.ReadDir(src.Sessions, ".")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read sessions: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+			data, err := fs.ReadFile(src.Sessions, entry.Name())
+			if err != nil {
+				continue
+			}
+			if redact {
+				data = redactSession(data)
+			}
+			if err := add(tw, path.Join(sessionsDir, entry.Name()), data, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tw.Close()
+}
+
+// Read reads back an archive written by Write.
+func Read(r io.Reader) (*Archive, error) {
+	fsys := fstest.MapFS{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+		}
+		fsys[path.Clean(header.Name)] = &fstest.MapFile{
+			Data:    data,
+			Mode:    0444,
+			ModTime: header.ModTime,
+		}
+	}
+
+	archive := &Archive{}
+	if file, ok := fsys[manifestFile]; ok {
+		if err := yaml.Unmarshal(file.Data, &archive.Manifest); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+		}
+	} else {
+		return nil, fmt.Errorf("not a capture archive: missing %s", manifestFile)
+	}
+	if file, ok := fsys[configurationFile]; ok {
+		if err := yaml.Unmarshal(file.Data, &archive.Configuration); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal configuration: %w", err)
+		}
+	}
+
+	proc, err := fs.Sub(fsys, procDir)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := fs.Sub(fsys, sessionsDir)
+	if err != nil {
+		return nil, err
+	}
+	archive.Source = &detect.Source{
+		Proc:     &detect.Proc{FS: proc, Sockets: detect.SocketsProcFS},
+		Sessions: sessions,
+	}
+	return archive, nil
+}
+
+func add(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// redactCmdline keeps the executable and replaces all arguments with a
+// placeholder, unless the command line belongs to an editor server.
+func redactCmdline(cmdline []byte) []byte {
+	if len(cmdline) == 0 || editorRegex.Match(cmdline) {
+		return cmdline
+	}
+	parts := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	for i := 1; i < len(parts); i++ {
+		parts[i] = []byte(redacted)
+	}
+	return append(bytes.Join(parts, []byte{0}), 0)
+}
+
+// redactSession replaces the remote host in a logind session file.
+func redactSession(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "REMOTE_HOST=") {
+			lines[i] = "REMOTE_HOST=" + redacted
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// redactTCP replaces the remote addresses in a /proc/net/tcp{,6} table with
+// the unspecified address and port, keeping the table parseable.
+func redactTCP(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		// the remote address follows the local one
+		at := strings.Index(line, fields[1]) + len(fields[1])
+		at += strings.Index(line[at:], fields[2])
+		unspecified := strings.Map(func(r rune) rune {
+			if r == ':' {
+				return r
+			}
+			return '0'
+		}, fields[2])
+		lines[i+1] = line[:at] + unspecified + line[at+len(fields[2]):]
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// withoutSecrets returns a copy of the configuration with a placeholder for
+// the secrets it may hold: the password of the SMTP relay, the headers of
+// webhooks, and the credentials, paths and queries of webhook and cloud
+// endpoint URLs, which often embed tokens.
+func withoutSecrets(cfg *configuration.Configuration) *configuration.Configuration {
+	c := *cfg
+	if c.Email != nil {
+		email := *c.Email
+		if email.Password != "" {
+			email.Password = redacted
+		}
+		c.Email = &email
+	}
+	c.Webhooks = nil
+	for _, w := range cfg.Webhooks {
+		webhook := *w
+		webhook.URL = redactURL(w.URL)
+		if len(w.Headers) > 0 {
+			webhook.Headers = map[string]string{}
+			for key := range w.Headers {
+				webhook.Headers[key] = redacted
+			}
+		}
+		c.Webhooks = append(c.Webhooks, &webhook)
+	}
+	if c.Cloud != nil {
+		cloud := *c.Cloud
+		if cloud.Endpoint != "" {
+			cloud.Endpoint = redactURL(cloud.Endpoint)
+		}
+		c.Cloud = &cloud
+	}
+	return &c
+}
+
+// redactURL keeps the scheme and host of a URL, replacing its user
+// information, path and query with a placeholder.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.Path != "" && u.Path != "/" {
+		u.Path = "/" + redacted
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	u.RawPath, u.Fragment = "", ""
+	return u.String()
+}
@@ -4,58 +4,50 @@ import (
 	"bytes"
 	"fmt"
 	"log/slog"
-	"os"
-	"path"
 	"path/filepath"
 	"regexp"
-	"strconv"
+	"slices"
 	"strings"
 )
 
-func IsAnyEditorActive2(procPath string) bool {
+var vscodeRegex = regexp.MustCompile(`(.*)\.vscode-server\/cli\/servers\/.*\/server\/out\/bootstrap-fork.*--type=(fileWatcher|extensionHost)`)
 
-	pattern := regexp.MustCompile(`(.*)\.vscode-server\/cli\/servers\/.*\/server\/out\/bootstrap-fork.*--type=(?:fileWatcher|extensionHost)`)
-
-	sshActive, err := HasActiveSSHConnections()
+// VSCodeServers returns a description of the VS Code server file watcher
+// and extension host processes running behind an active incoming SSH
+// connection; without SSH connections, editors are assumed inactive/hung.
+func (p *Proc) VSCodeServers() ([]string, error) {
+	sshActive, err := p.HasActiveSSHConnections()
 	if err != nil {
-		slog.Error("failed to check SSH connections", "error", err)
-		// If we can't check, we default to safety and assume it might be active
-		// if the process is found. Or should we be strict?
-		// Let's be strict as requested.
+		return nil, fmt.Errorf("failed to check SSH connections: %w", err)
 	}
 	if !sshActive {
 		slog.Debug("no active SSH connections, assuming editors are inactive/hung")
-		fmt.Println(" > no active SSH connections, assuming editors are inactive/hung...")
-		return false
+		return nil, nil
 	}
 
-	files, err := os.ReadDir(procPath)
+	pids, err := p.PIDs()
 	if err != nil {
-		slog.Error("failed to read /proc", "error", err)
-		fmt.Println(" > failed to read /proc, assuming editors are inactive/hung...")
-		return false
+		return nil, fmt.Errorf("failed to read /proc: %w", err)
 	}
 
-	for _, f := range files {
-		if !f.IsDir() || !isPID(f.Name()) {
-			continue
-		}
-
-		filename := path.Clean(filepath.Join(procPath, f.Name(), "cmdline"))
-		data, err := os.ReadFile(filename)
+	var found []string
+	for _, pid := range pids {
+		data, err := p.Cmdline(pid)
 		if err != nil {
 			continue
 		}
 
 		cmdline := strings.Replace(string(data), "\x00", " ", -1)
-		if pattern.MatchString(cmdline) {
-			slog.Debug("found active editor", "filename", filename, "cmdline", cmdline)
-			return true
+		if match := vscodeRegex.FindStringSubmatch(cmdline); match != nil {
+			slog.Debug("found active editor", "pid", pid, "cmdline", cmdline)
+			found = append(found, fmt.Sprintf("vscode-server %s (pid %s)", match[2], pid))
 		}
 	}
 
-	slog.Debug("no active editors found")
-	return false
+	if len(found) == 0 {
+		slog.Debug("no active editors found")
+	}
+	return found, nil
 }
 
 var (
@@ -63,37 +55,27 @@ var (
 	interpreterRegex = regexp.MustCompile(`^(node|python3?|sh|bash|perl|ruby)$`)
 )
 
-// IsAnyEditorActive checks if any of the target editor server components are running
-// AND there is an active incoming SSH connection.
-func IsAnyEditorActive(procPath string) []string {
-	sshActive, err := HasActiveSSHConnections()
+// RemoteEditors returns the names of the editor servers that are running
+// behind an active incoming SSH connection; without SSH connections, editors
+// are assumed inactive/hung.
+func (p *Proc) RemoteEditors() ([]string, error) {
+	sshActive, err := p.HasActiveSSHConnections()
 	if err != nil {
-		slog.Error("failed to check SSH connections", "error", err)
-		// If we can't check, we default to safety and assume it might be active
-		// if the process is found. Or should we be strict?
-		// Let's be strict as requested.
+		return nil, fmt.Errorf("failed to check SSH connections: %w", err)
 	}
 	if !sshActive {
 		slog.Debug("no active SSH connections, assuming editors are inactive/hung")
-		fmt.Println(" > no active SSH connections, assuming editors are inactive/hung...")
-		return nil
+		return nil, nil
 	}
 
-	files, err := os.ReadDir(procPath)
+	pids, err := p.PIDs()
 	if err != nil {
-		slog.Error("failed to read /proc", "error", err)
-		fmt.Println(" > failed to read /proc, assuming editors are inactive/hung...")
-		return nil
+		return nil, fmt.Errorf("failed to read /proc: %w", err)
 	}
 
 	found := make(map[string]struct{})
-	for _, f := range files {
-		if !f.IsDir() || !isPID(f.Name()) {
-			continue
-		}
-
-		cmdlinePath := filepath.Join(procPath, f.Name(), "cmdline")
-		data, err := os.ReadFile(path.Clean(cmdlinePath))
+	for _, pid := range pids {
+		data, err := p.Cmdline(pid)
 		if err != nil {
 			continue
 		}
@@ -126,20 +108,10 @@ func IsAnyEditorActive(procPath string) []string {
 		}
 	}
 
-	if len(found) > 0 {
-		var list []string
-		for k := range found {
-			list = append(list, k)
-		}
-		fmt.Printf(" > found active editors: %v\n", list)
-		return list
+	var list []string
+	for k := range found {
+		list = append(list, k)
 	}
-
-	fmt.Println(" > no active editors found...")
-	return nil
-}
-
-func isPID(name string) bool {
-	_, err := strconv.Atoi(name)
-	return err == nil
+	slices.Sort(list)
+	return list, nil
 }
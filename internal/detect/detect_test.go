@@ -1,49 +1,40 @@
 package detect
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
-func TestIsAnyEditorActive(t *testing.T) {
-	tempDir, err := os.MkdirTemp("", "proc_mock")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.RemoveAll(tempDir)
+const tcpHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retr   timeout inode\n"
 
-	// Create a dummy PID dir with an editor process
-	pidDir := filepath.Join(tempDir, "123")
-	if err := os.MkdirAll(pidDir, 0755); err != nil {
-		t.Fatal(err)
-	}
-	cmdline := "node\x00/home/user/.vscode-server/bin/some-id/out/server-main.js"
-	if err := os.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(cmdline), 0644); err != nil {
-		t.Fatal(err)
-	}
+const sshLine = "   0: 00000000:0016 00000000:0000 01 00000000:00000000 00:00000000 00000000     0        0 14467 1 0000000000000000 100 0 0 10 -1\n"
 
-	// Mock SSH connections (Empty by default)
-	netDir := filepath.Join(tempDir, "net")
-	if err := os.MkdirAll(netDir, 0755); err != nil {
-		t.Fatal(err)
+func TestRemoteEditors(t *testing.T) {
+	t.Parallel()
+
+	// Create a dummy PID dir with an editor process; mock SSH connections
+	// (empty by default)
+	proc := fstest.MapFS{
+		"123/cmdline": {Data: []byte("node\x00/home/user/.vscode-server/bin/some-id/out/server-main.js")},
+		"net/tcp":     {Data: []byte(tcpHeader)},
 	}
-	tcpFile := filepath.Join(netDir, "tcp")
-	os.WriteFile(tcpFile, []byte("  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retr   timeout inode\n"), 0644)
-	SetNetworkPaths(tcpFile, "/dev/null")
+	p := &Proc{FS: proc}
 
 	// Test negative (no SSH)
-	editors := IsAnyEditorActive(tempDir)
+	editors, _ := p.RemoteEditors()
 	if len(editors) > 0 {
 		t.Error("expected no active editors when no SSH connections")
 	}
 
 	// Mock active SSH connection
-	sshLine := "   0: 00000000:0016 00000000:0000 01 00000000:00000000 00:00000000 00000000     0        0 14467 1 0000000000000000 100 0 0 10 -1\n"
-	os.WriteFile(tcpFile, []byte("  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retr   timeout inode\n"+sshLine), 0644)
+	proc["net/tcp"] = &fstest.MapFile{Data: []byte(tcpHeader + sshLine)}
 
 	// Test positive (with SSH)
-	editors = IsAnyEditorActive(tempDir)
+	editors, _ = p.RemoteEditors()
 	if len(editors) == 0 {
 		t.Error("expected active editors when SSH connection is present")
 	}
@@ -52,31 +43,22 @@ func TestIsAnyEditorActive(t *testing.T) {
 	}
 
 	// Test false positive: flag value
-	cmdline = "myappl\x00--path=vscode-server"
-	if err := os.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(cmdline), 0644); err != nil {
-		t.Fatal(err)
-	}
-	editors = IsAnyEditorActive(tempDir)
+	proc["123/cmdline"] = &fstest.MapFile{Data: []byte("myappl\x00--path=vscode-server")}
+	editors, _ = p.RemoteEditors()
 	if len(editors) > 0 {
 		t.Error("expected no active editors for flag value")
 	}
 
 	// Test false positive: unrelated command arg
-	cmdline = "ls\x00vscode-server"
-	if err := os.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(cmdline), 0644); err != nil {
-		t.Fatal(err)
-	}
-	editors = IsAnyEditorActive(tempDir)
+	proc["123/cmdline"] = &fstest.MapFile{Data: []byte("ls\x00vscode-server")}
+	editors, _ = p.RemoteEditors()
 	if len(editors) > 0 {
 		t.Error("expected no active editors for unrelated command arg")
 	}
 
 	// Test positive: interpreter script
-	cmdline = "node\x00/usr/bin/vscode-server/server.js"
-	if err := os.WriteFile(filepath.Join(pidDir, "cmdline"), []byte(cmdline), 0644); err != nil {
-		t.Fatal(err)
-	}
-	editors = IsAnyEditorActive(tempDir)
+	proc["123/cmdline"] = &fstest.MapFile{Data: []byte("node\x00/usr/bin/vscode-server/server.js")}
+	editors, _ = p.RemoteEditors()
 	if len(editors) == 0 {
 		t.Error("expected active editors for node script")
 	}
@@ -84,3 +66,221 @@ func TestIsAnyEditorActive(t *testing.T) {
 		t.Errorf("expected [vscode-server], got %v", editors)
 	}
 }
+
+func TestVSCodeServers(t *testing.T) {
+	t.Parallel()
+
+	bootstrap := "/usr/bin/node\x00/home/user/.vscode-server/cli/servers/Stable-abc/server/out/bootstrap-fork\x00--type=extensionHost"
+	tests := []struct {
+		name string
+		proc fstest.MapFS
+		want bool
+	}{
+		{
+			name: "extension host with SSH",
+			proc: fstest.MapFS{
+				"42/cmdline": {Data: []byte(bootstrap)},
+				"net/tcp":    {Data: []byte(tcpHeader + sshLine)},
+			},
+			want: true,
+		},
+		{
+			name: "extension host without SSH",
+			proc: fstest.MapFS{
+				"42/cmdline": {Data: []byte(bootstrap)},
+				"net/tcp":    {Data: []byte(tcpHeader)},
+			},
+			want: false,
+		},
+		{
+			name: "SSH over IPv6 only",
+			proc: fstest.MapFS{
+				"42/cmdline": {Data: []byte(bootstrap)},
+				"net/tcp6":   {Data: []byte(tcpHeader + "   0: 00000000000000000000000001000000:0016 00000000000000000000000001000000:C350 01 00000000:00000000 00:00000000 00000000     0        0 14467 1 0000000000000000 100 0 0 10 -1\n")},
+			},
+			want: true,
+		},
+		{
+			name: "no editor",
+			proc: fstest.MapFS{
+				"42/cmdline": {Data: []byte("/usr/sbin/sshd\x00-D")},
+				"net/tcp":    {Data: []byte(tcpHeader + sshLine)},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := &Proc{FS: tt.proc}
+			servers, err := p.VSCodeServers()
+			if err != nil {
+				t.Fatalf("expected nil, got %v", err)
+			}
+			if got := len(servers) > 0; got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHasActiveProcess(t *testing.T) {
+	t.Parallel()
+
+	p := &Proc{FS: fstest.MapFS{
+		"1/cmdline":    {Data: []byte("/sbin/init\x00splash")},
+		"1234/cmdline": {Data: []byte("/usr/bin/dockerd\x00-H\x00fd://")},
+		"self/cmdline": {Data: []byte("/usr/bin/zed")},
+	}}
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{`dockerd -H`, true},
+		{`^/sbin/init splash$`, true},
+		{`zed`, false},
+	}
+	for _, tt := range tests {
+		got, err := p.HasActiveProcess(tt.pattern)
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("pattern %q: expected %v, got %v", tt.pattern, tt.want, got)
+		}
+	}
+
+	if _, err := p.HasActiveProcess(`(`); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+
+	sessions, err := Sessions(fstest.MapFS{
+		"1":     {Data: []byte("UID=1000\nUSER=developer\nACTIVE=1\nSTATE=active\nREMOTE=1\nTYPE=tty\nCLASS=user\nTTY=pts/0\nREMOTE_HOST=10.0.0.1\nSERVICE=sshd\n")},
+		"1.ref": {Data: []byte{}},
+		"c2":    {Data: []byte("UID=1001\nUSER=other\nSTATE=closing\nCLASS=user\n")},
+		"c3":    {Data: []byte("UID=120\nUSER=gdm\nSTATE=online\nCLASS=greeter\n")},
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %v", sessions)
+	}
+	want := []bool{true, false, false}
+	for i, s := range sessions {
+		if s.IsActive() != want[i] {
+			t.Errorf("session %s: expected active %v, got %v", s.ID, want[i], s.IsActive())
+		}
+	}
+	if s := sessions[0].String(); s != "session 1: developer on pts/0 from 10.0.0.1 (sshd)" {
+		t.Errorf("unexpected description %q", s)
+	}
+
+	// a missing sessions directory is not an error
+	if sessions, err := Sessions(os.DirFS(filepath.Join(t.TempDir(), "sessions"))); err != nil || sessions != nil {
+		t.Errorf("expected no sessions and no error, got %v, %v", sessions, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	detectors, err := Lookup("ssh", "vscode", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Lookup("ssh", "nonexistent"); err == nil {
+		t.Error("expected error for unknown detector")
+	}
+
+	tests := []struct {
+		name   string
+		src    *Source
+		want   []Verdict
+		active bool
+	}{
+		{
+			name: "ssh only",
+			src: &Source{
+				Proc: &Proc{FS: fstest.MapFS{"net/tcp": {Data: []byte(tcpHeader + sshLine)}}},
+			},
+			want:   []Verdict{Active, Idle, Idle},
+			active: true,
+		},
+		{
+			name: "unreadable proc",
+			src: &Source{
+				Proc:     &Proc{FS: unreadableFS{fstest.MapFS{"net/tcp": {Data: []byte(tcpHeader + sshLine)}}}},
+				Sessions: fstest.MapFS{"1": {Data: []byte("USER=developer\nSTATE=active\nCLASS=user\n")}},
+			},
+			want:   []Verdict{Active, Unknown, Active},
+			active: true,
+		},
+		{
+			name: "nothing",
+			src: &Source{
+				Proc: &Proc{FS: fstest.MapFS{}},
+			},
+			want:   []Verdict{Idle, Idle, Idle},
+			active: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			results := Run(tt.src, detectors)
+			for i, result := range results {
+				if result.Verdict != tt.want[i] {
+					t.Errorf("%s: expected %v, got %v (%v)", result.Detector, tt.want[i], result.Verdict, result.Error)
+				}
+			}
+			if IsActive(results) != tt.active {
+				t.Errorf("expected active %v, got %v", tt.active, IsActive(results))
+			}
+		})
+	}
+}
+
+func TestBootInfo(t *testing.T) {
+	t.Parallel()
+
+	p := &Proc{FS: fstest.MapFS{
+		"sys/kernel/random/boot_id": {Data: []byte("33ff8457-fecc-4b74-b78f-0fa94d5b70e2\n")},
+		"stat":                      {Data: []byte("cpu  1 2 3 4\nintr 123\nbtime 1792429756\nprocesses 42\n")},
+		"uptime":                    {Data: []byte("715.10 566.37\n")},
+	}}
+
+	id, err := p.BootID()
+	if err != nil || id != "33ff8457-fecc-4b74-b78f-0fa94d5b70e2" {
+		t.Errorf("unexpected boot id %q (%v)", id, err)
+	}
+	boot, err := p.BootTime()
+	if err != nil || !boot.Equal(time.Unix(1792429756, 0)) {
+		t.Errorf("unexpected boot time %v (%v)", boot, err)
+	}
+	uptime, err := p.Uptime()
+	if err != nil || uptime != 715*time.Second+100*time.Millisecond {
+		t.Errorf("unexpected uptime %v (%v)", uptime, err)
+	}
+
+	empty := &Proc{FS: fstest.MapFS{"stat": {Data: []byte("cpu  1 2 3 4\n")}}}
+	if _, err := empty.BootTime(); err == nil {
+		t.Error("expected error for missing boot time")
+	}
+	if _, err := empty.Uptime(); err == nil {
+		t.Error("expected error for missing uptime")
+	}
+}
+
+// unreadableFS is a filesystem whose directories cannot be listed.
+type unreadableFS struct {
+	fstest.MapFS
+}
+
+func (unreadableFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+}
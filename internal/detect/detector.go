@@ -0,0 +1,238 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// Source is the set of host views detectors read their inputs from.
+type Source struct {
+	// Proc is the procfs view, used for processes and network connections.
+	Proc *Proc
+	// Sessions is the logind sessions directory, e.g. a filesystem rooted at
+	// /run/systemd/sessions.
+	Sessions fs.FS
+	// Clock times detector runs; the real clock is used if nil.
+	Clock timex.Clock
+}
+
+// clock returns the clock of the source.
+func (src *Source) clock() timex.Clock {
+	if src.Clock == nil {
+		return timex.RealClock
+	}
+	return src.Clock
+}
+
+// Verdict is the outcome of running a detector.
+type Verdict int
+
+const (
+	// Idle means that the detector found no activity.
+	Idle Verdict = iota
+	// Active means that the detector found activity.
+	Active
+	// Unknown means that the detector failed and could not tell.
+	Unknown
+)
+
+// String returns the string representation of the Verdict value.
+func (v Verdict) String() string {
+	switch v {
+	case Idle:
+		return "idle"
+	case Active:
+		return "active"
+	case Unknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("Verdict(%d)", int(v))
+}
+
+// MarshalText marshals the Verdict value into a text string.
+func (v Verdict) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// Detector looks for one kind of user activity.
+type Detector interface {
+	// Name returns the name the detector is referenced by in the configuration.
+	Name() string
+	// Detect returns the evidence of activity found in the source, if any; an
+	// error means that the detector could not tell.
+	Detect(src *Source) ([]string, error)
+}
+
+// Result is the outcome of running a detector against a source.
+type Result struct {
+	Detector string
+	Verdict  Verdict
+	Evidence []string
+	Duration time.Duration
+	Error    error
+}
+
+// MarshalJSON marshals the Result into a JSON object, with the duration and
+// the error, if any, as strings.
+func (r Result) MarshalJSON() ([]byte, error) {
+	var e string
+	if r.Error != nil {
+		e = r.Error.Error()
+	}
+	return json.Marshal(struct {
+		Detector string   `json:"detector"`
+		Verdict  Verdict  `json:"verdict"`
+		Evidence []string `json:"evidence,omitempty"`
+		Duration string   `json:"duration"`
+		Error    string   `json:"error,omitempty"`
+	}{
+		Detector: r.Detector,
+		Verdict:  r.Verdict,
+		Evidence: r.Evidence,
+		Duration: r.Duration.String(),
+		Error:    e,
+	})
+}
+
+// Run runs the detectors against the source, one after the other.
+func Run(src *Source, detectors []Detector) []Result {
+	results := make([]Result, 0, len(detectors))
+	clock := src.clock()
+	for _, detector := range detectors {
+		start := clock.Now()
+		evidence, err := detector.Detect(src)
+		result := Result{
+			Detector: detector.Name(),
+			Verdict:  Idle,
+			Evidence: evidence,
+			Duration: clock.Since(start),
+			Error:    err,
+		}
+		switch {
+		case err != nil:
+			slog.Error("detector failed", "detector", detector.Name(), "error", err)
+			result.Verdict = Unknown
+		case len(evidence) > 0:
+			result.Verdict = Active
+		}
+		slog.Debug("detector run", "detector", result.Detector, "verdict", result.Verdict, "evidence", result.Evidence, "duration", result.Duration)
+		results = append(results, result)
+	}
+	return results
+}
+
+// IsActive returns whether any of the results reports activity.
+func IsActive(results []Result) bool {
+	for _, result := range results {
+		if result.Verdict == Active {
+			return true
+		}
+	}
+	return false
+}
+
+// Aggregate combines the results into a single verdict: the system is
+// active if any detector found activity, unknown if no detector found
+// activity but some failed, and idle otherwise.
+func Aggregate(results []Result) Verdict {
+	verdict := Idle
+	for _, result := range results {
+		switch result.Verdict {
+		case Active:
+			return Active
+		case Unknown:
+			verdict = Unknown
+		}
+	}
+	return verdict
+}
+
+// Evidence returns the evidence found by the detectors that reported
+// activity, each prefixed by the name of the detector.
+func Evidence(results []Result) []string {
+	var evidence []string
+	for _, result := range results {
+		if result.Verdict != Active {
+			continue
+		}
+		for _, e := range result.Evidence {
+			evidence = append(evidence, result.Detector+": "+e)
+		}
+	}
+	return evidence
+}
+
+// detectors is the set of available detectors, by name.
+var detectors = map[string]Detector{
+	"vscode":   detector{"vscode", func(src *Source) ([]string, error) { return src.Proc.VSCodeServers() }},
+	"editors":  detector{"editors", func(src *Source) ([]string, error) { return src.Proc.RemoteEditors() }},
+	"ssh":      detector{"ssh", sshConnections},
+	"sessions": detector{"sessions", userSessions},
+}
+
+// Names returns the names of the available detectors, sorted.
+func Names() []string {
+	return slices.Sorted(maps.Keys(detectors))
+}
+
+// Lookup returns the detectors with the given names.
+func Lookup(names ...string) ([]Detector, error) {
+	result := make([]Detector, 0, len(names))
+	for _, name := range names {
+		d, ok := detectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q (available: %v)", name, Names())
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// detector adapts a function into a Detector.
+type detector struct {
+	name   string
+	detect func(src *Source) ([]string, error)
+}
+
+// Name returns the name of the detector.
+func (d detector) Name() string {
+	return d.name
+}
+
+// Detect runs the detector function.
+func (d detector) Detect(src *Source) ([]string, error) {
+	return d.detect(src)
+}
+
+func sshConnections(src *Source) ([]string, error) {
+	connections, err := src.Proc.EstablishedConnections(sshPort)
+	if err != nil {
+		return nil, err
+	}
+	var evidence []string
+	for _, c := range connections {
+		evidence = append(evidence, fmt.Sprintf("ssh connection from port %d", c.RemotePort))
+	}
+	return evidence, nil
+}
+
+func userSessions(src *Source) ([]string, error) {
+	sessions, err := Sessions(src.Sessions)
+	if err != nil {
+		return nil, err
+	}
+	var evidence []string
+	for _, s := range sessions {
+		if s.IsActive() {
+			evidence = append(evidence, s.String())
+		}
+	}
+	return evidence, nil
+}
@@ -0,0 +1,139 @@
+//go:build linux
+
+package detect
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// constants from linux/sock_diag.h and linux/inet_diag.h, which are not
+// exported by the syscall package.
+const (
+	sockDiagByFamily    = 20
+	inetDiagReqBytecode = 1
+	inetDiagBcSGE       = 2
+	inetDiagBcSLE       = 3
+	tcpEstablished      = 1
+	// sizeofInetDiagReqV2 is the size of struct inet_diag_req_v2.
+	sizeofInetDiagReqV2 = 56
+	// sizeofInetDiagMsg is the size of struct inet_diag_msg.
+	sizeofInetDiagMsg = 72
+)
+
+// netlinkConnections asks the kernel, via NETLINK_SOCK_DIAG, for the
+// established TCP sockets whose local port is the given one; the filtering
+// happens in the kernel, so only matching sockets are ever transferred.
+func netlinkConnections(port int) ([]ConnectionInfo, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to bind netlink socket: %w", err)
+	}
+
+	var connections []ConnectionInfo
+	for i, family := range []uint8{syscall.AF_INET, syscall.AF_INET6} {
+		found, err := netlinkDump(fd, uint32(i+1), family, port)
+		if err != nil {
+			return nil, err
+		}
+		connections = append(connections, found...)
+	}
+	return connections, nil
+}
+
+func netlinkDump(fd int, seq uint32, family uint8, port int) ([]ConnectionInfo, error) {
+	request := inetDiagRequest(seq, family, port)
+	if err := syscall.Sendto(fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("failed to send sock_diag request: %w", err)
+	}
+
+	var connections []ConnectionInfo
+	buffer := make([]byte, 8*os.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive sock_diag response: %w", err)
+		}
+		messages, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sock_diag response: %w", err)
+		}
+		for _, message := range messages {
+			if message.Header.Seq != seq {
+				continue
+			}
+			switch message.Header.Type {
+			case syscall.NLMSG_DONE:
+				return connections, nil
+			case syscall.NLMSG_ERROR:
+				if len(message.Data) < 4 {
+					return nil, fmt.Errorf("truncated netlink error message")
+				}
+				errno := -int32(binary.NativeEndian.Uint32(message.Data[:4]))
+				if errno == 0 {
+					continue
+				}
+				return nil, fmt.Errorf("sock_diag request failed: %w", syscall.Errno(errno))
+			case sockDiagByFamily:
+				if len(message.Data) < sizeofInetDiagMsg {
+					continue
+				}
+				// struct inet_diag_msg: family, state, timer, retrans, then
+				// struct inet_diag_sockid whose ports are in network order
+				connections = append(connections, ConnectionInfo{
+					State:      fmt.Sprintf("%02X", message.Data[1]),
+					LocalPort:  int(binary.BigEndian.Uint16(message.Data[4:6])),
+					RemotePort: int(binary.BigEndian.Uint16(message.Data[6:8])),
+				})
+			}
+		}
+	}
+}
+
+// inetDiagRequest builds a SOCK_DIAG_BY_FAMILY dump request for established
+// TCP sockets of the given family, with a bytecode filter that only accepts
+// sockets whose local port is the given one (sport >= port && sport <= port).
+func inetDiagRequest(seq uint32, family uint8, port int) []byte {
+	// each struct inet_diag_bc_op is {code u8, yes u8, no u16}, followed by
+	// a second op carrying the port in its "no" field for port comparisons;
+	// jumping past the end of the program (len + 4) rejects the socket
+	bytecode := make([]byte, 16)
+	bytecode[0] = inetDiagBcSGE
+	bytecode[1] = 8
+	binary.NativeEndian.PutUint16(bytecode[2:], 20)
+	binary.NativeEndian.PutUint16(bytecode[6:], uint16(port))
+	bytecode[8] = inetDiagBcSLE
+	bytecode[9] = 8
+	binary.NativeEndian.PutUint16(bytecode[10:], 12)
+	binary.NativeEndian.PutUint16(bytecode[14:], uint16(port))
+
+	length := syscall.NLMSG_HDRLEN + sizeofInetDiagReqV2 + syscall.SizeofRtAttr + len(bytecode)
+	b := make([]byte, length)
+
+	// struct nlmsghdr
+	binary.NativeEndian.PutUint32(b[0:], uint32(length))
+	binary.NativeEndian.PutUint16(b[4:], sockDiagByFamily)
+	binary.NativeEndian.PutUint16(b[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(b[8:], seq)
+
+	// struct inet_diag_req_v2 (the socket id is left zeroed)
+	r := b[syscall.NLMSG_HDRLEN:]
+	r[0] = family
+	r[1] = syscall.IPPROTO_TCP
+	binary.NativeEndian.PutUint32(r[4:], 1<<tcpEstablished)
+
+	// INET_DIAG_REQ_BYTECODE attribute
+	a := r[sizeofInetDiagReqV2:]
+	binary.NativeEndian.PutUint16(a[0:], uint16(syscall.SizeofRtAttr+len(bytecode)))
+	binary.NativeEndian.PutUint16(a[2:], inetDiagReqBytecode)
+	copy(a[syscall.SizeofRtAttr:], bytecode)
+
+	return b
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package detect
+
+import "errors"
+
+// netlinkConnections is only available on Linux; elsewhere the caller falls
+// back to parsing the procfs network tables.
+func netlinkConnections(port int) ([]ConnectionInfo, error) {
+	return nil, errors.New("netlink sockets are only supported on linux")
+}
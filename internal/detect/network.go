@@ -2,46 +2,73 @@ package detect
 
 import (
 	"bufio"
+	"errors"
+	"io/fs"
 	"log/slog"
-	"os"
-	"path"
+	"strconv"
 	"strings"
 )
 
-var (
-	tcpPath  = "/proc/net/tcp"
-	tcp6Path = "/proc/net/tcp6"
+const (
+	// SocketsProcFS enumerates connections by parsing /proc/net/tcp{,6}.
+	SocketsProcFS = "procfs"
+	// SocketsNetlink enumerates connections through NETLINK_SOCK_DIAG, with
+	// the kernel filtering on state and local port; it falls back to procfs
+	// whenever the netlink query fails.
+	SocketsNetlink = "netlink"
 )
 
-// SetNetworkPaths allows overriding the paths to network proc files for testing.
-func SetNetworkPaths(tcp, tcp6 string) {
-	tcpPath = tcp
-	tcp6Path = tcp6
-}
+// stateEstablished is the TCP_ESTABLISHED state, as reported in the "st"
+// column of /proc/net/tcp.
+const stateEstablished = "01"
+
+// sshPort is the local port incoming SSH connections are accepted on.
+const sshPort = 22
 
 // HasActiveSSHConnections checks if there are any established incoming SSH connections.
-func HasActiveSSHConnections() (bool, error) {
-	active, err := checkTCP(tcpPath)
+func (p *Proc) HasActiveSSHConnections() (bool, error) {
+	connections, err := p.EstablishedConnections(sshPort)
 	if err != nil {
 		return false, err
 	}
-	if active {
-		return true, nil
+	for _, c := range connections {
+		slog.Debug("active SSH connection found", "local", c.LocalPort, "remote", c.RemotePort)
 	}
+	return len(connections) > 0, nil
+}
 
-	return checkTCP(tcp6Path)
+// EstablishedConnections returns the established TCP connections (both IPv4
+// and IPv6) whose local port is the given one, using the configured backend.
+func (p *Proc) EstablishedConnections(port int) ([]ConnectionInfo, error) {
+	if p.Sockets == SocketsNetlink {
+		connections, err := netlinkConnections(port)
+		if err == nil {
+			return connections, nil
+		}
+		slog.Warn("failed to query sockets via netlink, falling back to procfs", "error", err)
+	}
+	connections, err := checkTCP(p.FS, "net/tcp", port)
+	if err != nil {
+		return nil, err
+	}
+	more, err := checkTCP(p.FS, "net/tcp6", port)
+	if err != nil {
+		return nil, err
+	}
+	return append(connections, more...), nil
 }
 
-func checkTCP(filename string) (bool, error) {
-	file, err := os.Open(path.Clean(filename))
+func checkTCP(fsys fs.FS, name string, port int) ([]ConnectionInfo, error) {
+	file, err := fsys.Open(name)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return false, nil
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
 		}
-		return false, err
+		return nil, err
 	}
 	defer file.Close()
 
+	var connections []ConnectionInfo
 	scanner := bufio.NewScanner(file)
 	// Skip header
 	if scanner.Scan() {
@@ -55,23 +82,39 @@ func checkTCP(filename string) (bool, error) {
 			// Local address is field index 1
 			// Remote address is field index 2
 			// State is field index 3
-			localAddr := fields[1]
 			state := fields[3]
-
-			// State "01" is ESTABLISHED
-			if state != "01" {
+			if state != stateEstablished {
 				continue
 			}
 
-			// Check if local port is 22
-			if strings.HasSuffix(localAddr, ":0016") {
-				slog.Debug("active SSH connection found", "local", localAddr, "remote", fields[2])
-				return true, nil
+			local, ok := parsePort(fields[1])
+			if !ok || local != port {
+				continue
 			}
+			remote, _ := parsePort(fields[2])
+			connections = append(connections, ConnectionInfo{
+				State:      state,
+				LocalPort:  local,
+				RemotePort: remote,
+			})
 		}
 	}
 
-	return false, scanner.Err()
+	return connections, scanner.Err()
+}
+
+// parsePort extracts the port from an "ADDRESS:PORT" pair as found in
+// /proc/net/tcp, where the port is in hexadecimal notation.
+func parsePort(address string) (int, bool) {
+	i := strings.LastIndexByte(address, ':')
+	if i < 0 {
+		return 0, false
+	}
+	port, err := strconv.ParseUint(address[i+1:], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return int(port), true
 }
 
 // ConnectionInfo holds the basic details we need to verify an SSH connection
@@ -0,0 +1,81 @@
+package detect
+
+import (
+	"net"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckTCP(t *testing.T) {
+	t.Parallel()
+
+	table := tcpHeader +
+		"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 14467 1 0000000000000000 100 0 0 10 -1\n" +
+		"   1: 0100007F:0016 0100007F:D431 01 00000000:00000000 00:00000000 00000000     0        0 14468 1 0000000000000000 100 0 0 10 -1\n" +
+		"   2: 0100007F:1F90 0100007F:D432 01 00000000:00000000 00:00000000 00000000     0        0 14469 1 0000000000000000 100 0 0 10 -1\n"
+	proc := fstest.MapFS{
+		"net/tcp": {Data: []byte(table)},
+	}
+
+	tests := []struct {
+		port int
+		want []ConnectionInfo
+	}{
+		{22, []ConnectionInfo{{State: "01", LocalPort: 22, RemotePort: 0xD431}}},
+		{8080, []ConnectionInfo{{State: "01", LocalPort: 8080, RemotePort: 0xD432}}},
+		{443, nil},
+	}
+	for _, tt := range tests {
+		got, err := checkTCP(proc, "net/tcp", tt.port)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("expected %v, got %v", tt.want, got)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("expected %v, got %v", tt.want[i], got[i])
+			}
+		}
+	}
+
+	// a missing table (e.g. no IPv6 support) is not an error
+	if got, err := checkTCP(proc, "net/tcp6", 22); err != nil || got != nil {
+		t.Errorf("expected no connections and no error, got %v, %v", got, err)
+	}
+}
+
+func TestNetlinkConnections(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if c, err := listener.Accept(); err == nil {
+			defer c.Close()
+			c.Read(make([]byte, 1))
+		}
+	}()
+	client, err := net.Dial("tcp4", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	connections, err := netlinkConnections(port)
+	if err != nil {
+		t.Skipf("netlink sock_diag not available: %v", err)
+	}
+	if len(connections) != 1 {
+		t.Fatalf("expected exactly one connection on port %d, got %v", port, connections)
+	}
+	if connections[0].LocalPort != port || connections[0].State != stateEstablished {
+		t.Errorf("unexpected connection %v", connections[0])
+	}
+	if connections[0].RemotePort != client.LocalAddr().(*net.TCPAddr).Port {
+		t.Errorf("expected remote port %d, got %d", client.LocalAddr().(*net.TCPAddr).Port, connections[0].RemotePort)
+	}
+}
@@ -0,0 +1,105 @@
+package detect
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proc is the source detectors read process and network information from; it
+// wraps a filesystem rooted at the equivalent of /proc, so that the daemon can
+// be pointed at the host procfs when running inside a container (e.g. one
+// mounted at /host/proc) and tests can use fstest.MapFS fixtures. Proc values
+// hold no shared state and can be used concurrently.
+type Proc struct {
+	// FS is the procfs hierarchy, e.g. os.DirFS("/proc").
+	FS fs.FS
+	// Sockets selects how TCP connections are enumerated, either SocketsProcFS
+	// (the default when empty) or SocketsNetlink; note that netlink queries the
+	// network namespace the daemon runs in, regardless of FS.
+	Sockets string
+}
+
+// NewProc returns a Proc reading from the procfs mounted at the given path,
+// and enumerating connections through the given sockets backend.
+func NewProc(root string, sockets string) *Proc {
+	return &Proc{
+		FS:      os.DirFS(root),
+		Sockets: sockets,
+	}
+}
+
+// PIDs returns the names of the per-process directories in the procfs.
+func (p *Proc) PIDs() ([]string, error) {
+	entries, err := fs.ReadDir(p.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	var pids []string
+	for _, entry := range entries {
+		if entry.IsDir() && isPID(entry.Name()) {
+			pids = append(pids, entry.Name())
+		}
+	}
+	return pids, nil
+}
+
+// Cmdline returns the raw, NUL-separated command line of the given process.
+func (p *Proc) Cmdline(pid string) ([]byte, error) {
+	return fs.ReadFile(p.FS, pid+"/cmdline")
+}
+
+// BootID returns the random identifier the kernel generates at each boot.
+func (p *Proc) BootID() (string, error) {
+	data, err := fs.ReadFile(p.FS, "sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// BootTime returns the time at which the system booted, as recorded in the
+// "btime" line of /proc/stat.
+func (p *Proc) BootTime() (time.Time, error) {
+	data, err := fs.ReadFile(p.FS, "stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for line := range strings.Lines(string(data)) {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid boot time %q: %w", strings.TrimSpace(value), err)
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, errors.New("no boot time in stat")
+}
+
+// Uptime returns how long the system has been up, as recorded in the first
+// field of /proc/uptime.
+func (p *Proc) Uptime() (time.Duration, error) {
+	data, err := fs.ReadFile(p.FS, "uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, errors.New("empty uptime")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid uptime %q: %w", fields[0], err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func isPID(name string) bool {
+	_, err := strconv.Atoi(name)
+	return err == nil
+}
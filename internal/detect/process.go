@@ -1,39 +1,37 @@
 package detect
 
 import (
+	"fmt"
 	"log/slog"
-	"os"
-	"path"
-	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
-func HasActiveProcess(pattern string) (bool, error) {
-	files, err := os.ReadDir("/proc")
+// HasActiveProcess checks if any process has a command line (with arguments
+// separated by blanks) matching the given regular expression.
+func (p *Proc) HasActiveProcess(pattern string) (bool, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid process pattern %q: %w", pattern, err)
+	}
+
+	pids, err := p.PIDs()
 	if err != nil {
 		slog.Error("failed to read /proc", "error", err)
 		return false, err
 	}
 
-	for _, f := range files {
-		if !f.IsDir() || !isPID(f.Name()) {
-			slog.Warn("ignoring non-pid directory", "name", f.Name())
-			continue
-		}
-		slog.Debug("checking process", "pid", f.Name())
-		filename := path.Clean(filepath.Join("/proc", f.Name(), "cmdline"))
-		data, err := os.ReadFile(filename)
+	for _, pid := range pids {
+		slog.Debug("checking process", "pid", pid)
+		data, err := p.Cmdline(pid)
 		if err != nil {
-			slog.Warn("failed to read process command line", "pid", f.Name(), "error", err)
+			slog.Warn("failed to read process command line", "pid", pid, "error", err)
 			continue
 		}
 
 		cmdline := strings.Replace(string(data), "\x00", " ", -1)
-		re := regexp.MustCompile(pattern)
 		if re.MatchString(cmdline) {
-			slog.Debug("found process", "filename", filename, "cmdline", cmdline)
+			slog.Debug("found process", "pid", pid, "cmdline", cmdline)
 			return true, nil
 		}
 	}
@@ -41,8 +39,3 @@ func HasActiveProcess(pattern string) (bool, error) {
 	slog.Debug("no active process found")
 	return false, nil
 }
-
-func isPID(name string) bool {
-	_, err := strconv.Atoi(name)
-	return err == nil
-}
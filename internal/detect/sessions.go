@@ -0,0 +1,110 @@
+package detect
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Session is a login session as tracked by systemd-logind, which records
+// the state of each session in a file under /run/systemd/sessions.
+type Session struct {
+	ID         string
+	UID        string
+	User       string
+	State      string
+	Class      string
+	Type       string
+	TTY        string
+	Service    string
+	Remote     bool
+	RemoteHost string
+}
+
+// IsActive returns whether the session belongs to a user and is still alive,
+// i.e. it is neither closing nor a background (e.g. greeter) session.
+func (s Session) IsActive() bool {
+	return (s.State == "active" || s.State == "online") && strings.HasPrefix(s.Class, "user")
+}
+
+// String returns a one-line description of the session.
+func (s Session) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "session %s: %s", s.ID, s.User)
+	if s.TTY != "" {
+		fmt.Fprintf(&b, " on %s", s.TTY)
+	}
+	if s.Remote && s.RemoteHost != "" {
+		fmt.Fprintf(&b, " from %s", s.RemoteHost)
+	}
+	if s.Service != "" {
+		fmt.Fprintf(&b, " (%s)", s.Service)
+	}
+	return b.String()
+}
+
+// Sessions returns the logind sessions recorded in the given filesystem,
+// which must be rooted at the equivalent of /run/systemd/sessions; a missing
+// directory (e.g. on a system without logind) yields no sessions.
+func Sessions(fsys fs.FS) ([]Session, error) {
+	if fsys == nil {
+		return nil, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sessions []Session
+	for _, entry := range entries {
+		// skip the "<id>.ref" FIFOs logind keeps alongside the session files
+		if !entry.Type().IsRegular() || strings.Contains(entry.Name(), ".") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			// the session may have been closed in the meantime
+			continue
+		}
+		sessions = append(sessions, parseSession(entry.Name(), data))
+	}
+	return sessions, nil
+}
+
+func parseSession(id string, data []byte) Session {
+	session := Session{ID: id}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "UID":
+			session.UID = value
+		case "USER":
+			session.User = value
+		case "STATE":
+			session.State = value
+		case "CLASS":
+			session.Class = value
+		case "TYPE":
+			session.Type = value
+		case "TTY":
+			session.TTY = value
+		case "SERVICE":
+			session.Service = value
+		case "REMOTE":
+			session.Remote = value == "1"
+		case "REMOTE_HOST":
+			session.RemoteHost = value
+		}
+	}
+	return session
+}
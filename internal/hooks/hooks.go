@@ -0,0 +1,229 @@
+// Package hooks runs the executables in the hook directories, in lexical
+// order like run-parts(8), before the power action is taken (e.g. to stop
+// containers cleanly or push unsaved work) and after the system resumes from
+// it; a failing pre-action hook can veto the power action.
+package hooks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// Stage is when hooks are run.
+type Stage string
+
+const (
+	// StagePreAction hooks run before the power action is taken.
+	StagePreAction Stage = "pre-action"
+	// StagePostResume hooks run when the system is back up after a power
+	// action.
+	StagePostResume Stage = "post-resume"
+)
+
+const (
+	// DefaultPreAction is the default directory of the pre-action hooks.
+	DefaultPreAction = "/etc/slumberd/pre-action.d"
+	// DefaultPostResume is the default directory of the post-resume hooks.
+	DefaultPostResume = "/etc/slumberd/post-resume.d"
+	// DefaultTimeout is the default time each hook may run for.
+	DefaultTimeout = time.Minute
+)
+
+// maxOutput caps the output of a hook kept for the log.
+const maxOutput = 64 << 10
+
+// Hooks are the hook directories and the policies applied to them.
+type Hooks struct {
+	// PreAction is the directory of the hooks run before the power action.
+	PreAction string `json:"pre_action,omitempty" yaml:"pre_action,omitempty"`
+	// PostResume is the directory of the hooks run after the system resumes.
+	PostResume string `json:"post_resume,omitempty" yaml:"post_resume,omitempty"`
+	// Timeout is the time each hook may run for before it is killed.
+	Timeout *timex.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Veto are the names of the pre-action hooks whose failure vetoes the
+	// power action, or "*" for all of them; the failure of other hooks is
+	// only logged.
+	Veto []string `json:"veto,omitempty" yaml:"veto,omitempty"`
+}
+
+// Validate checks the hooks settings and fills in the defaults.
+func (h *Hooks) Validate() error {
+	if h.PreAction == "" {
+		h.PreAction = DefaultPreAction
+	}
+	if h.PostResume == "" {
+		h.PostResume = DefaultPostResume
+	}
+	if h.Timeout == nil {
+		timeout := timex.Duration(DefaultTimeout)
+		h.Timeout = &timeout
+	}
+	if *h.Timeout <= 0 {
+		return fmt.Errorf("invalid hook timeout %s", h.Timeout)
+	}
+	for _, name := range h.Veto {
+		if name == "" || strings.ContainsRune(name, filepath.Separator) {
+			return fmt.Errorf("invalid vetoing hook name %q", name)
+		}
+	}
+	return nil
+}
+
+// VetoError is returned when a hook whose failure vetoes the power action
+// fails.
+type VetoError struct {
+	// Hook is the name of the failed hook.
+	Hook string
+	// Err is the failure.
+	Err error
+}
+
+func (e *VetoError) Error() string {
+	return fmt.Sprintf("power action vetoed by hook %s: %v", e.Hook, e.Err)
+}
+
+func (e *VetoError) Unwrap() error {
+	return e.Err
+}
+
+// dir returns the directory of the hooks of the stage.
+func (h *Hooks) dir(stage Stage) string {
+	if stage == StagePreAction {
+		return h.PreAction
+	}
+	return h.PostResume
+}
+
+// List returns the paths of the hooks of the stage, in the order they run:
+// executable regular files (or links to them), skipping hidden files, editor
+// backups and package manager leftovers.
+func (h *Hooks) List(stage Stage) ([]string, error) {
+	dir := h.dir(stage)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s hooks directory %s: %w", stage, dir, err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		if ignored(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			slog.Debug("skipping hook", "path", path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	// ReadDir sorts entries by name
+	return paths, nil
+}
+
+// ignored returns whether a file in a hook directory is not a hook.
+func ignored(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return true
+	}
+	for _, suffix := range []string{".dpkg-old", ".dpkg-dist", ".dpkg-new", ".dpkg-tmp", ".rpmnew", ".rpmsave", ".rpmorig", ".disabled"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// vetoes returns whether the failure of the named hook vetoes the power
+// action.
+func (h *Hooks) vetoes(stage Stage, name string) bool {
+	return stage == StagePreAction && (slices.Contains(h.Veto, "*") || slices.Contains(h.Veto, name))
+}
+
+// Run runs the hooks of the stage in order, with the given variables added
+// to the environment, and logs their output; a failing pre-action hook
+// whose failure vetoes the power action stops the sequence and is returned
+// as a *VetoError, while other failures are only logged.
+func (h *Hooks) Run(ctx context.Context, stage Stage, env []string) error {
+	paths, err := h.List(stage)
+	if err != nil {
+		return err
+	}
+	env = append(slices.Clone(env), "SLUMBERD_STAGE="+string(stage))
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if err := h.run(ctx, path, env); err != nil {
+			if h.vetoes(stage, name) {
+				slog.Error("hook failed, vetoing power action", "stage", stage, "hook", name, "error", err)
+				return &VetoError{Hook: name, Err: err}
+			}
+			slog.Warn("hook failed", "stage", stage, "hook", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// run runs a single hook, killing it (and its children) on timeout.
+func (h *Hooks) run(ctx context.Context, path string, env []string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*h.Timeout))
+	defer cancel()
+
+	var output limitedBuffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = 5 * time.Second
+	configure(cmd)
+
+	start := time.Now()
+	slog.Info("running hook", "path", path)
+	err := cmd.Run()
+	elapsed := time.Since(start)
+	scanner := bufio.NewScanner(bytes.NewReader(output.Bytes()))
+	for scanner.Scan() {
+		slog.Info("hook output", "hook", filepath.Base(path), "line", scanner.Text())
+	}
+	if output.truncated {
+		slog.Warn("hook output truncated", "hook", filepath.Base(path), "limit", maxOutput)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", time.Duration(*h.Timeout))
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("hook completed", "path", path, "duration", elapsed)
+	return nil
+}
+
+// limitedBuffer keeps the first maxOutput bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
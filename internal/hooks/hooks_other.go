@@ -0,0 +1,8 @@
+//go:build !unix
+
+package hooks
+
+import "os/exec"
+
+// configure leaves the default process handling on this platform.
+func configure(cmd *exec.Cmd) {}
@@ -0,0 +1,133 @@
+//go:build unix
+
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// hook writes an executable shell script in the directory.
+func hook(t *testing.T, dir, name, script string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(t.TempDir(), "log")
+	record := `echo "$(basename "$0") $SLUMBERD_STAGE $SLUMBERD_REASON" >> ` + log
+	hook(t, dir, "10-docker", record, 0755)
+	hook(t, dir, "20-flush", record+"\nexit 1", 0755)
+	hook(t, dir, "30-git", record+"\necho 'nothing to push' >&2\nexit 2", 0755)
+	hook(t, dir, "40-after", record, 0755)
+	// not hooks
+	hook(t, dir, ".hidden", record, 0755)
+	hook(t, dir, "15-backup~", record, 0755)
+	hook(t, dir, "15-old.dpkg-old", record, 0755)
+	hook(t, dir, "15-readme", record, 0644)
+
+	tests := []struct {
+		name  string
+		stage Stage
+		veto  []string
+		want  []string
+		hook  string
+	}{
+		{
+			name:  "no veto",
+			stage: StagePreAction,
+			want:  []string{"10-docker", "20-flush", "30-git", "40-after"},
+		},
+		{
+			name:  "vetoing hook fails",
+			stage: StagePreAction,
+			veto:  []string{"30-git"},
+			want:  []string{"10-docker", "20-flush", "30-git"},
+			hook:  "30-git",
+		},
+		{
+			name:  "all hooks veto",
+			stage: StagePreAction,
+			veto:  []string{"*"},
+			want:  []string{"10-docker", "20-flush"},
+			hook:  "20-flush",
+		},
+		{
+			name:  "no veto after resume",
+			stage: StagePostResume,
+			veto:  []string{"*"},
+			want:  []string{"10-docker", "20-flush", "30-git", "40-after"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(log)
+			h := &Hooks{PreAction: dir, PostResume: dir, Veto: tt.veto}
+			if err := h.Validate(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err := h.Run(context.Background(), tt.stage, []string{"SLUMBERD_REASON=idle"})
+			var veto *VetoError
+			if tt.hook == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if tt.hook != "" && (!errors.As(err, &veto) || veto.Hook != tt.hook) {
+				t.Errorf("expected a veto by %s, got %v", tt.hook, err)
+			}
+			data, err := os.ReadFile(log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) != 3 || fields[1] != string(tt.stage) || fields[2] != "idle" {
+					t.Errorf("unexpected environment: %q", line)
+				}
+				got = append(got, fields[0])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v to run, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	dir := t.TempDir()
+	// the child keeps the output open: it must be killed too
+	hook(t, dir, "10-slow", "sleep 30 &\nwait", 0755)
+	timeout := timex.Duration(100 * time.Millisecond)
+	h := &Hooks{PreAction: dir, Timeout: &timeout, Veto: []string{"10-slow"}}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	start := time.Now()
+	err := h.Run(context.Background(), StagePreAction, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the hook to be killed, took %s", elapsed)
+	}
+}
+
+func TestRunMissingDirectory(t *testing.T) {
+	h := &Hooks{PreAction: filepath.Join(t.TempDir(), "missing")}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := h.Run(context.Background(), StagePreAction, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package idle
+
+import (
+	"fmt"
+
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+// Policy decides how samples whose outcome is unknown, because detectors
+// failed, are treated.
+type Policy string
+
+const (
+	// FailOpen treats unknown samples as activity, so that a system that
+	// cannot be observed is kept up.
+	FailOpen Policy = "open"
+	// FailClosed treats unknown samples as idle, so that a system that cannot
+	// be observed is eventually powered off.
+	FailClosed Policy = "closed"
+)
+
+// ParsePolicy returns the policy with the given name.
+func ParsePolicy(name string) (Policy, error) {
+	switch Policy(name) {
+	case FailOpen, FailClosed:
+		return Policy(name), nil
+	}
+	return "", fmt.Errorf("invalid failure policy %q: must be %q or %q", name, FailOpen, FailClosed)
+}
+
+// Hysteresis debounces activity samples, so that a single sample cannot flip
+// the outcome: the system is only reported idle after ToIdle consecutive idle
+// samples, and active again after ToActive consecutive active samples.
+type Hysteresis struct {
+	// ToIdle is the number of consecutive idle samples needed to go idle.
+	ToIdle int
+	// ToActive is the number of consecutive active samples needed to go
+	// active again.
+	ToActive int
+	// Policy decides whether unknown samples count as active or idle.
+	Policy Policy
+
+	active bool
+	streak int
+}
+
+// NewHysteresis returns a Hysteresis reporting the system as active.
+func NewHysteresis(toIdle, toActive int, policy Policy) *Hysteresis {
+	return &Hysteresis{
+		ToIdle:   toIdle,
+		ToActive: toActive,
+		Policy:   policy,
+		active:   true,
+	}
+}
+
+// Observe feeds a sample and returns whether the system is to be considered
+// active.
+func (h *Hysteresis) Observe(verdict detect.Verdict) bool {
+	active := verdict == detect.Active
+	if verdict == detect.Unknown {
+		active = h.Policy != FailClosed
+	}
+
+	if active == h.active {
+		h.streak = 0
+		return h.active
+	}
+
+	h.streak++
+	needed := h.ToIdle
+	if active {
+		needed = h.ToActive
+	}
+	if h.streak >= needed {
+		h.active = active
+		h.streak = 0
+	}
+	return h.active
+}
+
+// Streak returns the number of consecutive samples so far contrary to the
+// reported outcome, e.g. idle samples while the system is still reported
+// active.
+func (h *Hysteresis) Streak() int {
+	return h.streak
+}
+
+// Reset sets whether the system is considered active, discarding any streak
+// of contrary samples; it is used when resuming from a persisted state, so
+// that an idle system is not reported active again on the first sample.
+func (h *Hysteresis) Reset(active bool) {
+	h.active = active
+	h.streak = 0
+}
@@ -0,0 +1,232 @@
+// Package idle implements the lifecycle of an idle system as an explicit
+// state machine: Active → Idle → Warning → Grace → Acting, with activity
+// bringing the machine back to Active from any state. Each transition is
+// emitted as an Event to the subscribed listeners.
+package idle
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// State is a phase of the idle lifecycle.
+type State int
+
+const (
+	// Active means that user activity was detected on the last sample.
+	Active State = iota
+	// Idle means that no activity has been detected since the last active
+	// sample, but the warning lead time has not been reached yet.
+	Idle
+	// Warning means that the power action is less than the warning lead time
+	// away; users should be warned.
+	Warning
+	// Grace means that the idle timeout has expired, and the power action
+	// will be taken at the end of the grace period unless activity resumes.
+	Grace
+	// Acting means that the power action is due.
+	Acting
+)
+
+var states = []string{"active", "idle", "warning", "grace", "acting"}
+
+// String returns the string representation of the State value.
+func (s State) String() string {
+	if s >= 0 && int(s) < len(states) {
+		return states[s]
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// MarshalText marshals the State value into a text string.
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText unmarshals a text string into the State variable.
+func (s *State) UnmarshalText(text []byte) error {
+	for i, name := range states {
+		if name == string(text) {
+			*s = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid state %q", string(text))
+}
+
+// Event describes a transition between two states.
+type Event struct {
+	// From is the state the machine left.
+	From State `json:"from"`
+	// To is the state the machine entered.
+	To State `json:"to"`
+	// Time is when the transition happened.
+	Time time.Time `json:"time"`
+	// Reason explains why the transition happened.
+	Reason string `json:"reason"`
+	// Idle is how long the system has been idle at the time of the event.
+	Idle time.Duration `json:"idle"`
+	// Remaining is how long until the power action, at the time of the event.
+	Remaining time.Duration `json:"remaining"`
+}
+
+// LogValue returns the event as a group of structured logging attributes.
+func (e Event) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("from", e.From.String()),
+		slog.String("to", e.To.String()),
+		slog.String("reason", e.Reason),
+		slog.Duration("idle", e.Idle),
+		slog.Duration("remaining", e.Remaining),
+	)
+}
+
+// Listener is notified of state transitions; listeners are called
+// synchronously, in order of subscription, and must not block.
+type Listener func(Event)
+
+// Machine tracks the idle lifecycle from a sequence of activity samples.
+type Machine struct {
+	// Timeout is how long the system must be idle before the grace period
+	// starts.
+	Timeout time.Duration
+	// Warning is the lead time before the timeout at which users are warned.
+	Warning time.Duration
+	// Grace is how long after the timeout the power action is taken.
+	Grace time.Duration
+
+	state      State
+	since      time.Time
+	lastActive time.Time
+	listeners  []Listener
+}
+
+// New returns a machine in the Active state, as if activity had just been
+// detected at the given time.
+func New(timeout, warning, grace time.Duration, now time.Time) *Machine {
+	return &Machine{
+		Timeout:    timeout,
+		Warning:    warning,
+		Grace:      grace,
+		state:      Active,
+		since:      now,
+		lastActive: now,
+	}
+}
+
+// Restore puts the machine in the given state, entered at the given time,
+// with the last activity at the given time, without notifying listeners; it
+// is used to resume the lifecycle from a persisted state.
+func (m *Machine) Restore(state State, since, lastActive time.Time) {
+	m.state = state
+	m.since = since
+	m.lastActive = lastActive
+}
+
+// Backdate moves the last activity back to the given time, if earlier; it is
+// used once the system is confirmed idle after a few samples, since the
+// first of them is when activity stopped.
+func (m *Machine) Backdate(lastActive time.Time) {
+	if lastActive.Before(m.lastActive) {
+		m.lastActive = lastActive
+	}
+}
+
+// Subscribe registers a listener for state transitions.
+func (m *Machine) Subscribe(listener Listener) {
+	m.listeners = append(m.listeners, listener)
+}
+
+// State returns the current state.
+func (m *Machine) State() State {
+	return m.state
+}
+
+// Since returns when the current state was entered.
+func (m *Machine) Since() time.Time {
+	return m.since
+}
+
+// LastActive returns the time of the last activity.
+func (m *Machine) LastActive() time.Time {
+	return m.lastActive
+}
+
+// Deadline returns when the power action is due, if no activity resumes.
+func (m *Machine) Deadline() time.Time {
+	return m.lastActive.Add(m.Timeout + m.Grace)
+}
+
+// Observe feeds a sample to the machine: if active is true, activity was
+// detected at the given time and the reason describes it. Observe returns the
+// resulting state, after notifying listeners of any transition; when the
+// machine moves more than one state ahead, each intermediate transition is
+// emitted in turn.
+func (m *Machine) Observe(now time.Time, active bool, reason string) State {
+	if active {
+		m.lastActive = now
+		if m.state != Active {
+			m.transition(now, Active, reason)
+		}
+		return m.state
+	}
+
+	idle := now.Sub(m.lastActive)
+	for {
+		next, why := m.next(idle)
+		if next == m.state {
+			break
+		}
+		m.transition(now, next, why)
+	}
+	return m.state
+}
+
+// Force moves the machine straight to the Acting state, regardless of the
+// idle time, for the given reason; listeners are notified of the transition
+// unless the machine is already Acting.
+func (m *Machine) Force(now time.Time, reason string) State {
+	if m.state != Acting {
+		m.transition(now, Acting, reason)
+	}
+	return m.state
+}
+
+// next returns the state following the current one given the idle time, if
+// its threshold has been reached, along with the reason for the transition.
+func (m *Machine) next(idle time.Duration) (State, string) {
+	switch m.state {
+	case Active:
+		return Idle, "no activity detected"
+	case Idle:
+		if idle >= max(m.Timeout-m.Warning, 0) {
+			return Warning, "warning lead time reached"
+		}
+	case Warning:
+		if idle >= m.Timeout {
+			return Grace, "idle timeout reached"
+		}
+	case Grace:
+		if idle >= m.Timeout+m.Grace {
+			return Acting, "grace period expired"
+		}
+	}
+	return m.state, ""
+}
+
+func (m *Machine) transition(now time.Time, to State, reason string) {
+	event := Event{
+		From:      m.state,
+		To:        to,
+		Time:      now,
+		Reason:    reason,
+		Idle:      now.Sub(m.lastActive),
+		Remaining: max(m.Deadline().Sub(now), 0),
+	}
+	m.state = to
+	m.since = now
+	for _, listener := range m.listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,219 @@
+package idle
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+func TestMachine(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		samples []bool // one per minute, starting one minute after start
+		want    []State
+		events  []State
+	}{
+		{
+			name:    "stays active",
+			samples: []bool{true, true, true},
+			want:    []State{Active, Active, Active},
+		},
+		{
+			name:    "full lifecycle",
+			samples: []bool{false, false, false, false, false, false, false},
+			want:    []State{Idle, Idle, Warning, Warning, Grace, Grace, Acting},
+			events:  []State{Idle, Warning, Grace, Acting},
+		},
+		{
+			name:    "cancelled during grace",
+			samples: []bool{false, false, false, false, false, true, false},
+			want:    []State{Idle, Idle, Warning, Warning, Grace, Active, Idle},
+			events:  []State{Idle, Warning, Grace, Active, Idle},
+		},
+		{
+			name:    "cancelled during warning",
+			samples: []bool{false, false, false, true, false, false, false},
+			want:    []State{Idle, Idle, Warning, Active, Idle, Idle, Warning},
+			events:  []State{Idle, Warning, Active, Idle, Warning},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// timeout at 5m, warning from 3m, action at 7m
+			m := New(5*time.Minute, 2*time.Minute, 2*time.Minute, start)
+			var events []State
+			m.Subscribe(func(e Event) {
+				events = append(events, e.To)
+			})
+			for i, active := range tt.samples {
+				now := start.Add(time.Duration(i+1) * time.Minute)
+				if got := m.Observe(now, active, "test"); got != tt.want[i] {
+					t.Errorf("sample %d: expected %v, got %v", i, tt.want[i], got)
+				}
+			}
+			if !slices.Equal(events, tt.events) {
+				t.Errorf("expected events %v, got %v", tt.events, events)
+			}
+		})
+	}
+}
+
+func TestMachineSkipsAhead(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	m := New(15*time.Minute, 5*time.Minute, time.Minute, start)
+	var events []Event
+	m.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	// a single sample long after the deadline emits every transition
+	now := start.Add(time.Hour)
+	if got := m.Observe(now, false, ""); got != Acting {
+		t.Fatalf("expected %v, got %v", Acting, got)
+	}
+	want := []State{Idle, Warning, Grace, Acting}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %v", len(want), events)
+	}
+	for i, e := range events {
+		if e.To != want[i] || e.Time != now || e.Idle != time.Hour || e.Remaining != 0 {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+	if !m.Deadline().Equal(start.Add(16 * time.Minute)) {
+		t.Errorf("unexpected deadline %v", m.Deadline())
+	}
+}
+
+func TestMachineForce(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	m := New(15*time.Minute, 5*time.Minute, time.Minute, start)
+	var events []Event
+	m.Subscribe(func(e Event) {
+		events = append(events, e)
+	})
+
+	now := start.Add(time.Minute)
+	if got := m.Force(now, "curfew"); got != Acting {
+		t.Fatalf("expected %v, got %v", Acting, got)
+	}
+	m.Force(now.Add(time.Minute), "curfew")
+	if len(events) != 1 || events[0].From != Active || events[0].To != Acting || events[0].Reason != "curfew" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestMachineBackdate(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	m := New(15*time.Minute, 5*time.Minute, time.Minute, start)
+	m.Observe(start.Add(2*time.Minute), true, "still counted as active")
+
+	m.Backdate(start.Add(time.Minute))
+	if got := m.LastActive(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the last activity at 09:01, got %v", got.Format("15:04"))
+	}
+	// activity is never moved forward
+	m.Backdate(start.Add(3 * time.Minute))
+	if got := m.LastActive(); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the last activity at 09:01, got %v", got.Format("15:04"))
+	}
+	if got := m.Observe(start.Add(12*time.Minute), false, ""); got != Warning {
+		t.Errorf("expected %v 11m after the last activity, got %v", Warning, got)
+	}
+}
+
+func TestStateText(t *testing.T) {
+	for _, s := range []State{Active, Idle, Warning, Grace, Acting} {
+		b, err := s.MarshalText()
+		if err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		var got State
+		if err := got.UnmarshalText(b); err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+		if got != s {
+			t.Errorf("expected %v, got %v", s, got)
+		}
+	}
+	var s State
+	if err := s.UnmarshalText([]byte("sleeping")); err == nil {
+		t.Error("expected error for invalid state")
+	}
+}
+
+func TestHysteresis(t *testing.T) {
+	const (
+		a = detect.Active
+		i = detect.Idle
+		u = detect.Unknown
+	)
+	tests := []struct {
+		name     string
+		toIdle   int
+		toActive int
+		policy   Policy
+		samples  []detect.Verdict
+		want     []bool
+		streak   int
+	}{
+		{
+			name:   "single samples flip",
+			toIdle: 1, toActive: 1, policy: FailOpen,
+			samples: []detect.Verdict{i, a, i, i},
+			want:    []bool{false, true, false, false},
+		},
+		{
+			name:   "idle needs consecutive samples",
+			toIdle: 3, toActive: 1, policy: FailOpen,
+			samples: []detect.Verdict{i, i, a, i, i, i, i},
+			want:    []bool{true, true, true, true, true, false, false},
+		},
+		{
+			name:   "idle samples still reported active",
+			toIdle: 3, toActive: 1, policy: FailOpen,
+			samples: []detect.Verdict{a, i, i},
+			want:    []bool{true, true, true},
+			streak:  2,
+		},
+		{
+			name:   "active needs consecutive samples",
+			toIdle: 1, toActive: 2, policy: FailOpen,
+			samples: []detect.Verdict{i, a, i, a, a, i},
+			want:    []bool{false, false, false, false, true, false},
+		},
+		{
+			name:   "fail open keeps the system active",
+			toIdle: 2, toActive: 1, policy: FailOpen,
+			samples: []detect.Verdict{i, u, i, i, u},
+			want:    []bool{true, true, true, false, true},
+		},
+		{
+			name:   "fail closed lets the system go idle",
+			toIdle: 2, toActive: 1, policy: FailClosed,
+			samples: []detect.Verdict{i, u, a, u, u},
+			want:    []bool{true, false, true, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHysteresis(tt.toIdle, tt.toActive, tt.policy)
+			for n, sample := range tt.samples {
+				if got := h.Observe(sample); got != tt.want[n] {
+					t.Errorf("sample %d (%v): expected %v, got %v", n, sample, tt.want[n], got)
+				}
+				if n == len(tt.samples)-1 && h.Streak() != tt.streak {
+					t.Errorf("expected a streak of %d, got %d", tt.streak, h.Streak())
+				}
+			}
+		})
+	}
+
+	if _, err := ParsePolicy("ajar"); err == nil {
+		t.Error("expected error for invalid policy")
+	}
+}
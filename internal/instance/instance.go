@@ -0,0 +1,58 @@
+// Package instance reads information about the cloud instance the daemon
+// runs on from the OpenStack metadata service.
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMetadataURL is the base URL of the metadata service.
+const DefaultMetadataURL = "http://169.254.169.254"
+
+// UserIDKey is the metadata key holding the id of the user the instance
+// belongs to.
+const UserIDKey = "slumber-user-id"
+
+// Metadata is the instance metadata, as in openstack/latest/meta_data.json.
+type Metadata struct {
+	UUID             string            `json:"uuid"`
+	Name             string            `json:"name"`
+	Hostname         string            `json:"hostname"`
+	ProjectID        string            `json:"project_id"`
+	AvailabilityZone string            `json:"availability_zone"`
+	Meta             map[string]string `json:"meta"`
+}
+
+// UserID returns the id of the user the instance belongs to, if set in the
+// instance metadata.
+func (m *Metadata) UserID() string {
+	return m.Meta[UserIDKey]
+}
+
+// Fetch reads the instance metadata from the metadata service at the given
+// base URL.
+func Fetch(ctx context.Context, baseURL string) (*Metadata, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/openstack/latest/meta_data.json"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create metadata request: %w", err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instance metadata: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read instance metadata from %s: %s", url, response.Status)
+	}
+	var m Metadata
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid instance metadata from %s: %w", url, err)
+	}
+	return &m, nil
+}
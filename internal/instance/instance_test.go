@@ -0,0 +1,51 @@
+package instance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openstack/latest/meta_data.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"uuid": "d8e02d56-2648-49a3-bf97-6be8f1204f38",
+			"name": "devbox",
+			"hostname": "devbox.novalocal",
+			"project_id": "f7ac731cc11f40efbc03a9f9e1d1d21f",
+			"availability_zone": "nova",
+			"meta": {"slumber-user-id": "jdoe"}
+		}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url     string
+		userID  string
+		wantErr bool
+	}{
+		{server.URL, "jdoe", false},
+		{server.URL + "/", "jdoe", false},
+		{server.URL + "/missing", "", true},
+	}
+	for _, tt := range tests {
+		m, err := Fetch(context.Background(), tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.url)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+		if m.UUID != "d8e02d56-2648-49a3-bf97-6be8f1204f38" || m.UserID() != tt.userID {
+			t.Errorf("%s: unexpected metadata %+v", tt.url, m)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// queueSize is the number of messages that may wait for delivery to a
+// notifier before new ones are dropped.
+const queueSize = 16
+
+// Dispatcher delivers messages to notifiers in the background, so that a
+// slow or unreachable notifier never delays the caller; each notifier gets
+// the messages in the order they were sent.
+type Dispatcher struct {
+	timeout time.Duration
+	queues  []chan Message
+	pending sync.WaitGroup
+}
+
+// NewDispatcher returns a dispatcher delivering messages to the notifiers,
+// giving each delivery at most the given time, or the budget of Budgeted
+// notifiers.
+func NewDispatcher(timeout time.Duration, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{timeout: timeout}
+	for _, notifier := range notifiers {
+		queue := make(chan Message, queueSize)
+		d.queues = append(d.queues, queue)
+		go d.deliver(notifier, queue)
+	}
+	return d
+}
+
+// Send queues the message for delivery to every notifier and returns
+// immediately; the message is dropped for notifiers whose queue is full.
+func (d *Dispatcher) Send(m Message) {
+	for _, queue := range d.queues {
+		d.pending.Add(1)
+		select {
+		case queue <- m:
+		default:
+			d.pending.Done()
+			slog.Warn("notification queue full, dropping message", "kind", m.Kind)
+		}
+	}
+}
+
+// Wait waits until the queued messages have been delivered, or the context
+// is done.
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver hands the queued messages to the notifier; failures are logged.
+func (d *Dispatcher) deliver(notifier Notifier, queue <-chan Message) {
+	timeout := d.timeout
+	if b, ok := notifier.(Budgeted); ok {
+		timeout = b.Budget()
+	}
+	for m := range queue {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := notifier.Notify(ctx, m); err != nil {
+			slog.Error("error delivering notification", "notifier", notifier.Name(), "kind", m.Kind, "error", err)
+		}
+		cancel()
+		d.pending.Done()
+	}
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a notifier recording the kinds of the messages it delivers,
+// optionally blocking until released.
+type recorder struct {
+	lock    sync.Mutex
+	kinds   []Kind
+	release chan struct{}
+}
+
+func (r *recorder) Name() string {
+	return "recorder"
+}
+
+func (r *recorder) Notify(ctx context.Context, m Message) error {
+	if r.release != nil {
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.kinds = append(r.kinds, m.Kind)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	fast := &recorder{}
+	slow := &recorder{release: make(chan struct{})}
+	d := NewDispatcher(time.Minute, slow, fast)
+
+	kinds := []Kind{Transition, Warning, Warning, Action}
+	start := time.Now()
+	for _, kind := range kinds {
+		d.Send(Message{Kind: kind})
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected sending not to wait for the slow notifier, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to time out, got %v", err)
+	}
+
+	close(slow.release)
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, r := range []*recorder{fast, slow} {
+		if !slices.Equal(r.kinds, kinds) {
+			t.Errorf("expected %v in order, got %v", kinds, r.kinds)
+		}
+	}
+}
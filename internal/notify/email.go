@@ -0,0 +1,283 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// DefaultEmailSubject is the default subject of email notifications.
+const DefaultEmailSubject = `{{if eq .Kind "action"}}{{.Host}} was {{.Action}}{{else if eq .Kind "failure"}}{{.Host}} could not be {{.Action}}{{else}}{{.Host}} will be {{.Action}} in {{duration .Remaining}}{{end}}`
+
+// DefaultEmailBody is the default body of email notifications.
+const DefaultEmailBody = `{{if eq .Kind "action"}}Your system {{.Host}} was {{.Action}} at {{.Time.Format "15:04 MST on Mon Jan 2"}}: {{.Reason}}.
+{{else if eq .Kind "failure"}}Your system {{.Host}} could not be {{.Action}} at {{.Time.Format "15:04 MST on Mon Jan 2"}}: {{.Reason}}.
+{{else}}Your system {{.Host}} will be {{.Action}} in {{duration .Remaining}} ({{.Deadline.Format "15:04 MST"}}): {{.Reason}}.
+{{if .Postpone}}
+Save your work, or log in and run '{{.Postpone}}' to postpone.
+{{end}}{{end}}
+--
+slumberd@{{.Host}}
+`
+
+// DefaultEmailTimeout is the default timeout of the delivery of an email.
+const DefaultEmailTimeout = 30 * time.Second
+
+// Email sends messages by email through an SMTP relay, to the configured
+// recipients or, if there are none, to the user the instance belongs to.
+type Email struct {
+	// Server is the address of the SMTP relay, as host:port; the port
+	// defaults to 587 (submission).
+	Server string `json:"server" yaml:"server"`
+	// StartTLS requires the connection to be upgraded with STARTTLS before
+	// authenticating and sending (the default); if false, STARTTLS is only
+	// used when offered.
+	StartTLS *bool `json:"starttls,omitempty" yaml:"starttls,omitempty"`
+	// Username and Password authenticate to the relay, if set.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// From is the sender address.
+	From string `json:"from" yaml:"from"`
+	// To are the recipient addresses; if empty, the message goes to the user
+	// id of the instance, completed with Domain if it is not an address.
+	To []string `json:"to,omitempty" yaml:"to,omitempty"`
+	// Domain completes user ids into addresses (e.g. "example.com").
+	Domain string `json:"domain,omitempty" yaml:"domain,omitempty"`
+	// Subject and Body are the templates of the email.
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Body    string `json:"body,omitempty" yaml:"body,omitempty"`
+	// Events are the kinds of events sent; warnings, actions and failures if
+	// empty.
+	Events []Kind `json:"events,omitempty" yaml:"events,omitempty"`
+	// Timeout bounds the delivery of each email.
+	Timeout *timex.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	host    string
+	subject *template.Template
+	body    *template.Template
+	// tlsConfig, if set, is used for STARTTLS instead of the defaults.
+	tlsConfig *tls.Config
+}
+
+// Validate checks the email settings, fills in the defaults and prepares
+// the templates.
+func (e *Email) Validate() error {
+	if e.Server == "" {
+		return errors.New("no SMTP server")
+	}
+	host, _, err := net.SplitHostPort(e.Server)
+	if err != nil {
+		host = e.Server
+		e.Server = net.JoinHostPort(e.Server, "587")
+	}
+	e.host = host
+	if e.StartTLS == nil {
+		required := true
+		e.StartTLS = &required
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("invalid sender address %q: %w", e.From, err)
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient address %q: %w", to, err)
+		}
+	}
+	if len(e.Events) == 0 {
+		e.Events = []Kind{Warning, Action, Failure}
+	}
+	for _, kind := range e.Events {
+		if kind != Transition && kind != Warning && kind != Action && kind != Failure {
+			return fmt.Errorf("invalid email event %q", kind)
+		}
+	}
+	if e.Subject == "" {
+		e.Subject = DefaultEmailSubject
+	}
+	if e.subject, err = ParseTemplate("email subject", e.Subject); err != nil {
+		return err
+	}
+	if e.Body == "" {
+		e.Body = DefaultEmailBody
+	}
+	if e.body, err = ParseTemplate("email body", e.Body); err != nil {
+		return err
+	}
+	if e.Timeout == nil {
+		timeout := timex.Duration(DefaultEmailTimeout)
+		e.Timeout = &timeout
+	}
+	if *e.Timeout <= 0 {
+		return fmt.Errorf("invalid email timeout %s", e.Timeout)
+	}
+	return nil
+}
+
+// Name returns the name of the notifier.
+func (e *Email) Name() string {
+	return "email " + e.host
+}
+
+// Budget returns the timeout of the delivery of an email.
+func (e *Email) Budget() time.Duration {
+	return time.Duration(*e.Timeout)
+}
+
+// Notify sends the message by email, if its kind is among the events of the
+// notifier.
+func (e *Email) Notify(ctx context.Context, m Message) error {
+	if !slices.Contains(e.Events, m.Kind) {
+		return nil
+	}
+	to, err := e.recipients(m)
+	if err != nil {
+		return err
+	}
+	data, err := e.compose(m, to)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*e.Timeout))
+	defer cancel()
+	if err := e.send(ctx, m.Host, to, data); err != nil {
+		if ctx.Err() != nil {
+			err = errors.Join(err, ctx.Err())
+		}
+		return fmt.Errorf("failed to send email through %s: %w", e.Server, err)
+	}
+	slog.Debug("email sent", "server", e.Server, "to", to, "kind", m.Kind)
+	return nil
+}
+
+// recipients returns the addresses the message goes to.
+func (e *Email) recipients(m Message) ([]string, error) {
+	if len(e.To) > 0 {
+		return e.To, nil
+	}
+	switch {
+	case m.UserID == "":
+		return nil, errors.New("no email recipient: no addresses configured and no user id")
+	case strings.Contains(m.UserID, "@"):
+		return []string{m.UserID}, nil
+	case e.Domain != "":
+		return []string{m.UserID + "@" + e.Domain}, nil
+	default:
+		return nil, fmt.Errorf("no email recipient: user id %q is not an address and no domain is configured", m.UserID)
+	}
+}
+
+// compose renders the message into an RFC 5322 email.
+func (e *Email) compose(m Message, to []string) ([]byte, error) {
+	subject, err := Render(e.subject, m)
+	if err != nil {
+		return nil, err
+	}
+	body, err := Render(e.body, m)
+	if err != nil {
+		return nil, err
+	}
+	date := m.Time
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	b.WriteString("Auto-Submitted: auto-generated\r\n")
+	b.WriteString("\r\n")
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// send delivers the email through the relay, introducing itself with the
+// given name; the connection is closed when the context is done.
+func (e *Email) send(ctx context.Context, name string, to []string, data []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", e.Server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if name == "" {
+		name = "localhost"
+	}
+	if err := client.Hello(name); err != nil {
+		return err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		config := e.tlsConfig
+		if config == nil {
+			config = &tls.Config{ServerName: e.host}
+		}
+		if err := client.StartTLS(config); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	} else if *e.StartTLS {
+		return errors.New("server does not support STARTTLS")
+	}
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	from, _ := mail.ParseAddress(e.From)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return fmt.Errorf("invalid recipient address %q: %w", recipient, err)
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,338 @@
+package notify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// envelope is an email received by the SMTP stand-in.
+type envelope struct {
+	tls  bool
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// relay is a minimal SMTP server standing in for the relay, optionally
+// offering STARTTLS with a self-signed certificate.
+type relay struct {
+	listener net.Listener
+	config   *tls.Config
+	// delay holds back the greeting, as a slow relay does.
+	delay    time.Duration
+	lock     sync.Mutex
+	received []envelope
+}
+
+func newRelay(t *testing.T, starttls bool) (*relay, *tls.Config) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &relay{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	var client *tls.Config
+	if starttls {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certificate, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.config = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+		pool := x509.NewCertPool()
+		pool.AddCert(certificate)
+		client = &tls.Config{ServerName: "127.0.0.1", RootCAs: pool}
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r, client
+}
+
+func (r *relay) serve(conn net.Conn) {
+	defer conn.Close()
+	var e envelope
+	tp := textproto.NewConn(conn)
+	time.Sleep(r.delay)
+	tp.PrintfLine("220 relay ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"relay", "8BITMIME"}
+			if r.config != nil && !e.tls {
+				extensions = append(extensions, "STARTTLS")
+			}
+			if e.tls {
+				extensions = append(extensions, "AUTH PLAIN")
+			}
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				tp.PrintfLine("250%s%s", separator, extension)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start TLS")
+			secure := tls.Server(conn, r.config)
+			if err := secure.Handshake(); err != nil {
+				return
+			}
+			conn = secure
+			tp = textproto.NewConn(secure)
+			e.tls = true
+		case "AUTH":
+			_, credentials, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			e.auth = string(decoded)
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:"), " ")
+			e.from = strings.Trim(from, "<>")
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			e.to = append(e.to, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			e.data = string(data)
+			r.lock.Lock()
+			r.received = append(r.received, e)
+			r.lock.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (r *relay) envelopes() []envelope {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.received
+}
+
+func TestEmail(t *testing.T) {
+	r, client := newRelay(t, true)
+	e := &Email{
+		Server:   r.listener.Addr().String(),
+		Username: "slumberd",
+		Password: "secret",
+		From:     "slumberd <slumberd@example.com>",
+		Domain:   "example.com",
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e.tlsConfig = client
+
+	m := testMessage()
+	m.Kind = Action
+	m.Remaining = 0
+	m.Time = m.Deadline
+	if err := e.Notify(context.Background(), m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// transitions are not sent by default
+	m.Kind = Transition
+	if err := e.Notify(context.Background(), m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.Kind = Failure
+	m.Reason = "cannot poweroff: not permitted by polkit"
+	if err := e.Notify(context.Background(), m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := r.envelopes()
+	if len(received) != 2 {
+		t.Fatalf("expected 2 emails, got %d", len(received))
+	}
+	got := received[0]
+	if !got.tls {
+		t.Errorf("expected the email to be sent over TLS")
+	}
+	if got.auth != "\x00slumberd\x00secret" {
+		t.Errorf("expected plain authentication, got %q", got.auth)
+	}
+	if got.from != "slumberd@example.com" || len(got.to) != 1 || got.to[0] != "jdoe@example.com" {
+		t.Errorf("unexpected envelope from %q to %q", got.from, got.to)
+	}
+	message, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("invalid email: %v", err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "devbox was powered off" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(message.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Your system devbox was powered off at 07:44 UTC on Mon Mar 10: no user activity since 06:44."; !strings.Contains(string(body), want) {
+		t.Errorf("expected %q in body %q", want, body)
+	}
+	message, err = mail.ReadMessage(strings.NewReader(received[1].data))
+	if err != nil {
+		t.Fatalf("invalid email: %v", err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "devbox could not be powered off" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	body, _ = io.ReadAll(quotedprintable.NewReader(message.Body))
+	if want := "Your system devbox could not be powered off at 07:44 UTC on Mon Mar 10: cannot poweroff: not permitted by polkit."; !strings.Contains(string(body), want) {
+		t.Errorf("expected %q in body %q", want, body)
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	r, _ := newRelay(t, false)
+	r.delay = 100 * time.Millisecond
+	required := false
+	timeout := timex.Duration(time.Second)
+	e := &Email{Server: r.listener.Addr().String(), StartTLS: &required, From: "slumberd@example.com", To: []string{"ops@example.com"}, Timeout: &timeout}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the timeout of the email, not that of the dispatcher, bounds delivery
+	d := NewDispatcher(10*time.Millisecond, e)
+	d.Send(testMessage())
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(r.envelopes()) != 1 {
+		t.Errorf("expected the email to be delivered by the slow relay")
+	}
+}
+
+func TestEmailTemplates(t *testing.T) {
+	r, _ := newRelay(t, false)
+	required := false
+	e := &Email{
+		Server:   r.listener.Addr().String(),
+		StartTLS: &required,
+		From:     "slumberd@example.com",
+		To:       []string{"ops@example.com", "Jane Doe <jdoe@example.com>"},
+		Subject:  "[{{.Kind}}] {{.Host}}",
+		Body:     "{{.Host}}: {{duration .Remaining}} left\n",
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := e.Notify(context.Background(), testMessage()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	received := r.envelopes()
+	if len(received) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(received))
+	}
+	if got := received[0].to; len(got) != 2 || got[1] != "jdoe@example.com" {
+		t.Errorf("unexpected recipients %q", got)
+	}
+	message, err := mail.ReadMessage(strings.NewReader(received[0].data))
+	if err != nil {
+		t.Fatalf("invalid email: %v", err)
+	}
+	if subject := message.Header.Get("Subject"); subject != "[warning] devbox" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(message.Body))
+	if string(body) != "devbox: 10 minutes left\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestEmailStartTLSRequired(t *testing.T) {
+	r, _ := newRelay(t, false)
+	e := &Email{Server: r.listener.Addr().String(), From: "slumberd@example.com", To: []string{"ops@example.com"}}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := e.Notify(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected a STARTTLS error, got %v", err)
+	}
+	if len(r.envelopes()) != 0 {
+		t.Errorf("expected no email to be sent")
+	}
+}
+
+func TestEmailRecipients(t *testing.T) {
+	tests := []struct {
+		to     []string
+		domain string
+		userID string
+		want   string
+	}{
+		{[]string{"ops@example.com"}, "example.com", "jdoe", "ops@example.com"},
+		{nil, "example.com", "jdoe", "jdoe@example.com"},
+		{nil, "example.com", "jane.doe@example.org", "jane.doe@example.org"},
+		{nil, "", "jdoe", ""},
+		{nil, "example.com", "", ""},
+	}
+	for _, tt := range tests {
+		e := &Email{To: tt.to, Domain: tt.domain}
+		got, err := e.recipients(Message{UserID: tt.userID})
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q@%q: expected an error, got %q", tt.userID, tt.domain, got)
+			}
+			continue
+		}
+		if err != nil || len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%q@%q: expected %q, got %q (%v)", tt.userID, tt.domain, tt.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package notify tells users about the idle lifecycle of the host and the
+// power action coming, through notifiers such as a wall-style broadcast to
+// their terminals or webhooks, and tracks the countdown of warnings to send
+// as the action gets closer.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+)
+
+// Kind is the kind of event a message is about.
+type Kind string
+
+const (
+	// Transition is a transition between two states of the idle lifecycle.
+	Transition Kind = "transition"
+	// Warning is a warning of the countdown before the power action.
+	Warning Kind = "warning"
+	// Action is the power action being taken.
+	Action Kind = "action"
+	// Failure is the power action failing, which starts the idle clock
+	// over.
+	Failure Kind = "failure"
+)
+
+// Message describes an event in the idle lifecycle of the host.
+type Message struct {
+	// Kind is the kind of event.
+	Kind Kind
+	// Time is when the event happened.
+	Time time.Time
+	// Host is the name of the host.
+	Host string
+	// UserID identifies the user the instance belongs to (slumber-user-id).
+	UserID string
+	// Action is what will be done to the host, e.g. "powered off", or what
+	// is being done once the power action is taken, e.g. "suspended".
+	Action string
+	// From is the state the idle lifecycle left, for transitions.
+	From idle.State
+	// To is the state the idle lifecycle entered, for transitions; it is the
+	// current state for other events.
+	To idle.State
+	// Idle is how long the host has been idle.
+	Idle time.Duration
+	// Remaining is how long until the power action; zero means now.
+	Remaining time.Duration
+	// Deadline is when the power action is due.
+	Deadline time.Time
+	// LastActive is the time of the last detected activity.
+	LastActive time.Time
+	// Reason explains why the event happened.
+	Reason string
+	// Postpone is the command users can run to postpone the power action.
+	Postpone string
+}
+
+// MarshalJSON marshals the Message into a JSON object, with the durations as
+// strings.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message struct {
+		Kind       Kind        `json:"kind"`
+		Time       time.Time   `json:"time"`
+		Host       string      `json:"host"`
+		UserID     string      `json:"user_id,omitempty"`
+		Action     string      `json:"action"`
+		From       *idle.State `json:"from,omitempty"`
+		To         idle.State  `json:"to"`
+		Idle       string      `json:"idle"`
+		Remaining  string      `json:"remaining"`
+		Deadline   time.Time   `json:"deadline"`
+		LastActive time.Time   `json:"last_active"`
+		Reason     string      `json:"reason"`
+		Postpone   string      `json:"postpone,omitempty"`
+	}
+	var from *idle.State
+	if m.Kind == Transition {
+		from = &m.From
+	}
+	return json.Marshal(message{
+		Kind:       m.Kind,
+		Time:       m.Time,
+		Host:       m.Host,
+		UserID:     m.UserID,
+		Action:     m.Action,
+		From:       from,
+		To:         m.To,
+		Idle:       m.Idle.Round(time.Second).String(),
+		Remaining:  m.Remaining.Round(time.Second).String(),
+		Deadline:   m.Deadline,
+		LastActive: m.LastActive,
+		Reason:     m.Reason,
+		Postpone:   m.Postpone,
+	})
+}
+
+// Summary returns a one-line description of the message, e.g. for chat
+// notifications.
+func (m Message) Summary() string {
+	var b strings.Builder
+	b.WriteString(m.Host)
+	if m.UserID != "" {
+		fmt.Fprintf(&b, " (%s)", m.UserID)
+	}
+	switch m.Kind {
+	case Transition:
+		fmt.Fprintf(&b, ": %s -> %s: %s (idle %s", m.From, m.To, m.Reason, Humanize(m.Idle))
+		if m.Remaining > 0 {
+			fmt.Fprintf(&b, ", %s in %s", m.Action, Humanize(m.Remaining))
+		}
+		b.WriteString(")")
+	case Action:
+		fmt.Fprintf(&b, " is being %s: %s", m.Action, m.Reason)
+	case Failure:
+		fmt.Fprintf(&b, " could not be %s: %s", m.Action, m.Reason)
+	default:
+		fmt.Fprintf(&b, " will be %s in %s: %s", m.Action, Humanize(m.Remaining), m.Reason)
+	}
+	return b.String()
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	// Name returns the name of the notifier, for logging.
+	Name() string
+	// Notify delivers the message.
+	Notify(ctx context.Context, m Message) error
+}
+
+// Budgeted is implemented by notifiers that bound the time they take to
+// deliver a message themselves, e.g. because they retry.
+type Budgeted interface {
+	// Budget returns the longest time the notifier takes to deliver a
+	// message.
+	Budget() time.Duration
+}
+
+// DefaultTemplate is the default text of warning messages.
+const DefaultTemplate = `{{if eq .Kind "failure"}}This system could not be {{.Action}}{{else if .Remaining}}This system will be {{.Action}} in {{duration .Remaining}} ({{.Deadline.Format "15:04 MST"}}){{else}}This system is being {{.Action}} now{{end}}: {{.Reason}}.
+{{if and .Remaining .Postpone (ne .Kind "failure")}}Save your work, or run '{{.Postpone}}' to postpone.
+{{end}}`
+
+// Funcs are the functions available to message templates.
+var Funcs = template.FuncMap{
+	"duration": Humanize,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// ParseTemplate parses a message template, with Funcs available.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(Funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+// Render executes the template on the message.
+func Render(t *template.Template, m Message) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, m); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+// Humanize returns a duration in words, rounded to the minute above one
+// minute and to the second below, e.g. "10 minutes" or "1 hour 5 minutes".
+func Humanize(d time.Duration) string {
+	if d <= 0 {
+		return "now"
+	}
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	if d < time.Minute {
+		return plural(int(d.Round(time.Second)/time.Second), "second")
+	}
+	d = d.Round(time.Minute)
+	var parts []string
+	if h := int(d / time.Hour); h > 0 {
+		parts = append(parts, plural(h, "hour"))
+	}
+	if m := int(d % time.Hour / time.Minute); m > 0 {
+		parts = append(parts, plural(m, "minute"))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Countdown tracks which warnings of a countdown have been sent: a warning
+// is due when the time remaining before the power action drops to one of
+// the lead times, and is sent once per idle period.
+type Countdown struct {
+	at   []time.Duration
+	sent int
+}
+
+// NewCountdown returns a countdown with warnings at the given lead times
+// before the power action (e.g. 10m, 5m, 1m).
+func NewCountdown(at ...time.Duration) *Countdown {
+	at = slices.Clone(at)
+	slices.Sort(at)
+	slices.Reverse(at)
+	return &Countdown{at: at}
+}
+
+// Due returns whether a warning is due given the time remaining before the
+// power action; when several lead times have been crossed since the last
+// call, a single warning is due.
+func (c *Countdown) Due(remaining time.Duration) bool {
+	due := false
+	for c.sent < len(c.at) && remaining <= c.at[c.sent] {
+		c.sent++
+		due = true
+	}
+	return due
+}
+
+// Reset restarts the countdown, e.g. when activity resumes.
+func (c *Countdown) Reset() {
+	c.sent = 0
+}
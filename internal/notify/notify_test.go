@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+)
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		input time.Duration
+		want  string
+	}{
+		{0, "now"},
+		{-time.Second, "now"},
+		{time.Second, "1 second"},
+		{45 * time.Second, "45 seconds"},
+		{time.Minute, "1 minute"},
+		{9*time.Minute + 40*time.Second, "10 minutes"},
+		{time.Hour, "1 hour"},
+		{2*time.Hour + 5*time.Minute, "2 hours 5 minutes"},
+	}
+	for _, tt := range tests {
+		if got := Humanize(tt.input); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCountdown(t *testing.T) {
+	c := NewCountdown(time.Minute, 10*time.Minute, 5*time.Minute)
+	steps := []struct {
+		remaining time.Duration
+		want      bool
+	}{
+		{15 * time.Minute, false},
+		{10 * time.Minute, true},
+		{9 * time.Minute, false},
+		// both the 5m and 1m lead times were crossed: a single warning
+		{30 * time.Second, true},
+		{0, false},
+	}
+	for i, step := range steps {
+		if got := c.Due(step.remaining); got != step.want {
+			t.Errorf("step %d (%v): expected %v, got %v", i, step.remaining, step.want, got)
+		}
+	}
+	c.Reset()
+	if !c.Due(8 * time.Minute) {
+		t.Errorf("expected a warning after reset")
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl, err := ParseTemplate("warning message", DefaultTemplate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := Message{
+		Host:      "devbox",
+		Action:    "powered off",
+		Remaining: 5 * time.Minute,
+		Deadline:  time.Date(2026, 1, 5, 17, 35, 0, 0, time.UTC),
+		Reason:    "no user activity since 17:00",
+		Postpone:  "slumberd inhibit",
+	}
+	tests := []struct {
+		name string
+		m    Message
+		want string
+	}{
+		{"countdown", m, "This system will be powered off in 5 minutes (17:35 UTC): no user activity since 17:00.\nSave your work, or run 'slumberd inhibit' to postpone.\n"},
+		{"now", func() Message { m := m; m.Remaining = 0; return m }(), "This system is being powered off now: no user activity since 17:00.\n"},
+		{"failure", func() Message { m := m; m.Kind = Failure; m.Reason = "not permitted by polkit"; return m }(), "This system could not be powered off: not permitted by polkit.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tmpl, tt.m)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := ParseTemplate("warning message", "{{.Remaining"); err == nil {
+		t.Errorf("expected an error for an invalid template")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	m := Message{
+		Host:      "devbox",
+		UserID:    "jdoe",
+		Action:    "powered off",
+		From:      idle.Active,
+		To:        idle.Idle,
+		Idle:      20 * time.Minute,
+		Remaining: 40 * time.Minute,
+		Reason:    "no user activity since 07:00",
+	}
+	tests := []struct {
+		kind Kind
+		want string
+	}{
+		{Transition, "devbox (jdoe): active -> idle: no user activity since 07:00 (idle 20 minutes, powered off in 40 minutes)"},
+		{Warning, "devbox (jdoe) will be powered off in 40 minutes: no user activity since 07:00"},
+		{Action, "devbox (jdoe) is being powered off: no user activity since 07:00"},
+		{Failure, "devbox (jdoe) could not be powered off: no user activity since 07:00"},
+	}
+	for _, tt := range tests {
+		m.Kind = tt.kind
+		if got := m.Summary(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.kind, tt.want, got)
+		}
+	}
+}
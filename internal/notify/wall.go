@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+// Wall broadcasts messages, like wall(1), to every pseudo-terminal under
+// /dev/pts owned by a user with a logind session, so that users reach the
+// message in all their shells, including those in tmux or editor terminals.
+type Wall struct {
+	// Dev is the device directory, usually /dev.
+	Dev string
+	// Sessions is the logind sessions directory.
+	Sessions fs.FS
+	// Template renders the message text.
+	Template *template.Template
+	// Now returns the current time, for the banner of messages without a
+	// time.
+	Now func() time.Time
+}
+
+// Name returns the name of the notifier.
+func (w *Wall) Name() string {
+	return "wall"
+}
+
+// Notify writes warnings, power actions and their failures to the terminals
+// of the logged in users, ignoring transitions; it returns an error only if
+// the message could be delivered to none of them.
+func (w *Wall) Notify(ctx context.Context, m Message) error {
+	if m.Kind == Transition {
+		return nil
+	}
+	text, err := Render(w.Template, m)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := detect.Sessions(w.Sessions)
+	if err != nil {
+		return fmt.Errorf("failed to read sessions: %w", err)
+	}
+	uids := map[uint32]bool{}
+	for _, s := range sessions {
+		if !strings.HasPrefix(s.Class, "user") || s.State == "closing" {
+			continue
+		}
+		if uid, err := strconv.ParseUint(s.UID, 10, 32); err == nil {
+			uids[uint32(uid)] = true
+		}
+	}
+	if len(uids) == 0 {
+		slog.Debug("no logged in users to warn")
+		return nil
+	}
+
+	ttys, err := w.terminals(uids)
+	if err != nil {
+		return err
+	}
+	banner := w.banner(m.Host, m.Time, text)
+	var errs []error
+	delivered := 0
+	for _, tty := range ttys {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		if err := writeTerminal(tty, banner); err != nil {
+			slog.Warn("error writing to terminal", "tty", tty, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.Debug("warning written to terminal", "tty", tty)
+		delivered++
+	}
+	if delivered == 0 && len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// terminals returns the pseudo-terminals owned by the given users.
+func (w *Wall) terminals(uids map[uint32]bool) ([]string, error) {
+	dir := filepath.Join(w.Dev, "pts")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var ttys []string
+	for _, entry := range entries {
+		// skip ptmx
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if uid, ok := owner(info); ok && uids[uid] {
+			ttys = append(ttys, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return ttys, nil
+}
+
+// banner frames the text like wall(1) does, with carriage returns so that it
+// displays correctly on terminals in raw mode.
+func (w *Wall) banner(host string, at time.Time, text string) []byte {
+	if at.IsZero() {
+		at = time.Now()
+		if w.Now != nil {
+			at = w.Now()
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\r\n\aBroadcast message from slumberd@%s (%s):\r\n\r\n", host, at.Format("Mon Jan 2 15:04:05 2006"))
+	b.WriteString(strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// openPTY opens a pseudo-terminal and returns its master side and the path
+// of its slave side.
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		t.Fatalf("cannot unlock pseudo-terminal: %v", errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		t.Fatalf("cannot get pseudo-terminal number: %v", errno)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestWriteTerminalUnread(t *testing.T) {
+	_, pts := openPTY(t)
+	// the slave must stay open for the terminal to keep its buffered output
+	slave, err := os.OpenFile(pts, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	// nobody reads the terminal, so its buffer fills up
+	banner := bytes.Repeat([]byte("x"), 1<<20)
+	done := make(chan error, 1)
+	go func() {
+		done <- writeTerminal(pts, banner)
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error writing to a full terminal")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("writing to a full terminal blocked")
+	}
+}
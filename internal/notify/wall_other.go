@@ -0,0 +1,18 @@
+//go:build !unix
+
+package notify
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// owner is not supported on this platform.
+func owner(info fs.FileInfo) (uint32, bool) {
+	return 0, false
+}
+
+// writeTerminal is not supported on this platform.
+func writeTerminal(path string, data []byte) error {
+	return errors.New("terminal broadcasts are not supported on this platform")
+}
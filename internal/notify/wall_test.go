@@ -0,0 +1,79 @@
+//go:build unix
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWall(t *testing.T) {
+	dev := t.TempDir()
+	pts := filepath.Join(dev, "pts")
+	if err := os.Mkdir(pts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"0", "1", "ptmx"} {
+		if err := os.WriteFile(filepath.Join(pts, name), nil, 0620); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a terminal owned by another user, when the test can create one
+	other := os.Chown(filepath.Join(pts, "1"), os.Getuid()+1, -1) == nil
+
+	tmpl, err := ParseTemplate("warning message", DefaultTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wall := &Wall{
+		Dev: dev,
+		Sessions: fstest.MapFS{
+			"3":     {Data: fmt.Appendf(nil, "UID=%d\nUSER=developer\nSTATE=active\nCLASS=user\nTTY=pts/0\n", os.Getuid())},
+			"3.ref": {Data: nil},
+			"c1":    {Data: fmt.Appendf(nil, "UID=%d\nUSER=gdm\nSTATE=online\nCLASS=greeter\n", os.Getuid()+2)},
+		},
+		Template: tmpl,
+		Now:      func() time.Time { return time.Date(2026, 1, 5, 17, 30, 0, 0, time.UTC) },
+	}
+	err = wall.Notify(context.Background(), Message{
+		Host:      "devbox",
+		Action:    "powered off",
+		Remaining: 5 * time.Minute,
+		Deadline:  time.Date(2026, 1, 5, 17, 35, 0, 0, time.UTC),
+		Reason:    "no user activity since 17:00",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(pts, "0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\r\n\aBroadcast message from slumberd@devbox (Mon Jan 5 17:30:00 2026):\r\n\r\nThis system will be powered off in 5 minutes (17:35 UTC): no user activity since 17:00.\r\n\r\n"
+	if string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+	for _, name := range []string{"1", "ptmx"} {
+		if name == "1" && !other {
+			continue
+		}
+		if data, _ := os.ReadFile(filepath.Join(pts, name)); len(data) != 0 {
+			t.Errorf("unexpected message on %s: %q", name, data)
+		}
+	}
+}
+
+func TestWallNoUsers(t *testing.T) {
+	tmpl, _ := ParseTemplate("warning message", DefaultTemplate)
+	// the device directory is not even read without logged in users
+	wall := &Wall{Dev: filepath.Join(t.TempDir(), "missing"), Sessions: fstest.MapFS{}, Template: tmpl}
+	if err := wall.Notify(context.Background(), Message{Action: "powered off"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
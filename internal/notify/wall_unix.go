@@ -0,0 +1,44 @@
+//go:build unix
+
+package notify
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+// owner returns the user id owning the file.
+func owner(info fs.FileInfo) (uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return stat.Uid, true
+}
+
+// writeTerminal writes to the terminal without making it the controlling
+// terminal of the daemon and without blocking on a terminal whose output is
+// suspended (e.g. with Ctrl-S): the writes go straight to the descriptor
+// rather than through the runtime poller, which would wait for the terminal
+// to become writable, and a full terminal is a failure.
+func writeTerminal(path string, data []byte) error {
+	fd, err := syscall.Open(path, syscall.O_WRONLY|syscall.O_APPEND|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: path, Err: err}
+	}
+	defer syscall.Close(fd)
+	for len(data) > 0 {
+		n, err := syscall.Write(fd, data)
+		switch {
+		case err == syscall.EINTR:
+			continue
+		case err == syscall.EAGAIN:
+			return &fs.PathError{Op: "write", Path: path, Err: errors.New("terminal output suspended or full")}
+		case err != nil:
+			return &fs.PathError{Op: "write", Path: path, Err: err}
+		}
+		data = data[n:]
+	}
+	return nil
+}
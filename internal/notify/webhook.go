@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// Format is the format of the body of webhook requests.
+type Format string
+
+const (
+	// FormatJSON posts the message as a JSON object.
+	FormatJSON Format = "json"
+	// FormatSlack posts a Slack incoming webhook payload.
+	FormatSlack Format = "slack"
+	// FormatMattermost posts a Mattermost incoming webhook payload.
+	FormatMattermost Format = "mattermost"
+	// FormatTeams posts a Microsoft Teams connector card.
+	FormatTeams Format = "teams"
+)
+
+// templates are the body templates of the chat formats.
+var templates = map[Format]string{
+	FormatSlack:      `{"text": {{json .Summary}}}`,
+	FormatMattermost: `{"username": "slumberd", "text": {{json .Summary}}}`,
+	FormatTeams: `{"@type": "MessageCard", "@context": "https://schema.org/extensions", ` +
+		`"themeColor": "{{if eq .Kind "action"}}D13438{{else if eq .Kind "warning"}}FFB900{{else}}0078D7{{end}}", ` +
+		`"summary": {{json .Summary}}, "title": {{json (printf "slumberd@%s" .Host)}}, "text": {{json .Summary}}}`,
+}
+
+const (
+	// DefaultWebhookTimeout is the default timeout of a webhook request.
+	DefaultWebhookTimeout = 10 * time.Second
+	// DefaultWebhookRetries is the default number of times a failed webhook
+	// request is retried.
+	DefaultWebhookRetries = 3
+)
+
+// Webhook posts messages to an HTTP endpoint, either as JSON objects or in
+// the format of a chat service (Slack, Mattermost, Teams), retrying with
+// exponential backoff on network errors and server errors.
+type Webhook struct {
+	// URL is the endpoint messages are posted to.
+	URL string `json:"url" yaml:"url"`
+	// Format is the format of the body, "json" (the default), "slack",
+	// "mattermost" or "teams".
+	Format Format `json:"format,omitempty" yaml:"format,omitempty"`
+	// Template, if set, renders the body instead of the format.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Events are the kinds of events posted; all of them if empty.
+	Events []Kind `json:"events,omitempty" yaml:"events,omitempty"`
+	// Headers are added to the requests, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// Timeout bounds each request.
+	Timeout *timex.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Retries is the number of times a failed request is retried.
+	Retries *int `json:"retries,omitempty" yaml:"retries,omitempty"`
+
+	host     string
+	template *template.Template
+	client   *http.Client
+	// backoff is the delay before the first retry, doubled on every retry.
+	backoff time.Duration
+}
+
+// Validate checks the webhook, fills in the defaults and prepares it for use.
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", w.URL)
+	}
+	w.host = u.Host
+	if w.Format == "" {
+		w.Format = FormatJSON
+	}
+	for _, kind := range w.Events {
+		if kind != Transition && kind != Warning && kind != Action && kind != Failure {
+			return fmt.Errorf("invalid webhook event %q", kind)
+		}
+	}
+	text := w.Template
+	if text == "" && w.Format != FormatJSON {
+		var ok bool
+		if text, ok = templates[w.Format]; !ok {
+			return fmt.Errorf("invalid webhook format %q", w.Format)
+		}
+	}
+	if text != "" {
+		if w.template, err = ParseTemplate("webhook "+string(w.Format), text); err != nil {
+			return err
+		}
+	}
+	if w.Timeout == nil {
+		timeout := timex.Duration(DefaultWebhookTimeout)
+		w.Timeout = &timeout
+	}
+	if *w.Timeout <= 0 {
+		return fmt.Errorf("invalid webhook timeout %s", w.Timeout)
+	}
+	if w.Retries == nil {
+		retries := DefaultWebhookRetries
+		w.Retries = &retries
+	}
+	if *w.Retries < 0 {
+		return fmt.Errorf("invalid webhook retries %d", *w.Retries)
+	}
+	w.client = &http.Client{Timeout: time.Duration(*w.Timeout)}
+	if w.backoff == 0 {
+		w.backoff = time.Second
+	}
+	return nil
+}
+
+// Name returns the name of the notifier; the path of the URL is left out,
+// as it often embeds a secret token.
+func (w *Webhook) Name() string {
+	return "webhook " + w.host
+}
+
+// Budget returns the time it takes to make every attempt at posting a
+// message, each of them timing out, with the delays between them.
+func (w *Webhook) Budget() time.Duration {
+	budget := time.Duration(*w.Timeout) * time.Duration(*w.Retries+1)
+	delay := w.backoff
+	for range *w.Retries {
+		budget += delay
+		delay *= 2
+	}
+	return budget
+}
+
+// Notify posts the message, if its kind is among the events of the webhook;
+// it gives up when the retries are exhausted or the context is done.
+func (w *Webhook) Notify(ctx context.Context, m Message) error {
+	if len(w.Events) > 0 && !slices.Contains(w.Events, m.Kind) {
+		return nil
+	}
+	body, err := w.body(m)
+	if err != nil {
+		return err
+	}
+	delay := w.backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil {
+			slog.Debug("message posted to webhook", "webhook", w.host, "kind", m.Kind)
+			return nil
+		}
+		var status *statusError
+		if attempt >= *w.Retries || (errors.As(err, &status) && !status.temporary()) {
+			return err
+		}
+		slog.Warn("error posting to webhook, retrying", "webhook", w.host, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up posting to webhook: %w", errors.Join(err, ctx.Err()))
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// body renders the body of the request.
+func (w *Webhook) body(m Message) ([]byte, error) {
+	if w.template == nil {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal message: %w", err)
+		}
+		return data, nil
+	}
+	text, err := Render(w.template, m)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(text), nil
+}
+
+// post makes a single request.
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "slumberd")
+	for key, value := range w.Headers {
+		request.Header.Set(key, value)
+	}
+	response, err := w.client.Do(request)
+	if err != nil {
+		// strip the URL from the error, it may embed a secret token
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("failed to post to webhook %s: %w", w.host, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		text, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return &statusError{code: response.StatusCode, body: string(bytes.TrimSpace(text))}
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	return nil
+}
+
+// statusError is an unsuccessful response from the webhook.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("webhook responded %d %s", e.code, http.StatusText(e.code))
+	}
+	return fmt.Sprintf("webhook responded %d %s: %s", e.code, http.StatusText(e.code), e.body)
+}
+
+// temporary returns whether the request may succeed if retried.
+func (e *statusError) temporary() bool {
+	return e.code >= 500 || e.code == http.StatusTooManyRequests || e.code == http.StatusRequestTimeout
+}
@@ -0,0 +1,283 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/timex"
+)
+
+// endpoint is a webhook endpoint recording the requests it receives, and
+// failing the first ones with the given status codes.
+type endpoint struct {
+	lock     sync.Mutex
+	failures []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.bodies = append(e.bodies, string(body))
+	e.headers = append(e.headers, r.Header.Clone())
+	if len(e.failures) > 0 {
+		code := e.failures[0]
+		e.failures = e.failures[1:]
+		http.Error(w, "failure", code)
+	}
+}
+
+func (e *endpoint) requests() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.bodies)
+}
+
+func testMessage() Message {
+	return Message{
+		Kind:       Warning,
+		Time:       time.Date(2025, 3, 10, 7, 34, 0, 0, time.UTC),
+		Host:       "devbox",
+		UserID:     "jdoe",
+		Action:     "powered off",
+		To:         idle.Warning,
+		Idle:       50 * time.Minute,
+		Remaining:  10 * time.Minute,
+		Deadline:   time.Date(2025, 3, 10, 7, 44, 0, 0, time.UTC),
+		LastActive: time.Date(2025, 3, 10, 6, 44, 0, 0, time.UTC),
+		Reason:     "no user activity since 06:44",
+	}
+}
+
+func testWebhook(t *testing.T, w *Webhook) *Webhook {
+	t.Helper()
+	w.backoff = time.Millisecond
+	if err := w.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return w
+}
+
+func TestWebhookFormats(t *testing.T) {
+	tests := []struct {
+		format   Format
+		template string
+		want     map[string]any
+	}{
+		{
+			format: FormatJSON,
+			want: map[string]any{
+				"kind":      "warning",
+				"host":      "devbox",
+				"user_id":   "jdoe",
+				"to":        "warning",
+				"idle":      "50m0s",
+				"remaining": "10m0s",
+				"reason":    "no user activity since 06:44",
+			},
+		},
+		{
+			format: FormatSlack,
+			want:   map[string]any{"text": "devbox (jdoe) will be powered off in 10 minutes: no user activity since 06:44"},
+		},
+		{
+			format: FormatMattermost,
+			want:   map[string]any{"username": "slumberd", "text": "devbox (jdoe) will be powered off in 10 minutes: no user activity since 06:44"},
+		},
+		{
+			format: FormatTeams,
+			want: map[string]any{
+				"@type":      "MessageCard",
+				"themeColor": "FFB900",
+				"title":      "slumberd@devbox",
+				"text":       "devbox (jdoe) will be powered off in 10 minutes: no user activity since 06:44",
+			},
+		},
+		{
+			template: `{"host": {{json .Host}}, "in": {{json (duration .Remaining)}}}`,
+			want:     map[string]any{"host": "devbox", "in": "10 minutes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format)+tt.template, func(t *testing.T) {
+			e := &endpoint{}
+			server := httptest.NewServer(e)
+			defer server.Close()
+			w := testWebhook(t, &Webhook{
+				URL:      server.URL + "/hooks/secret",
+				Format:   tt.format,
+				Template: tt.template,
+				Headers:  map[string]string{"Authorization": "Bearer token"},
+			})
+			if err := w.Notify(context.Background(), testMessage()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e.requests() != 1 {
+				t.Fatalf("expected 1 request, got %d", e.requests())
+			}
+			if got := e.headers[0].Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected JSON content type, got %q", got)
+			}
+			if got := e.headers[0].Get("Authorization"); got != "Bearer token" {
+				t.Errorf("expected authorization header, got %q", got)
+			}
+			var got map[string]any
+			if err := json.Unmarshal([]byte(e.bodies[0]), &got); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", e.bodies[0], err)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s: expected %v, got %v", key, value, got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures []int
+		retries  int
+		requests int
+		fail     bool
+	}{
+		{"success", nil, 3, 1, false},
+		{"server error", []int{500, 503}, 3, 3, false},
+		{"too many requests", []int{429}, 3, 2, false},
+		{"retries exhausted", []int{500, 500, 500}, 2, 3, true},
+		{"no retries", []int{502}, 0, 1, true},
+		{"client error", []int{400, 500}, 3, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &endpoint{failures: tt.failures}
+			server := httptest.NewServer(e)
+			defer server.Close()
+			w := testWebhook(t, &Webhook{URL: server.URL, Retries: &tt.retries})
+			err := w.Notify(context.Background(), testMessage())
+			if tt.fail != (err != nil) {
+				t.Errorf("expected failure %v, got %v", tt.fail, err)
+			}
+			if e.requests() != tt.requests {
+				t.Errorf("expected %d requests, got %d", tt.requests, e.requests())
+			}
+		})
+	}
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	timeout := timex.Duration(20 * time.Millisecond)
+	w := testWebhook(t, &Webhook{URL: server.URL + "/hooks/secret", Timeout: &timeout})
+	w.backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := w.Notify(ctx, testMessage())
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the context to bound the retries, took %s", elapsed)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the URL to be left out of the error, got %v", err)
+	}
+}
+
+func TestWebhookRetryAfterTimeout(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests int
+	)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests++
+		first := requests == 1
+		lock.Unlock()
+		if first {
+			// the first attempt hangs until it times out
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	timeout := timex.Duration(50 * time.Millisecond)
+	w := testWebhook(t, &Webhook{URL: server.URL, Timeout: &timeout})
+	if budget := w.Budget(); budget != 4*50*time.Millisecond+7*time.Millisecond {
+		t.Errorf("unexpected budget %s", budget)
+	}
+	// the dispatcher timeout alone leaves no time to retry
+	d := NewDispatcher(time.Duration(timeout), w)
+	d.Send(testMessage())
+	if err := d.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if requests != 2 {
+		t.Errorf("expected the webhook to be retried once, got %d requests", requests)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	e := &endpoint{}
+	server := httptest.NewServer(e)
+	defer server.Close()
+	w := testWebhook(t, &Webhook{URL: server.URL, Events: []Kind{Action}})
+	m := testMessage()
+	for _, kind := range []Kind{Transition, Warning, Action} {
+		m.Kind = kind
+		if err := w.Notify(context.Background(), m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if e.requests() != 1 || !strings.Contains(e.bodies[0], `"kind":"action"`) {
+		t.Errorf("expected only the action to be posted, got %v", e.bodies)
+	}
+}
+
+func TestWebhookValidate(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		name    string
+		webhook Webhook
+	}{
+		{"no URL", Webhook{}},
+		{"bad scheme", Webhook{URL: "ftp://example.com"}},
+		{"bad format", Webhook{URL: "https://example.com", Format: "irc"}},
+		{"bad template", Webhook{URL: "https://example.com", Template: "{{.Host"}},
+		{"bad event", Webhook{URL: "https://example.com", Events: []Kind{"boot"}}},
+		{"bad retries", Webhook{URL: "https://example.com", Retries: &negative}},
+	}
+	for _, tt := range tests {
+		if err := tt.webhook.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package power
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultWakeAlarm is the sysfs file of the RTC wake alarm.
+const DefaultWakeAlarm = "/sys/class/rtc/rtc0/wakealarm"
+
+// Alarm is the RTC wake alarm, which wakes the system up from suspend,
+// hibernation and, on most hardware, power off.
+type Alarm struct {
+	// Path is the wakealarm sysfs file; DefaultWakeAlarm if empty.
+	Path string
+}
+
+func (a *Alarm) path() string {
+	if a.Path == "" {
+		return DefaultWakeAlarm
+	}
+	return a.Path
+}
+
+// Set programs the alarm to go off at the given time, replacing any alarm
+// already set, since the kernel refuses to overwrite it.
+func (a *Alarm) Set(t time.Time) error {
+	if err := a.Clear(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(a.path(), []byte(strconv.FormatInt(t.Unix(), 10)), 0); err != nil {
+		return fmt.Errorf("failed to set wake alarm: %w", err)
+	}
+	return nil
+}
+
+// Clear disables the alarm.
+func (a *Alarm) Clear() error {
+	if err := os.WriteFile(a.path(), []byte("0"), 0); err != nil {
+		return fmt.Errorf("failed to clear wake alarm: %w", err)
+	}
+	return nil
+}
+
+// Get returns when the alarm goes off, or the zero time if it is not set.
+func (a *Alarm) Get() (time.Time, error) {
+	data, err := os.ReadFile(a.path())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read wake alarm: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" || value == "0" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid wake alarm %q: %w", value, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
@@ -0,0 +1,35 @@
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAlarm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wakealarm")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := &Alarm{Path: path}
+	if got, err := a.Get(); err != nil || !got.IsZero() {
+		t.Errorf("expected no alarm, got %v, %v", got, err)
+	}
+	want := time.Date(2026, 1, 6, 7, 50, 0, 0, time.UTC)
+	if err := a.Set(want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.Get(); err != nil || !got.Equal(want) {
+		t.Errorf("expected alarm at %v, got %v, %v", want, got, err)
+	}
+	if err := a.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.Get(); err != nil || !got.IsZero() {
+		t.Errorf("expected no alarm, got %v, %v", got, err)
+	}
+	if err := (&Alarm{Path: filepath.Join(t.TempDir(), "missing", "wakealarm")}).Set(want); err == nil {
+		t.Error("expected error for missing RTC")
+	}
+}
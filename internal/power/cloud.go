@@ -0,0 +1,84 @@
+package power
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultCloudTimeout is how long the cloud has to accept a stop request.
+const DefaultCloudTimeout = time.Minute
+
+// Stopper asks the cloud to stop the instance the daemon runs on.
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// StopperFunc adapts a function to the Stopper interface.
+type StopperFunc func(ctx context.Context) error
+
+// Stop calls f(ctx).
+func (f StopperFunc) Stop(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Cloud powers the system off or puts it to sleep by asking the hypervisor
+// to act on the instance, which, unlike acting from within the guest, lets
+// the cloud release or account for its resources.
+type Cloud struct {
+	// Stopper asks the cloud to act on the instance.
+	Stopper Stopper
+	// Action is the power action that the cloud's action amounts to for the
+	// system: stopping or shelving the instance powers it off, suspending or
+	// pausing it puts it to sleep; PowerOff if empty.
+	Action Action
+	// Timeout bounds the stop request; DefaultCloudTimeout if zero.
+	Timeout time.Duration
+}
+
+// Name returns "cloud".
+func (c *Cloud) Name() string {
+	return "cloud"
+}
+
+// Can checks that the action is the one the cloud's action amounts to, the
+// only one it can take.
+func (c *Cloud) Can(action Action) error {
+	if _, ok := methods[action]; !ok {
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	if expected := c.action(); action != expected {
+		return &UnavailableError{Action: action, Err: fmt.Errorf("%w: the cloud's action on the instance amounts to %s", ErrNotSupported, expected)}
+	}
+	if c.Stopper == nil {
+		return &UnavailableError{Action: action, Err: fmt.Errorf("%w: no cloud configured", ErrNotSupported)}
+	}
+	return nil
+}
+
+// Do asks the cloud to act on the instance.
+func (c *Cloud) Do(action Action) error {
+	if err := c.Can(action); err != nil {
+		return err
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCloudTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	slog.Info("requesting power action", "backend", c.Name(), "action", action)
+	if err := c.Stopper.Stop(ctx); err != nil {
+		return &UnavailableError{Action: action, Err: fmt.Errorf("cloud request failed: %w", err)}
+	}
+	return nil
+}
+
+// action returns the power action the cloud's action amounts to.
+func (c *Cloud) action() Action {
+	if c.Action == "" {
+		return PowerOff
+	}
+	return c.Action
+}
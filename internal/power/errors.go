@@ -0,0 +1,71 @@
+package power
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var (
+	// ErrChallenge means that the action needs interactive authentication
+	// (a polkit challenge), which a daemon cannot answer.
+	ErrChallenge = errors.New("interactive authentication required (polkit challenge)")
+	// ErrNotPermitted means that polkit does not permit the action.
+	ErrNotPermitted = errors.New("not permitted by polkit")
+	// ErrNotSupported means that the system does not support the action,
+	// e.g. hibernation without swap space or resume device.
+	ErrNotSupported = errors.New("not supported by the system")
+	// ErrInProgress means that another power action is already in progress.
+	ErrInProgress = errors.New("another power action is in progress")
+	// ErrInhibited means that a logind inhibitor lock blocks the action.
+	ErrInhibited = errors.New("blocked by an inhibitor lock")
+)
+
+// UnavailableError explains why a power action is unavailable.
+type UnavailableError struct {
+	// Action is the unavailable action.
+	Action Action
+	// Err is the reason, one of the Err* values or the underlying error.
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	msg := fmt.Sprintf("cannot %s: %v", e.Action, e.Err)
+	if e.Err == ErrNotSupported && (e.Action == Hibernate || e.Action == HybridSleep) {
+		msg += " (no swap space large enough to hold the memory, or no resume device configured)"
+	}
+	return msg
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// dbusErrors map the names of D-Bus errors returned by logind to reasons.
+var dbusErrors = map[string]error{
+	"org.freedesktop.DBus.Error.InteractiveAuthorizationRequired": ErrChallenge,
+	"org.freedesktop.DBus.Error.AccessDenied":                     ErrNotPermitted,
+	"org.freedesktop.DBus.Error.NotSupported":                     ErrNotSupported,
+	"org.freedesktop.login1.SleepVerbNotSupported":                ErrNotSupported,
+	"org.freedesktop.login1.OperationInProgress":                  ErrInProgress,
+	"org.freedesktop.login1.BlockedByInhibitorLock":               ErrInhibited,
+}
+
+// unavailable turns the error of a logind call into an *UnavailableError,
+// with the reason decoded from the D-Bus error name where possible.
+func unavailable(action Action, err error) error {
+	var derr dbus.Error
+	if errors.As(err, &derr) {
+		if reason, ok := dbusErrors[derr.Name]; ok {
+			return &UnavailableError{Action: action, Err: reason}
+		}
+	}
+	var pderr *dbus.Error
+	if errors.As(err, &pderr) {
+		if reason, ok := dbusErrors[pderr.Name]; ok {
+			return &UnavailableError{Action: action, Err: reason}
+		}
+	}
+	return &UnavailableError{Action: action, Err: err}
+}
@@ -0,0 +1,203 @@
+package power
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Systemctl takes power actions with systemctl, for systems running systemd
+// where logind cannot be reached over D-Bus.
+type Systemctl struct {
+	// Command is the systemctl executable; "systemctl" if empty.
+	Command string
+	// Kernel, if set, is checked for support of the action.
+	Kernel *Kernel
+}
+
+// Name returns "systemctl".
+func (s *Systemctl) Name() string {
+	return "systemctl"
+}
+
+func (s *Systemctl) command() string {
+	if s.Command == "" {
+		return "systemctl"
+	}
+	return s.Command
+}
+
+// Can checks that systemctl is there and that the kernel supports the action.
+func (s *Systemctl) Can(action Action) error {
+	if _, ok := methods[action]; !ok {
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	if _, err := exec.LookPath(s.command()); err != nil {
+		return &UnavailableError{Action: action, Err: fmt.Errorf("%w: %v", ErrNotSupported, err)}
+	}
+	if s.Kernel != nil {
+		if err := s.Kernel.Supports(action); err != nil {
+			return &UnavailableError{Action: action, Err: err}
+		}
+	}
+	return nil
+}
+
+// Do runs systemctl with the action as verb.
+func (s *Systemctl) Do(action Action) error {
+	if _, ok := methods[action]; !ok {
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	slog.Info("requesting power action", "backend", s.Name(), "action", action)
+	if err := execute(s.command(), string(action)); err != nil {
+		return &UnavailableError{Action: action, Err: err}
+	}
+	return nil
+}
+
+// Classic powers the system off with the shutdown command, and suspends or
+// hibernates it by writing to /sys/power/state, for minimal images and
+// containers without systemd.
+type Classic struct {
+	// Command is the shutdown executable; "shutdown" if empty.
+	Command string
+	// Sys is the directory sysfs is mounted at; "/sys" if empty.
+	Sys string
+	// Kernel, if set, is checked for support of the action.
+	Kernel *Kernel
+}
+
+// Name returns "shutdown".
+func (c *Classic) Name() string {
+	return "shutdown"
+}
+
+func (c *Classic) command() string {
+	if c.Command == "" {
+		return "shutdown"
+	}
+	return c.Command
+}
+
+func (c *Classic) sys(path string) string {
+	if c.Sys == "" {
+		return filepath.Join("/sys", path)
+	}
+	return filepath.Join(c.Sys, path)
+}
+
+// Can checks that the shutdown command is there to power off, or that the
+// sysfs power interface is writable and the kernel supports the action.
+func (c *Classic) Can(action Action) error {
+	if _, ok := methods[action]; !ok {
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	if action == PowerOff {
+		if _, err := exec.LookPath(c.command()); err != nil {
+			return &UnavailableError{Action: action, Err: fmt.Errorf("%w: %v", ErrNotSupported, err)}
+		}
+		return nil
+	}
+	if err := writable(c.sys("power/state")); err != nil {
+		return &UnavailableError{Action: action, Err: err}
+	}
+	if c.Kernel != nil {
+		if err := c.Kernel.Supports(action); err != nil {
+			return &UnavailableError{Action: action, Err: err}
+		}
+	}
+	return nil
+}
+
+// Do runs "shutdown -h now" to power off; to suspend it writes mem to
+// /sys/power/state, to hibernate disk, and for hybrid sleep it first selects
+// the suspend mode in /sys/power/disk, restoring the previous one afterwards.
+func (c *Classic) Do(action Action) error {
+	slog.Info("requesting power action", "backend", c.Name(), "action", action)
+	var err error
+	switch action {
+	case PowerOff:
+		err = execute(c.command(), "-h", "now")
+	case Suspend:
+		err = write(c.sys("power/state"), "mem")
+	case Hibernate:
+		err = write(c.sys("power/state"), "disk")
+	case HybridSleep:
+		err = c.hybridSleep()
+	default:
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	if err != nil {
+		return &UnavailableError{Action: action, Err: err}
+	}
+	return nil
+}
+
+// hybridSleep selects the suspend mode in /sys/power/disk and hibernates;
+// the mode selected before is restored once the system has resumed, or if
+// hibernating failed, so that later hibernations power the system off again.
+func (c *Classic) hybridSleep() error {
+	disk := c.sys("power/disk")
+	data, err := os.ReadFile(disk)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", disk, err)
+	}
+	previous := selectedMode(string(data))
+	if err := write(disk, "suspend"); err != nil {
+		return err
+	}
+	err = write(c.sys("power/state"), "disk")
+	if previous != "" && previous != "suspend" {
+		// the system may be back from hybrid sleep, which must not be
+		// reported as failed because of this
+		if restoreErr := write(disk, previous); restoreErr != nil {
+			slog.Warn("failed to restore the hibernation mode", "path", disk, "mode", previous, "error", restoreErr)
+		}
+	}
+	return err
+}
+
+// execute runs the command, returning its output in the error if it fails.
+func execute(name string, args ...string) error {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, out)
+		}
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// writable checks that the sysfs file can be written.
+func writable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("%w: %v", ErrNotPermitted, err)
+		}
+		return fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+	return f.Close()
+}
+
+// write writes the value to the sysfs file, as "echo value > path" does,
+// which, for /sys/power/state, returns only after the system has resumed.
+func write(path string, value string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(value); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %q to %s: %w", value, path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,121 @@
+package power
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// script writes an executable shell script appending its arguments to a log
+// file, and returns the paths of both.
+func script(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "command")
+	log := filepath.Join(dir, "log")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"$@\" >>'"+log+"'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path, log
+}
+
+func TestSystemctl(t *testing.T) {
+	command, log := script(t)
+	kernel := &Kernel{Proc: fstest.MapFS{}, Sys: fstest.MapFS{"power/state": {Data: []byte("freeze mem\n")}}}
+	s := &Systemctl{Command: command, Kernel: kernel}
+	action, err := Take(s, []Action{Hibernate, Suspend})
+	if err != nil || action != Suspend {
+		t.Fatalf("expected suspend, got %q, %v", action, err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "suspend\n" {
+		t.Errorf("expected systemctl suspend, got %q", data)
+	}
+	if err := (&Systemctl{Command: filepath.Join(t.TempDir(), "missing")}).Can(PowerOff); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected error wrapping %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestClassic(t *testing.T) {
+	command, log := script(t)
+	sys := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(sys, "power"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(sys, "power", "state")
+	disk := filepath.Join(sys, "power", "disk")
+	if err := os.WriteFile(state, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Classic{Command: command, Sys: sys}
+
+	// the hibernation mode is restored after resuming from hybrid sleep, or
+	// if it failed
+	for _, tt := range []struct {
+		name string
+		mode string
+		fail bool
+		want string
+	}{
+		{name: "resumed", mode: "[platform] shutdown reboot suspend test_resume\n", want: "platform"},
+		{name: "failed", mode: "platform [shutdown] reboot suspend test_resume\n", fail: true, want: "shutdown"},
+		{name: "unknown mode", mode: "\n", want: "suspend"},
+	} {
+		if err := os.WriteFile(disk, []byte(tt.mode), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if tt.fail {
+			// the state cannot be written, even by root
+			os.Remove(state)
+			if err := os.Mkdir(state, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := c.Do(HybridSleep)
+		if tt.fail {
+			os.Remove(state)
+			if err := os.WriteFile(state, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tt.fail != (err != nil) {
+			t.Errorf("%s: expected failure %v, got %v", tt.name, tt.fail, err)
+		}
+		data, err := os.ReadFile(disk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: expected %q in disk, got %q", tt.name, tt.want, data)
+		}
+	}
+	if data, _ := os.ReadFile(state); string(data) != "disk" {
+		t.Errorf("expected %q in state, got %q", "disk", data)
+	}
+
+	if _, err := Take(c, []Action{PowerOff}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != "-h now" {
+		t.Errorf("expected shutdown -h now, got %q", data)
+	}
+
+	c.Sys = filepath.Join(sys, "missing")
+	if err := c.Can(Suspend); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected error wrapping %v, got %v", ErrNotSupported, err)
+	}
+}
@@ -0,0 +1,47 @@
+package power
+
+import "sync"
+
+// Fake is a Backend recording the actions it is asked to take, for tests:
+// actions are available unless Unavailable has a reason for them, and
+// succeed unless Failures has an error for them.
+type Fake struct {
+	// Unavailable are the reasons why actions are unavailable.
+	Unavailable map[Action]error
+	// Failures are the errors actions fail with.
+	Failures map[Action]error
+
+	mu    sync.Mutex
+	taken []Action
+}
+
+// Name returns "fake".
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// Can returns the reason why the action is unavailable, if any.
+func (f *Fake) Can(action Action) error {
+	if err := f.Unavailable[action]; err != nil {
+		return &UnavailableError{Action: action, Err: err}
+	}
+	return nil
+}
+
+// Do records the action and returns its failure, if any.
+func (f *Fake) Do(action Action) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.taken = append(f.taken, action)
+	if err := f.Failures[action]; err != nil {
+		return &UnavailableError{Action: action, Err: err}
+	}
+	return nil
+}
+
+// Taken returns the actions taken so far, including the failed ones.
+func (f *Fake) Taken() []Action {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Action(nil), f.taken...)
+}
@@ -0,0 +1,243 @@
+package power
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kernel reads the kernel interfaces telling whether the system can really
+// hibernate: logind only checks that the kernel supports it and that some
+// swap is there, so hibernation silently fails when the free swap space is too
+// small to hold the memory in use or the kernel does not know where to resume
+// from.
+type Kernel struct {
+	// Proc is the procfs hierarchy, e.g. os.DirFS("/proc").
+	Proc fs.FS
+	// Sys is the sysfs hierarchy, e.g. os.DirFS("/sys").
+	Sys fs.FS
+}
+
+// NewKernel returns a Kernel reading procfs and sysfs from the given
+// directories.
+func NewKernel(proc, sys string) *Kernel {
+	return &Kernel{
+		Proc: os.DirFS(proc),
+		Sys:  os.DirFS(sys),
+	}
+}
+
+// Hibernation is the outcome of the hibernation feasibility check.
+type Hibernation struct {
+	// Memory is the total memory, in bytes (MemTotal in /proc/meminfo).
+	Memory uint64 `json:"memory"`
+	// Used is the memory the hibernation image holds, in bytes: the
+	// anonymous memory (Active(anon) and Inactive(anon) in /proc/meminfo),
+	// since the page cache is dropped rather than saved.
+	Used uint64 `json:"used"`
+	// Swap is the total swap space, in bytes (/proc/swaps).
+	Swap uint64 `json:"swap"`
+	// SwapFree is the swap space not in use, in bytes (/proc/swaps).
+	SwapFree uint64 `json:"swap_free"`
+	// States are the sleep states the kernel supports (/sys/power/state).
+	States []string `json:"states"`
+	// Mode is the selected hibernation mode (/sys/power/disk).
+	Mode string `json:"mode"`
+	// Resume is the device the kernel resumes from, as given by resume= on
+	// the kernel command line or set in /sys/power/resume.
+	Resume string `json:"resume"`
+	// Problems are the reasons why hibernation would fail.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Feasible returns whether hibernation can succeed.
+func (h *Hibernation) Feasible() bool {
+	return len(h.Problems) == 0
+}
+
+// Err returns nil if hibernation can succeed, and otherwise an error
+// wrapping ErrNotSupported with the problems.
+func (h *Hibernation) Err() error {
+	if h.Feasible() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrNotSupported, strings.Join(h.Problems, "; "))
+}
+
+// Hibernation checks whether the system can hibernate: the kernel must
+// support it and not have it disabled, the free swap space must be at least
+// as large as the memory in use (as systemd checks it), and a resume device
+// must be configured.
+func (k *Kernel) Hibernation() *Hibernation {
+	h := &Hibernation{}
+	problem := func(format string, args ...any) {
+		h.Problems = append(h.Problems, fmt.Sprintf(format, args...))
+	}
+
+	if data, err := fs.ReadFile(k.Sys, "power/state"); err != nil {
+		problem("cannot read /sys/power/state: %v", err)
+	} else {
+		h.States = strings.Fields(string(data))
+		if !slices.Contains(h.States, "disk") {
+			problem("kernel does not support hibernation (no disk in /sys/power/state)")
+		}
+	}
+
+	if data, err := fs.ReadFile(k.Sys, "power/disk"); err != nil {
+		problem("cannot read /sys/power/disk: %v", err)
+	} else {
+		h.Mode = selectedMode(string(data))
+		if h.Mode == "" || h.Mode == "disabled" {
+			problem("hibernation is disabled (e.g. by kernel lockdown)")
+		}
+	}
+
+	var memErr error
+	if h.Memory, memErr = meminfo(k.Proc, "MemTotal"); memErr == nil {
+		var active, inactive uint64
+		if active, memErr = meminfo(k.Proc, "Active(anon)"); memErr == nil {
+			inactive, memErr = meminfo(k.Proc, "Inactive(anon)")
+		}
+		h.Used = active + inactive
+	}
+	if memErr != nil {
+		problem("cannot read memory usage: %v", memErr)
+	}
+	swap, used, err := swaps(k.Proc)
+	if err != nil {
+		problem("cannot read swap space: %v", err)
+	}
+	h.Swap, h.SwapFree = swap, swap-used
+	if err == nil && memErr == nil {
+		if swap == 0 {
+			problem("no swap space")
+		} else if h.SwapFree < h.Used {
+			problem("free swap space (%d MiB) smaller than memory in use (%d MiB)", h.SwapFree>>20, h.Used>>20)
+		}
+	}
+
+	var noresume bool
+	if data, err := fs.ReadFile(k.Proc, "cmdline"); err == nil {
+		for _, param := range strings.Fields(string(data)) {
+			if value, ok := strings.CutPrefix(param, "resume="); ok {
+				h.Resume = value
+			}
+			if param == "noresume" {
+				noresume = true
+			}
+		}
+	}
+	if h.Resume == "" {
+		// systemd-hibernate-resume sets it from the EFI HibernateLocation
+		// variable or the initrd configuration
+		if data, err := fs.ReadFile(k.Sys, "power/resume"); err == nil {
+			if device := strings.TrimSpace(string(data)); device != "" && device != "0:0" {
+				h.Resume = device
+			}
+		}
+	}
+	switch {
+	case noresume:
+		problem("resume is disabled (noresume on the kernel command line)")
+	case h.Resume == "":
+		problem("no resume device (no resume= on the kernel command line)")
+	}
+	return h
+}
+
+// Supports checks whether the kernel supports the action, for backends that
+// cannot ask logind: suspending needs mem or freeze in /sys/power/state, and
+// hibernating needs the Hibernation check to pass.
+func (k *Kernel) Supports(action Action) error {
+	switch action {
+	case PowerOff:
+		return nil
+	case Suspend:
+		data, err := fs.ReadFile(k.Sys, "power/state")
+		if err != nil {
+			return fmt.Errorf("%w: cannot read /sys/power/state: %v", ErrNotSupported, err)
+		}
+		states := strings.Fields(string(data))
+		if !slices.Contains(states, "mem") && !slices.Contains(states, "freeze") {
+			return fmt.Errorf("%w: no mem or freeze in /sys/power/state", ErrNotSupported)
+		}
+		return nil
+	case Hibernate, HybridSleep:
+		return k.Hibernation().Err()
+	default:
+		return fmt.Errorf("invalid power action %q", action)
+	}
+}
+
+// selectedMode returns the mode selected in the contents of /sys/power/disk,
+// the one in brackets (e.g. "[platform] shutdown reboot suspend").
+func selectedMode(disk string) string {
+	for _, mode := range strings.Fields(disk) {
+		if strings.HasPrefix(mode, "[") && strings.HasSuffix(mode, "]") {
+			return strings.Trim(mode, "[]")
+		}
+	}
+	return ""
+}
+
+// meminfo returns the value of a /proc/meminfo field, in bytes.
+func meminfo(proc fs.FS, field string) (uint64, error) {
+	data, err := fs.ReadFile(proc, "meminfo")
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || name != field {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			break
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s in meminfo: %w", field, err)
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			n <<= 10
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("no %s in meminfo", field)
+}
+
+// swaps returns the total size of the swap areas in /proc/swaps and how much
+// of it is used, in bytes.
+func swaps(proc fs.FS) (total, used uint64, err error) {
+	data, err := fs.ReadFile(proc, "swaps")
+	if err != nil {
+		return 0, 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// Filename Type Size Used Priority, sizes in KiB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid size of swap area %s: %w", fields[0], err)
+		}
+		n, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid usage of swap area %s: %w", fields[0], err)
+		}
+		total += size << 10
+		used += n << 10
+	}
+	return total, used, nil
+}
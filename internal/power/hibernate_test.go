@@ -0,0 +1,138 @@
+package power
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+const (
+	// 4 GiB of memory, of which 2 GiB anonymous
+	meminfo4G   = "MemTotal:        4194304 kB\nMemFree:         1048576 kB\nActive(anon):    1572864 kB\nInactive(anon):   524288 kB\n"
+	swaps2G     = "Filename\tType\tSize\tUsed\tPriority\n/dev/vda2\tpartition\t2097152\t0\t-2\n"
+	swaps2GUsed = "Filename\tType\tSize\tUsed\tPriority\n/dev/vda2\tpartition\t2097152\t1048576\t-2\n"
+	swaps6G     = "Filename\tType\tSize\tUsed\tPriority\n/dev/vda2\tpartition\t2097152\t0\t-2\n/swapfile\tfile\t4194304\t1024\t-3\n"
+)
+
+func TestHibernation(t *testing.T) {
+	tests := []struct {
+		name     string
+		proc     fstest.MapFS
+		sys      fstest.MapFS
+		swap     uint64
+		free     uint64
+		resume   string
+		problems []string
+	}{
+		{
+			name: "feasible",
+			proc: fstest.MapFS{
+				"meminfo": {Data: []byte(meminfo4G)},
+				"swaps":   {Data: []byte(swaps6G)},
+				"cmdline": {Data: []byte("BOOT_IMAGE=/vmlinuz root=/dev/vda1 resume=UUID=1234 quiet\n")},
+			},
+			sys: fstest.MapFS{
+				"power/state": {Data: []byte("freeze mem disk\n")},
+				"power/disk":  {Data: []byte("[platform] shutdown reboot suspend test_resume\n")},
+			},
+			swap:   6 << 30,
+			free:   6<<30 - 1<<20,
+			resume: "UUID=1234",
+		},
+		{
+			// the page cache is not saved, so that the swap space may be
+			// smaller than the memory
+			name: "swap smaller than memory",
+			proc: fstest.MapFS{
+				"meminfo": {Data: []byte(meminfo4G)},
+				"swaps":   {Data: []byte(swaps2G)},
+				"cmdline": {Data: []byte("resume=/dev/vda2\n")},
+			},
+			sys: fstest.MapFS{
+				"power/state": {Data: []byte("freeze mem disk\n")},
+				"power/disk":  {Data: []byte("[platform] shutdown\n")},
+			},
+			swap:   2 << 30,
+			free:   2 << 30,
+			resume: "/dev/vda2",
+		},
+		{
+			name: "resume from sysfs",
+			proc: fstest.MapFS{
+				"meminfo": {Data: []byte(meminfo4G)},
+				"swaps":   {Data: []byte(swaps6G)},
+				"cmdline": {Data: []byte("root=/dev/vda1\n")},
+			},
+			sys: fstest.MapFS{
+				"power/state":  {Data: []byte("freeze mem disk\n")},
+				"power/disk":   {Data: []byte("[platform] shutdown\n")},
+				"power/resume": {Data: []byte("252:2\n")},
+			},
+			swap:   6 << 30,
+			free:   6<<30 - 1<<20,
+			resume: "252:2",
+		},
+		{
+			name: "little free swap and no resume",
+			proc: fstest.MapFS{
+				"meminfo": {Data: []byte(meminfo4G)},
+				"swaps":   {Data: []byte(swaps2GUsed)},
+				"cmdline": {Data: []byte("root=/dev/vda1\n")},
+			},
+			sys: fstest.MapFS{
+				"power/state":  {Data: []byte("freeze mem disk\n")},
+				"power/disk":   {Data: []byte("[platform] shutdown\n")},
+				"power/resume": {Data: []byte("0:0\n")},
+			},
+			swap: 2 << 30,
+			free: 1 << 30,
+			problems: []string{
+				"free swap space (1024 MiB) smaller than memory in use (2048 MiB)",
+				"no resume device (no resume= on the kernel command line)",
+			},
+		},
+		{
+			name: "unsupported",
+			proc: fstest.MapFS{
+				"meminfo": {Data: []byte(meminfo4G)},
+				"swaps":   {Data: []byte("Filename\tType\tSize\tUsed\tPriority\n")},
+				"cmdline": {Data: []byte("root=/dev/vda1 resume=/dev/vda2 noresume\n")},
+			},
+			sys: fstest.MapFS{
+				"power/state": {Data: []byte("freeze mem\n")},
+				"power/disk":  {Data: []byte("[disabled]\n")},
+			},
+			resume: "/dev/vda2",
+			problems: []string{
+				"kernel does not support hibernation (no disk in /sys/power/state)",
+				"hibernation is disabled (e.g. by kernel lockdown)",
+				"no swap space",
+				"resume is disabled (noresume on the kernel command line)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := (&Kernel{Proc: tt.proc, Sys: tt.sys}).Hibernation()
+			if h.Memory != 4<<30 {
+				t.Errorf("expected memory %d, got %d", 4<<30, h.Memory)
+			}
+			if h.Used != 2<<30 {
+				t.Errorf("expected memory in use %d, got %d", 2<<30, h.Used)
+			}
+			if h.Swap != tt.swap || h.SwapFree != tt.free {
+				t.Errorf("expected swap %d (%d free), got %d (%d free)", tt.swap, tt.free, h.Swap, h.SwapFree)
+			}
+			if h.Resume != tt.resume {
+				t.Errorf("expected resume %q, got %q", tt.resume, h.Resume)
+			}
+			if !slices.Equal(h.Problems, tt.problems) {
+				t.Errorf("expected problems %q, got %q", tt.problems, h.Problems)
+			}
+			if err := h.Err(); (err == nil) != (tt.problems == nil) || (err != nil && !errors.Is(err, ErrNotSupported)) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package power
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dbusDest      = "org.freedesktop.login1"
+	dbusPath      = "/org/freedesktop/login1"
+	dbusInterface = "org.freedesktop.login1.Manager"
+)
+
+// Bus calls the methods of the logind manager; it stands between Logind and
+// the system bus, so that tests can replace the latter.
+type Bus interface {
+	Call(method string, args ...any) ([]any, error)
+}
+
+// SystemBus calls logind over a private connection to the system bus.
+type SystemBus struct{}
+
+// Call calls the logind manager method with the given arguments.
+func (SystemBus) Call(method string, args ...any) ([]any, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
+	}
+	defer conn.Close()
+
+	call := conn.Object(dbusDest, dbus.ObjectPath(dbusPath)).Call(dbusInterface+"."+method, 0, args...)
+	if call.Err != nil {
+		return nil, call.Err
+	}
+	return call.Body, nil
+}
+
+// Logind takes power actions through systemd-logind.
+type Logind struct {
+	// Bus is the bus to logind; the system bus if nil.
+	Bus Bus
+	// Interactive lets polkit ask the user for authentication, which only
+	// makes sense when a user runs the action from a terminal.
+	Interactive bool
+	// Kernel, if set, is checked before hibernating, since logind may report
+	// that the system can hibernate when it cannot.
+	Kernel *Kernel
+}
+
+// Name returns "logind".
+func (l *Logind) Name() string {
+	return "logind"
+}
+
+func (l *Logind) bus() Bus {
+	if l.Bus == nil {
+		return SystemBus{}
+	}
+	return l.Bus
+}
+
+// Can checks whether the action is available, with the logind Can* methods;
+// it returns an *UnavailableError if it is not.
+func (l *Logind) Can(action Action) error {
+	method, ok := methods[action]
+	if !ok {
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	body, err := l.bus().Call("Can" + method)
+	if err != nil {
+		return unavailable(action, err)
+	}
+	var answer string
+	if len(body) == 1 {
+		answer, _ = body[0].(string)
+	}
+	var reason error
+	switch answer {
+	case "yes":
+	case "challenge":
+		if !l.Interactive {
+			reason = ErrChallenge
+		}
+	case "no":
+		reason = ErrNotPermitted
+	case "na":
+		reason = ErrNotSupported
+	default:
+		reason = fmt.Errorf("unexpected answer %v from logind", body)
+	}
+	if reason == nil && l.Kernel != nil && (action == Hibernate || action == HybridSleep) {
+		reason = l.Kernel.Hibernation().Err()
+	}
+	if reason != nil {
+		return &UnavailableError{Action: action, Err: reason}
+	}
+	return nil
+}
+
+// Do takes the action, without checking first whether it is available.
+func (l *Logind) Do(action Action) error {
+	method, ok := methods[action]
+	if !ok {
+		return fmt.Errorf("invalid power action %q", action)
+	}
+	slog.Info("requesting power action", "action", action, "interactive", l.Interactive)
+	if _, err := l.bus().Call(method, l.Interactive); err != nil {
+		return unavailable(action, err)
+	}
+	return nil
+}
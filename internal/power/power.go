@@ -1,40 +1,98 @@
+// Package power takes power actions (power off, hibernate, suspend, hybrid
+// sleep) through a Backend (systemd-logind, systemctl, the classic shutdown
+// command and /sys/power/state, or the cloud), checking first which of them
+// are available and falling back along a chain of actions, and explains why
+// an action is unavailable with typed errors.
 package power
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-
-	"github.com/godbus/dbus/v5"
 )
 
+// Action is a power action.
+type Action string
+
 const (
-	dbusDest      = "org.freedesktop.login1"
-	dbusPath      = "/org/freedesktop/login1"
-	dbusInterface = "org.freedesktop.login1.Manager"
+	// PowerOff powers the system off.
+	PowerOff Action = "poweroff"
+	// Hibernate saves the memory to disk and powers the system off.
+	Hibernate Action = "hibernate"
+	// Suspend keeps the memory powered and suspends the system.
+	Suspend Action = "suspend"
+	// HybridSleep saves the memory to disk and suspends the system.
+	HybridSleep Action = "hybrid-sleep"
 )
 
-func Shutdown() error {
-	slog.Info("requesting system shutdown")
-	return callLogind("PowerOff")
+// methods are the logind methods taking the actions.
+var methods = map[Action]string{
+	PowerOff:    "PowerOff",
+	Hibernate:   "Hibernate",
+	Suspend:     "Suspend",
+	HybridSleep: "HybridSleep",
 }
 
-func Hibernate() error {
-	slog.Info("requesting system hibernation")
-	return callLogind("Hibernate")
+// ParseAction parses the name of a power action.
+func ParseAction(value string) (Action, error) {
+	action := Action(value)
+	if _, ok := methods[action]; !ok {
+		return "", fmt.Errorf("invalid power action %q: must be one of poweroff, hibernate, suspend or hybrid-sleep", value)
+	}
+	return action, nil
 }
 
-func callLogind(method string) error {
-	conn, err := dbus.SystemBus()
-	if err != nil {
-		return fmt.Errorf("failed to connect to system bus: %w", err)
+// Backends are the names of the backends that can be selected.
+var Backends = []string{"logind", "systemctl", "shutdown", "cloud"}
+
+// Backend takes power actions.
+type Backend interface {
+	// Name returns the name of the backend.
+	Name() string
+	// Can checks whether the action is available; it returns an
+	// *UnavailableError if it is not.
+	Can(action Action) error
+	// Do takes the action, without checking first whether it is available.
+	Do(action Action) error
+}
+
+// Choose returns the first action of the chain available through the
+// backend; if none is, the error joins the reasons why each of them is
+// unavailable.
+func Choose(backend Backend, chain []Action) (Action, error) {
+	var errs []error
+	for _, action := range chain {
+		err := backend.Can(action)
+		if err == nil {
+			return action, nil
+		}
+		slog.Debug("power action unavailable", "backend", backend.Name(), "action", action, "error", err)
+		errs = append(errs, err)
 	}
-	defer conn.Close()
+	return "", fmt.Errorf("no power action available through %s: %w", backend.Name(), errors.Join(errs...))
+}
 
-	obj := conn.Object(dbusDest, dbus.ObjectPath(dbusPath))
-	// the boolean argument is for "interactive" (polkit dialog)
-	call := obj.Call(dbusInterface+"."+method, 0, true)
-	if call.Err != nil {
-		return fmt.Errorf("dbus call to %s failed: %w", method, call.Err)
+// Take takes the first action of the chain available through the backend,
+// falling back to the next one if it fails, and returns the action taken.
+func Take(backend Backend, chain []Action) (Action, error) {
+	var errs []error
+	for _, action := range chain {
+		err := backend.Can(action)
+		if err == nil {
+			if err = backend.Do(action); err == nil {
+				return action, nil
+			}
+		}
+		slog.Warn("power action unavailable, falling back", "backend", backend.Name(), "action", action, "error", err)
+		errs = append(errs, err)
 	}
-	return nil
+	return "", fmt.Errorf("no power action could be taken through %s: %w", backend.Name(), errors.Join(errs...))
+}
+
+// Shutdown powers the system off through logind, letting polkit ask for
+// authentication.
+func Shutdown() error {
+	slog.Info("requesting system shutdown")
+	_, err := Take(&Logind{Interactive: true}, []Action{PowerOff})
+	return err
 }
@@ -0,0 +1,210 @@
+package power
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// bus is a fake logind: it answers the Can* methods from a map, fails the
+// actions with the given errors, and records the calls.
+type bus struct {
+	answers map[string]string
+	errors  map[string]error
+	calls   []string
+}
+
+func (b *bus) Call(method string, args ...any) ([]any, error) {
+	b.calls = append(b.calls, method)
+	if err := b.errors[method]; err != nil {
+		return nil, err
+	}
+	if answer, ok := b.answers[method]; ok {
+		return []any{answer}, nil
+	}
+	return nil, nil
+}
+
+func TestParseAction(t *testing.T) {
+	for _, value := range []string{"poweroff", "hibernate", "suspend", "hybrid-sleep"} {
+		if action, err := ParseAction(value); err != nil || string(action) != value {
+			t.Errorf("ParseAction(%q) = %q, %v", value, action, err)
+		}
+	}
+	if _, err := ParseAction("reboot"); err == nil {
+		t.Error("expected error for reboot")
+	}
+}
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		name        string
+		answer      string
+		err         error
+		interactive bool
+		expected    error
+	}{
+		{name: "yes", answer: "yes"},
+		{name: "challenge", answer: "challenge", expected: ErrChallenge},
+		{name: "challenge interactive", answer: "challenge", interactive: true},
+		{name: "no", answer: "no", expected: ErrNotPermitted},
+		{name: "na", answer: "na", expected: ErrNotSupported},
+		{name: "access denied", err: dbus.Error{Name: "org.freedesktop.DBus.Error.AccessDenied"}, expected: ErrNotPermitted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bus{
+				answers: map[string]string{"CanHibernate": tt.answer},
+				errors:  map[string]error{"CanHibernate": tt.err},
+			}
+			err := (&Logind{Bus: b, Interactive: tt.interactive}).Can(Hibernate)
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			var unavailable *UnavailableError
+			if !errors.As(err, &unavailable) || unavailable.Action != Hibernate {
+				t.Fatalf("expected *UnavailableError for hibernate, got %v", err)
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  map[string]string
+		errors   map[string]error
+		chain    []Action
+		expected Action
+		calls    []string
+		reasons  []error
+	}{
+		{
+			name:     "first available",
+			answers:  map[string]string{"CanHibernate": "yes"},
+			chain:    []Action{Hibernate, PowerOff},
+			expected: Hibernate,
+			calls:    []string{"CanHibernate", "Hibernate"},
+		},
+		{
+			name:     "no swap",
+			answers:  map[string]string{"CanHibernate": "na", "CanPowerOff": "yes"},
+			chain:    []Action{Hibernate, PowerOff},
+			expected: PowerOff,
+			calls:    []string{"CanHibernate", "CanPowerOff", "PowerOff"},
+		},
+		{
+			name:     "action fails",
+			answers:  map[string]string{"CanSuspend": "yes", "CanPowerOff": "yes"},
+			errors:   map[string]error{"Suspend": &dbus.Error{Name: "org.freedesktop.login1.SleepVerbNotSupported"}},
+			chain:    []Action{Suspend, PowerOff},
+			expected: PowerOff,
+			calls:    []string{"CanSuspend", "Suspend", "CanPowerOff", "PowerOff"},
+		},
+		{
+			name:    "none available",
+			answers: map[string]string{"CanHybridSleep": "challenge", "CanPowerOff": "no"},
+			chain:   []Action{HybridSleep, PowerOff},
+			calls:   []string{"CanHybridSleep", "CanPowerOff"},
+			reasons: []error{ErrChallenge, ErrNotPermitted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bus{answers: tt.answers, errors: tt.errors}
+			action, err := Take(&Logind{Bus: b}, tt.chain)
+			if action != tt.expected {
+				t.Errorf("expected action %q, got %q", tt.expected, action)
+			}
+			if !slices.Equal(b.calls, tt.calls) {
+				t.Errorf("expected calls %v, got %v", tt.calls, b.calls)
+			}
+			if tt.reasons == nil && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			for _, reason := range tt.reasons {
+				if !errors.Is(err, reason) {
+					t.Errorf("expected error wrapping %v, got %v", reason, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUnavailableError(t *testing.T) {
+	err := &UnavailableError{Action: Hibernate, Err: ErrNotSupported}
+	if !strings.Contains(err.Error(), "swap") {
+		t.Errorf("expected hint about swap space, got %q", err.Error())
+	}
+}
+
+func TestTakeInfeasibleHibernation(t *testing.T) {
+	b := &bus{answers: map[string]string{"CanHibernate": "yes", "CanPowerOff": "yes"}}
+	kernel := &Kernel{Proc: fstest.MapFS{}, Sys: fstest.MapFS{}}
+	action, err := Take(&Logind{Bus: b, Kernel: kernel}, []Action{Hibernate, PowerOff})
+	if err != nil || action != PowerOff {
+		t.Fatalf("expected poweroff, got %q, %v", action, err)
+	}
+	if expected := []string{"CanHibernate", "CanPowerOff", "PowerOff"}; !slices.Equal(b.calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, b.calls)
+	}
+}
+
+func TestFake(t *testing.T) {
+	f := &Fake{
+		Unavailable: map[Action]error{Hibernate: ErrNotSupported},
+		Failures:    map[Action]error{Suspend: ErrInhibited},
+	}
+	if action, err := Choose(f, []Action{Hibernate, Suspend, PowerOff}); err != nil || action != Suspend {
+		t.Errorf("expected suspend to be chosen, got %q, %v", action, err)
+	}
+	action, err := Take(f, []Action{Hibernate, Suspend, PowerOff})
+	if err != nil || action != PowerOff {
+		t.Errorf("expected poweroff to be taken, got %q, %v", action, err)
+	}
+	if expected := []Action{Suspend, PowerOff}; !slices.Equal(f.Taken(), expected) {
+		t.Errorf("expected %v to be taken, got %v", expected, f.Taken())
+	}
+	if _, err := Take(f, []Action{Hibernate}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected error wrapping %v, got %v", ErrNotSupported, err)
+	}
+}
+
+func TestCloud(t *testing.T) {
+	var stopped int
+	c := &Cloud{Stopper: StopperFunc(func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected a deadline on the stop request")
+		}
+		stopped++
+		return nil
+	})}
+	action, err := Take(c, []Action{Hibernate, PowerOff})
+	if err != nil || action != PowerOff || stopped != 1 {
+		t.Errorf("expected the instance to be stopped once, got %q, %v, %d", action, err, stopped)
+	}
+	if err := (&Cloud{}).Can(PowerOff); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected error wrapping %v without stopper, got %v", ErrNotSupported, err)
+	}
+
+	// a paused instance is asleep, not powered off
+	c.Action = Suspend
+	action, err = Take(c, []Action{PowerOff, Suspend})
+	if err != nil || action != Suspend || stopped != 2 {
+		t.Errorf("expected the instance to be paused, got %q, %v, %d", action, err, stopped)
+	}
+	if err := c.Can(PowerOff); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected error wrapping %v, got %v", ErrNotSupported, err)
+	}
+}
@@ -0,0 +1,218 @@
+// Package schedule implements time-based power policies: idle timeouts that
+// depend on the time of day and day of the week, blackout windows during
+// which no power action is taken, a curfew after which the system is
+// powered off regardless of activity, unless explicitly inhibited, and the
+// working hours the system is woken up for.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+)
+
+// Window is a daily time window, optionally restricted to some days of the
+// week; a window whose end precedes its start crosses midnight, and belongs
+// to the day it starts on, while a window whose start and end coincide lasts
+// the whole day.
+type Window struct {
+	Days timex.Weekdays  `json:"days,omitempty" yaml:"days,omitempty"`
+	From timex.TimeOfDay `json:"from,omitempty" yaml:"from,omitempty"`
+	To   timex.TimeOfDay `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+// Contains returns whether the given time, in the schedule location, falls
+// within the window.
+func (w Window) Contains(t time.Time) bool {
+	tod := timex.Of(t)
+	switch {
+	case w.From == w.To:
+		return w.onDay(t.Weekday())
+	case w.From < w.To:
+		return w.onDay(t.Weekday()) && tod >= w.From && tod < w.To
+	default:
+		// crossing midnight: either late on a matching day, or early on the
+		// day after a matching day
+		if tod >= w.From {
+			return w.onDay(t.Weekday())
+		}
+		return tod < w.To && w.onDay((t.Weekday()+6)%7)
+	}
+}
+
+func (w Window) onDay(day time.Weekday) bool {
+	return w.Days.IsEmpty() || w.Days.Contains(day)
+}
+
+// Rule sets the idle timeout during a time window.
+type Rule struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`
+	Window  `yaml:",inline"`
+	Timeout *timex.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// Curfew is the time after which the system is powered off even if there is
+// activity, unless an inhibit is in effect; it is either a time of day
+// (without seconds) on some days of the week, or a cron expression.
+type Curfew struct {
+	Days timex.Weekdays  `json:"days,omitempty" yaml:"days,omitempty"`
+	At   timex.TimeOfDay `json:"at,omitempty" yaml:"at,omitempty"`
+	Cron *timex.Cron     `json:"cron,omitempty" yaml:"cron,omitempty"`
+}
+
+// Schedule is a set of time-based power policies, all expressed in the
+// schedule time zone.
+type Schedule struct {
+	// TimeZone is the IANA name of the time zone (e.g. "Europe/Rome"); the
+	// local time zone is used if empty.
+	TimeZone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	// Rules set the idle timeout by time window; the first matching rule
+	// wins, and the default timeout applies if none matches.
+	Rules []Rule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Blackouts are the windows during which no power action is taken.
+	Blackouts []Window `json:"blackouts,omitempty" yaml:"blackouts,omitempty"`
+	// Holidays are the dates on which windows restricted to some days of the
+	// week are not in effect, as if no day matched.
+	Holidays timex.Dates `json:"holidays,omitempty" yaml:"holidays,omitempty"`
+	// Curfew, if set, forces the power action once the curfew time has
+	// passed on a system that was already up at that time.
+	Curfew *Curfew `json:"curfew,omitempty" yaml:"curfew,omitempty"`
+	// WorkingHours are the windows a suspended system is woken up for.
+	WorkingHours []Window `json:"working_hours,omitempty" yaml:"working_hours,omitempty"`
+
+	location *time.Location
+}
+
+// Validate checks the schedule and loads its time zone.
+func (s *Schedule) Validate() error {
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", s.TimeZone, err)
+	}
+	if s.TimeZone == "" {
+		location = time.Local
+	}
+	s.location = location
+
+	for i, rule := range s.Rules {
+		if rule.Timeout == nil || *rule.Timeout <= 0 {
+			return fmt.Errorf("schedule rule %d (%s) has no or invalid timeout", i+1, rule.Name)
+		}
+	}
+	if s.Curfew != nil && s.Curfew.Cron == nil {
+		// express the time of day as a cron expression, so that both forms
+		// are evaluated alike
+		days := "*"
+		if !s.Curfew.Days.IsEmpty() {
+			var list []string
+			for day := time.Sunday; day <= time.Saturday; day++ {
+				if s.Curfew.Days.Contains(day) {
+					list = append(list, fmt.Sprint(int(day)))
+				}
+			}
+			days = strings.Join(list, ",")
+		}
+		at := time.Duration(s.Curfew.At)
+		if at%time.Minute != 0 {
+			// cron expressions have a resolution of one minute
+			return fmt.Errorf("invalid curfew time %s: seconds are not supported", s.Curfew.At)
+		}
+		cron, err := timex.ParseCron(fmt.Sprintf("%d %d * * %s", int(at%time.Hour/time.Minute), int(at/time.Hour), days))
+		if err != nil {
+			return fmt.Errorf("invalid curfew: %w", err)
+		}
+		s.Curfew.Cron = &cron
+	}
+	return nil
+}
+
+// Location returns the schedule time zone.
+func (s *Schedule) Location() *time.Location {
+	if s.location == nil {
+		return time.Local
+	}
+	return s.location
+}
+
+// Timeout returns the idle timeout in effect at the given time: that of the
+// first matching rule, or the given default if no rule matches.
+func (s *Schedule) Timeout(t time.Time, fallback time.Duration) (time.Duration, string) {
+	t = t.In(s.Location())
+	for i, rule := range s.Rules {
+		if s.contains(rule.Window, t) {
+			name := rule.Name
+			if name == "" {
+				name = fmt.Sprintf("rule %d", i+1)
+			}
+			return time.Duration(*rule.Timeout), name
+		}
+	}
+	return fallback, "default"
+}
+
+// contains returns whether the window contains the given time, taking
+// holidays into account.
+func (s *Schedule) contains(w Window, t time.Time) bool {
+	if !w.Days.IsEmpty() && s.Holidays.Contains(t) {
+		return false
+	}
+	return w.Contains(t)
+}
+
+// InBlackout returns whether the given time falls within a blackout window.
+func (s *Schedule) InBlackout(t time.Time) bool {
+	t = t.In(s.Location())
+	for _, blackout := range s.Blackouts {
+		if s.contains(blackout, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextCurfew returns the first curfew time after the given one, or the zero
+// time if there is no curfew.
+func (s *Schedule) NextCurfew(t time.Time) time.Time {
+	if s.Curfew == nil || s.Curfew.Cron == nil {
+		return time.Time{}
+	}
+	return s.Curfew.Cron.Next(t.In(s.Location()))
+}
+
+// IsCurfew returns whether the curfew is in effect at the given time for a
+// system up since the given time (when it booted or last resumed), that is
+// whether a curfew time has passed since then; a system started after the
+// curfew is left alone until the next one, and so is one whose boot time is
+// unknown.
+func (s *Schedule) IsCurfew(t, up time.Time) bool {
+	if up.IsZero() {
+		return false
+	}
+	curfew := s.NextCurfew(up)
+	return !curfew.IsZero() && !curfew.After(t)
+}
+
+// NextWorkStart returns the first start of working hours after the given
+// time, or the zero time if there are no working hours in the coming year.
+func (s *Schedule) NextWorkStart(t time.Time) time.Time {
+	t = t.In(s.Location())
+	for day := 0; day <= 366; day++ {
+		date := t.AddDate(0, 0, day)
+		var next time.Time
+		for _, w := range s.WorkingHours {
+			if !w.onDay(date.Weekday()) || (!w.Days.IsEmpty() && s.Holidays.Contains(date)) {
+				continue
+			}
+			start := w.From.On(date)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,189 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/timex"
+	"gopkg.in/yaml.v3"
+)
+
+const testSchedule = `
+timezone: Europe/Rome
+rules:
+  - name: working hours
+    days: mon-fri
+    from: "08:00"
+    to: "19:00"
+    timeout: 1h
+  - name: nights
+    from: "22:00"
+    to: "06:00"
+    timeout: 5m
+blackouts:
+  - days: [fri]
+    from: "17:00"
+    to: "18:00"
+holidays: [01-06]
+curfew:
+  days: weekdays
+  at: "23:30"
+working_hours:
+  - days: mon-fri
+    from: "08:00"
+    to: "19:00"
+  - days: [sat]
+    from: "09:30"
+    to: "12:00"
+`
+
+func load(t *testing.T) *Schedule {
+	t.Helper()
+	s := &Schedule{}
+	if err := yaml.Unmarshal([]byte(testSchedule), s); err != nil {
+		t.Fatalf("unexpected error unmarshalling schedule: %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("unexpected error validating schedule: %v", err)
+	}
+	return s
+}
+
+func TestTimeout(t *testing.T) {
+	s := load(t)
+	rome := s.Location()
+
+	tests := []struct {
+		name string
+		time time.Time
+		want time.Duration
+		rule string
+	}{
+		{"working hours", time.Date(2026, 1, 5, 10, 0, 0, 0, rome), time.Hour, "working hours"},
+		{"working hours end is exclusive", time.Date(2026, 1, 5, 19, 0, 0, 0, rome), 15 * time.Minute, "default"},
+		{"saturday morning", time.Date(2026, 1, 10, 10, 0, 0, 0, rome), 15 * time.Minute, "default"},
+		{"late evening", time.Date(2026, 1, 10, 23, 0, 0, 0, rome), 5 * time.Minute, "nights"},
+		{"early morning", time.Date(2026, 1, 11, 5, 59, 0, 0, rome), 5 * time.Minute, "nights"},
+		{"holiday", time.Date(2026, 1, 6, 10, 0, 0, 0, rome), 15 * time.Minute, "default"},
+		{"night on a holiday", time.Date(2026, 1, 6, 23, 0, 0, 0, rome), 5 * time.Minute, "nights"},
+		{"other time zone", time.Date(2026, 1, 5, 8, 30, 0, 0, time.UTC), time.Hour, "working hours"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule := s.Timeout(tt.time, 15*time.Minute)
+			if got != tt.want || rule != tt.rule {
+				t.Errorf("expected %v (%s), got %v (%s)", tt.want, tt.rule, got, rule)
+			}
+		})
+	}
+}
+
+func TestInBlackout(t *testing.T) {
+	s := load(t)
+	rome := s.Location()
+
+	tests := []struct {
+		time time.Time
+		want bool
+	}{
+		{time.Date(2026, 1, 9, 17, 30, 0, 0, rome), true},
+		{time.Date(2026, 1, 9, 18, 0, 0, 0, rome), false},
+		{time.Date(2026, 1, 8, 17, 30, 0, 0, rome), false},
+	}
+	for _, tt := range tests {
+		if got := s.InBlackout(tt.time); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.time, tt.want, got)
+		}
+	}
+}
+
+func TestCurfew(t *testing.T) {
+	s := load(t)
+	rome := s.Location()
+
+	tests := []struct {
+		name string
+		now  time.Time
+		boot time.Time
+		want bool
+	}{
+		{"before curfew", time.Date(2026, 1, 5, 23, 0, 0, 0, rome), time.Date(2026, 1, 5, 8, 0, 0, 0, rome), false},
+		{"after curfew", time.Date(2026, 1, 5, 23, 45, 0, 0, rome), time.Date(2026, 1, 5, 8, 0, 0, 0, rome), true},
+		{"booted after curfew", time.Date(2026, 1, 6, 1, 0, 0, 0, rome), time.Date(2026, 1, 5, 23, 50, 0, 0, rome), false},
+		{"left on over the weekend", time.Date(2026, 1, 10, 12, 0, 0, 0, rome), time.Date(2026, 1, 9, 8, 0, 0, 0, rome), true},
+		{"booted on saturday", time.Date(2026, 1, 11, 23, 45, 0, 0, rome), time.Date(2026, 1, 10, 8, 0, 0, 0, rome), false},
+		{"unknown boot time", time.Date(2026, 1, 5, 23, 45, 0, 0, rome), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsCurfew(tt.now, tt.boot); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCurfewCron(t *testing.T) {
+	s := &Schedule{}
+	if err := yaml.Unmarshal([]byte("timezone: UTC\ncurfew:\n  cron: \"0 20 * * fri\"\n"), s); err != nil {
+		t.Fatalf("unexpected error unmarshalling schedule: %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("unexpected error validating schedule: %v", err)
+	}
+	boot := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)
+	if want, got := time.Date(2026, 1, 9, 20, 0, 0, 0, time.UTC), s.NextCurfew(boot); !got.Equal(want) {
+		t.Errorf("expected next curfew at %v, got %v", want, got)
+	}
+	if s.IsCurfew(time.Date(2026, 1, 9, 19, 59, 0, 0, time.UTC), boot) {
+		t.Errorf("expected no curfew before friday evening")
+	}
+	if !s.IsCurfew(time.Date(2026, 1, 9, 20, 0, 0, 0, time.UTC), boot) {
+		t.Errorf("expected curfew on friday evening")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+	}{
+		{"invalid time zone", Schedule{TimeZone: "Mars/Olympus_Mons"}},
+		{"rule without timeout", Schedule{Rules: []Rule{{Name: "empty"}}}},
+		{"curfew with seconds", Schedule{Curfew: &Curfew{At: timex.TimeOfDay(22*time.Hour + 30*time.Minute + 30*time.Second)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schedule.Validate(); err == nil {
+				t.Errorf("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestNextWorkStart(t *testing.T) {
+	s := load(t)
+	rome := s.Location()
+
+	tests := []struct {
+		name string
+		time time.Time
+		want time.Time
+	}{
+		{"before work", time.Date(2026, 1, 5, 7, 0, 0, 0, rome), time.Date(2026, 1, 5, 8, 0, 0, 0, rome)},
+		{"at work", time.Date(2026, 1, 5, 8, 0, 0, 0, rome), time.Date(2026, 1, 7, 8, 0, 0, 0, rome)},
+		{"friday evening", time.Date(2026, 1, 9, 20, 0, 0, 0, rome), time.Date(2026, 1, 10, 9, 30, 0, 0, rome)},
+		{"saturday afternoon", time.Date(2026, 1, 10, 14, 0, 0, 0, rome), time.Date(2026, 1, 12, 8, 0, 0, 0, rome)},
+		{"other time zone", time.Date(2026, 1, 8, 22, 0, 0, 0, time.UTC), time.Date(2026, 1, 9, 8, 0, 0, 0, rome)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.NextWorkStart(tt.time); !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if got := (&Schedule{}).NextWorkStart(time.Now()); !got.IsZero() {
+		t.Errorf("expected no working hours, got %v", got)
+	}
+}
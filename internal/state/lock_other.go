@@ -0,0 +1,8 @@
+//go:build !unix
+
+package state
+
+// lock is a no-op where advisory file locks are not available.
+func lock(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,182 @@
+// Package state persists the daemon state (last activity, idle lifecycle
+// phase and inhibit records) across daemon restarts and reboots, so that
+// restarting the daemon does not reset the idle clock.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+)
+
+// Inhibit is a request that no power action be taken until a given time.
+type Inhibit struct {
+	// Who is the user that requested the inhibit.
+	Who string `json:"who"`
+	// Why explains why the inhibit was requested.
+	Why string `json:"why,omitempty"`
+	// Since is when the inhibit was requested.
+	Since time.Time `json:"since"`
+	// Until is when the inhibit expires.
+	Until time.Time `json:"until"`
+}
+
+// IsActive returns whether the inhibit is in effect at the given time.
+func (i Inhibit) IsActive(now time.Time) bool {
+	return !now.Before(i.Since) && now.Before(i.Until)
+}
+
+// String returns a one-line description of the inhibit.
+func (i Inhibit) String() string {
+	s := fmt.Sprintf("inhibited by %s until %s", i.Who, i.Until.Format("2006-01-02 15:04:05 MST"))
+	if i.Why != "" {
+		s += ": " + i.Why
+	}
+	return s
+}
+
+// State is the daemon state persisted across restarts.
+type State struct {
+	// BootID identifies the boot the state was saved during.
+	BootID string `json:"boot_id,omitempty"`
+	// LastActive is the time of the last detected activity.
+	LastActive time.Time `json:"last_active"`
+	// Phase is the idle lifecycle state.
+	Phase idle.State `json:"phase"`
+	// Since is when the current phase was entered.
+	Since time.Time `json:"since"`
+	// Inhibits are the inhibit records, including expired ones not yet pruned.
+	Inhibits []Inhibit `json:"inhibits,omitempty"`
+}
+
+// ActiveInhibits returns the inhibits in effect at the given time.
+func (s *State) ActiveInhibits(now time.Time) []Inhibit {
+	var active []Inhibit
+	for _, i := range s.Inhibits {
+		if i.IsActive(now) {
+			active = append(active, i)
+		}
+	}
+	return active
+}
+
+// Prune removes the inhibits that have expired by the given time.
+func (s *State) Prune(now time.Time) {
+	s.Inhibits = slices.DeleteFunc(s.Inhibits, func(i Inhibit) bool {
+		return !now.Before(i.Until)
+	})
+}
+
+// Sanitize adjusts a state loaded from disk so that it can be trusted on the
+// current boot: timestamps in the future are clamped to now, the last activity
+// is never earlier than the boot time, because the time the system spent
+// powered off must not count as idle time, and if the state was saved during
+// a previous boot the lifecycle restarts from the Active phase.
+func (s *State) Sanitize(bootID string, bootTime, now time.Time) {
+	if s.LastActive.After(now) {
+		slog.Warn("last activity is in the future, resetting to now", "last_active", s.LastActive, "now", now)
+		s.LastActive = now
+	}
+	if s.Since.After(now) {
+		s.Since = now
+	}
+	if s.LastActive.Before(bootTime) {
+		s.LastActive = bootTime
+	}
+	if s.BootID != bootID {
+		if s.BootID != "" {
+			slog.Info("state saved during a previous boot, restarting idle clock", "previous", s.BootID, "current", bootID, "boot_time", bootTime)
+		}
+		s.Phase = idle.Active
+		s.Since = s.LastActive
+		s.BootID = bootID
+	}
+	if s.Since.Before(s.LastActive) && s.Phase == idle.Active {
+		s.Since = s.LastActive
+	}
+	s.Prune(now)
+}
+
+// Load reads the state from the given file; a missing file yields an empty
+// state.
+func Load(path string) (*State, error) {
+	s := &State{}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save atomically writes the state to the given file, by writing it to a
+// temporary file in the same directory and renaming it over the target.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", dir, err)
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write state file %s: %w", f.Name(), err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync state file %s: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close state file %s: %w", f.Name(), err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set state file permissions: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Update loads the state from the given file, applies fn to it and saves it
+// back, holding an exclusive lock on the file for the whole sequence so that
+// concurrent updaters (the daemon and the inhibit command) do not lose each
+// other's changes; if fn returns an error, the state is not saved.
+func Update(path string, fn func(s *State) error) error {
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock state file %s: %w", path, err)
+	}
+	defer unlock()
+
+	s, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	return s.Save(path)
+}
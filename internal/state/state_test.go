@@ -0,0 +1,155 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+)
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slumberd", "state.json")
+
+	// a missing file yields an empty state
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if !s.LastActive.IsZero() || len(s.Inhibits) != 0 {
+		t.Errorf("expected empty state, got %+v", s)
+	}
+
+	now := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	want := &State{
+		BootID:     "33ff8457-fecc-4b74-b78f-0fa94d5b70e2",
+		LastActive: now.Add(-10 * time.Minute),
+		Phase:      idle.Warning,
+		Since:      now.Add(-time.Minute),
+		Inhibits: []Inhibit{
+			{Who: "developer", Why: "benchmarks", Since: now, Until: now.Add(time.Hour)},
+		},
+	}
+	if err := want.Save(path); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if got.BootID != want.BootID || !got.LastActive.Equal(want.LastActive) || got.Phase != want.Phase || !got.Since.Equal(want.Since) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if len(got.Inhibits) != 1 || got.Inhibits[0].Who != "developer" || !got.Inhibits[0].Until.Equal(want.Inhibits[0].Until) {
+		t.Errorf("expected %+v, got %+v", want.Inhibits, got.Inhibits)
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the state file, got %v", entries)
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	boot := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)
+	now := boot.Add(2 * time.Hour)
+
+	tests := []struct {
+		name       string
+		state      State
+		lastActive time.Time
+		phase      idle.State
+		inhibits   int
+	}{
+		{
+			name:       "daemon restart",
+			state:      State{BootID: "b", LastActive: now.Add(-10 * time.Minute), Phase: idle.Warning, Since: now.Add(-time.Minute)},
+			lastActive: now.Add(-10 * time.Minute),
+			phase:      idle.Warning,
+		},
+		{
+			name:       "reboot",
+			state:      State{BootID: "a", LastActive: boot.Add(-time.Hour), Phase: idle.Acting, Since: boot.Add(-time.Minute)},
+			lastActive: boot,
+			phase:      idle.Active,
+		},
+		{
+			name:       "reboot after recent activity",
+			state:      State{BootID: "a", LastActive: boot.Add(time.Minute), Phase: idle.Idle, Since: boot.Add(2 * time.Minute)},
+			lastActive: boot.Add(time.Minute),
+			phase:      idle.Active,
+		},
+		{
+			name:       "clock in the future",
+			state:      State{BootID: "b", LastActive: now.Add(time.Hour), Phase: idle.Active, Since: now.Add(time.Hour)},
+			lastActive: now,
+			phase:      idle.Active,
+		},
+		{
+			name: "expired inhibits",
+			state: State{BootID: "b", LastActive: now, Inhibits: []Inhibit{
+				{Who: "a", Since: boot, Until: now.Add(-time.Minute)},
+				{Who: "b", Since: boot, Until: now.Add(time.Minute)},
+			}},
+			lastActive: now,
+			phase:      idle.Active,
+			inhibits:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.state
+			s.Sanitize("b", boot, now)
+			if !s.LastActive.Equal(tt.lastActive) {
+				t.Errorf("expected last activity %v, got %v", tt.lastActive, s.LastActive)
+			}
+			if s.Phase != tt.phase {
+				t.Errorf("expected phase %v, got %v", tt.phase, s.Phase)
+			}
+			if s.BootID != "b" {
+				t.Errorf("expected boot id %q, got %q", "b", s.BootID)
+			}
+			if s.Since.After(now) || (s.Phase == idle.Active && s.Since.Before(s.LastActive)) {
+				t.Errorf("unexpected phase start %v", s.Since)
+			}
+			if len(s.Inhibits) != tt.inhibits {
+				t.Errorf("expected %d inhibits, got %v", tt.inhibits, s.Inhibits)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update(path, func(s *State) error {
+				s.Inhibits = append(s.Inhibits, Inhibit{Who: "developer", Since: now, Until: now.Add(time.Hour)})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("expected nil, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ActiveInhibits(now)) != 10 {
+		t.Errorf("expected 10 active inhibits, got %d", len(s.ActiveInhibits(now)))
+	}
+}
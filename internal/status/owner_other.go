@@ -0,0 +1,27 @@
+//go:build !unix
+
+package status
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// owner is not supported on this platform.
+func owner(info fs.FileInfo) (uint32, bool) {
+	return 0, false
+}
+
+// openRequest opens a request file for reading, failing if it is a symbolic
+// link.
+func openRequest(path string) (*os.File, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%s is a symbolic link", path)
+	}
+	return os.Open(path)
+}
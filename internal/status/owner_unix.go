@@ -0,0 +1,24 @@
+//go:build unix
+
+package status
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// owner returns the user id owning the file.
+func owner(info fs.FileInfo) (uint32, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return stat.Uid, true
+}
+
+// openRequest opens a request file for reading, failing if it is a symbolic
+// link and without blocking if it is a FIFO.
+func openRequest(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+}
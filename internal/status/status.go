@@ -0,0 +1,229 @@
+// Package status publishes the idle lifecycle status of the host in a small
+// world-readable file, for users' shell prompts, and collects the postpone
+// requests users drop in a world-writable spool directory, so that users
+// without administrative rights can postpone the power action.
+package status
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/internal/power"
+)
+
+// DefaultDir is the default runtime directory of the daemon.
+const DefaultDir = "/run/slumberd"
+
+const (
+	// statusFile is the name of the status file in the runtime directory.
+	statusFile = "status"
+	// spoolDir is the name of the postpone spool in the runtime directory.
+	spoolDir = "postpone"
+)
+
+// Status is the status published for users' shell prompts.
+type Status struct {
+	// State is the idle lifecycle state.
+	State idle.State
+	// Deadline is when the power action is due; zero while active.
+	Deadline time.Time
+	// Action is the power action that will be taken; empty while active.
+	Action power.Action
+}
+
+// Remaining returns how long until the power action at the given time, and
+// whether a power action is due at all.
+func (s Status) Remaining(now time.Time) (time.Duration, bool) {
+	if s.Deadline.IsZero() {
+		return 0, false
+	}
+	return max(s.Deadline.Sub(now), 0), true
+}
+
+// Prepare creates the runtime directory and the postpone spool, which any
+// user can write to but only the daemon can list, like a mail drop.
+func Prepare(dir string) error {
+	spool := filepath.Join(dir, spoolDir)
+	if err := os.MkdirAll(spool, 0755); err != nil {
+		return fmt.Errorf("failed to create runtime directory %s: %w", dir, err)
+	}
+	if err := os.Chmod(dir, 0755); err != nil {
+		return fmt.Errorf("failed to set runtime directory permissions: %w", err)
+	}
+	if err := os.Chmod(spool, fs.ModeSticky|0733); err != nil {
+		return fmt.Errorf("failed to set postpone spool permissions: %w", err)
+	}
+	return nil
+}
+
+// Write atomically publishes the status in the runtime directory, as a line
+// with the state, the deadline in seconds since the epoch (0 while active)
+// and the power action, if any, e.g. "warning 1767600240 suspend", so that
+// shells can parse it cheaply.
+func Write(dir string, s Status) error {
+	deadline := int64(0)
+	if !s.Deadline.IsZero() {
+		deadline = s.Deadline.Unix()
+	}
+	f, err := os.CreateTemp(dir, "."+statusFile+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary status file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	line := fmt.Sprintf("%s %d", s.State, deadline)
+	if s.Action != "" {
+		line += " " + string(s.Action)
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write status file %s: %w", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close status file %s: %w", f.Name(), err)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set status file permissions: %w", err)
+	}
+	path := filepath.Join(dir, statusFile)
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace status file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read reads the status published in the runtime directory.
+func Read(dir string) (Status, error) {
+	path := filepath.Join(dir, statusFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read status file: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 && len(fields) != 3 {
+		return Status{}, fmt.Errorf("invalid status file %s: %q", path, data)
+	}
+	var s Status
+	if err := s.State.UnmarshalText([]byte(fields[0])); err != nil {
+		return Status{}, fmt.Errorf("invalid status file %s: %w", path, err)
+	}
+	deadline, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return Status{}, fmt.Errorf("invalid status file %s: %w", path, err)
+	}
+	if deadline != 0 {
+		s.Deadline = time.Unix(deadline, 0)
+	}
+	if len(fields) == 3 {
+		if s.Action, err = power.ParseAction(fields[2]); err != nil {
+			return Status{}, fmt.Errorf("invalid status file %s: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// Request is a request to postpone the power action.
+type Request struct {
+	// User is the name of the user who made the request.
+	User string
+	// Duration is how long the power action should be postponed.
+	Duration time.Duration
+}
+
+// Postpone drops a request to postpone the power action for the given time
+// in the spool of the runtime directory, for the daemon to pick up.
+func Postpone(dir string, d time.Duration) error {
+	f, err := os.CreateTemp(filepath.Join(dir, spoolDir), "request-*")
+	if err != nil {
+		return fmt.Errorf("failed to create postpone request: %w", err)
+	}
+	_, err = fmt.Fprintln(f, d)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("failed to write postpone request: %w", err)
+	}
+	return nil
+}
+
+// Requests collects and removes the postpone requests in the spool of the
+// runtime directory; the requester is the owner of the request file, and
+// malformed requests are discarded.
+func Requests(dir string) ([]Request, error) {
+	spool := filepath.Join(dir, spoolDir)
+	entries, err := os.ReadDir(spool)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read postpone spool: %w", err)
+	}
+	var (
+		requests []Request
+		errs     []error
+	)
+	for _, entry := range entries {
+		path := filepath.Join(spool, entry.Name())
+		request, err := readRequest(path)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			requests = append(requests, request)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("failed to remove postpone request: %w", err))
+		}
+	}
+	return requests, errors.Join(errs...)
+}
+
+// readRequest reads a single request; the file is checked through its
+// descriptor, so that it cannot be replaced after the check, and symbolic
+// links are not followed. The content of invalid requests is not reported,
+// as the file may be anything the requester could link to.
+func readRequest(path string) (Request, error) {
+	f, err := openRequest(path)
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to read postpone request: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to read postpone request: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return Request{}, fmt.Errorf("invalid postpone request %s: not a regular file", path)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 64))
+	if err != nil {
+		return Request{}, fmt.Errorf("failed to read postpone request: %w", err)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(string(data)))
+	if err != nil || d <= 0 {
+		return Request{}, fmt.Errorf("invalid postpone request %s: not a positive duration", path)
+	}
+	return Request{User: ownerName(info), Duration: d}, nil
+}
+
+// ownerName returns the name of the user owning the file.
+func ownerName(info fs.FileInfo) string {
+	uid, ok := owner(info)
+	if !ok {
+		return "unknown"
+	}
+	id := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return "uid " + id
+}
@@ -0,0 +1,99 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/internal/power"
+)
+
+func TestWriteRead(t *testing.T) {
+	dir := t.TempDir()
+	if err := Prepare(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Date(2026, 1, 5, 7, 44, 0, 0, time.UTC)
+	tests := []Status{
+		{State: idle.Active},
+		{State: idle.Grace, Deadline: deadline},
+		{State: idle.Warning, Deadline: deadline, Action: power.Suspend},
+	}
+	for _, want := range tests {
+		if err := Write(dir, want); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := Read(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.State != want.State || !got.Deadline.Equal(want.Deadline) || got.Action != want.Action {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, statusFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "warning 1767599040 suspend\n"; string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+	s, _ := Read(dir)
+	if remaining, ok := s.Remaining(deadline.Add(-12 * time.Minute)); !ok || remaining != 12*time.Minute {
+		t.Errorf("expected 12m remaining, got %v (%v)", remaining, ok)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	dir := t.TempDir()
+	if err := Prepare(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, spoolDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSticky == 0 || info.Mode().Perm() != 0733 {
+		t.Errorf("unexpected spool permissions %v", info.Mode())
+	}
+
+	if err := Postpone(dir, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Postpone(dir, 30*time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	spool := filepath.Join(dir, spoolDir)
+	if err := os.WriteFile(filepath.Join(spool, "garbage"), []byte("forever"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/passwd", filepath.Join(spool, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	requests, err := Requests(dir)
+	if err == nil {
+		t.Errorf("expected errors for the invalid requests")
+	} else if strings.Contains(err.Error(), "forever") || strings.Contains(err.Error(), "root") {
+		t.Errorf("expected the content of invalid requests not to be reported, got %v", err)
+	}
+	total := time.Duration(0)
+	for _, request := range requests {
+		if request.User == "" {
+			t.Errorf("expected the requester to be known")
+		}
+		total += request.Duration
+	}
+	if len(requests) != 2 || total != 90*time.Minute {
+		t.Errorf("expected 2 requests for 1h30m, got %+v", requests)
+	}
+	if entries, _ := os.ReadDir(spool); len(entries) != 0 {
+		t.Errorf("expected the spool to be emptied, got %d entries", len(entries))
+	}
+	if requests, err := Requests(dir); err != nil || len(requests) != 0 {
+		t.Errorf("expected no requests, got %+v (%v)", requests, err)
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"time"
 
 	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+	"github.com/dihedron/slumberd/internal/hooks"
+	"github.com/dihedron/slumberd/internal/idle"
+	"github.com/dihedron/slumberd/internal/instance"
+	"github.com/dihedron/slumberd/internal/notify"
 	"github.com/dihedron/slumberd/internal/power"
+	"github.com/dihedron/slumberd/internal/status"
 	"github.com/dihedron/slumberd/metadata"
 	"github.com/dihedron/slumberd/pointer"
 	"github.com/dihedron/slumberd/timex"
@@ -31,15 +38,46 @@ func main() {
 			}
 		case "p", "poweroff", "-poweroff", "--poweroff":
 			slog.Info("executing poweroff")
-			power.Shutdown()
+			if err := power.Shutdown(); err != nil {
+				slog.Error("failed to power off", "error", err)
+				fmt.Fprintf(os.Stderr, "failed to power off: %v\n", err)
+				os.Exit(1)
+			}
 			os.Exit(0)
 		case "i", "init", "-init", "--init", "initialise", "-initialise", "--initialise", "g", "gen", "-gen", "--gen", "generate", "-generate", "--generate":
 			slog.Info("executing init")
 			cfg := configuration.Configuration{
-				Packages:  pointer.To("/home/developer/packages.yaml"),
-				Debounce:  pointer.To(timex.Duration(500 * time.Millisecond)),
-				Timeout:   pointer.To(timex.Duration(15 * time.Minute)),
-				Frequency: pointer.To(timex.Duration(time.Minute)),
+				Packages:      pointer.To("/home/developer/packages.yaml"),
+				Debounce:      pointer.To(timex.Duration(500 * time.Millisecond)),
+				Timeout:       pointer.To(timex.Duration(15 * time.Minute)),
+				Frequency:     pointer.To(timex.Duration(time.Minute)),
+				Warning:       pointer.To(timex.Duration(5 * time.Minute)),
+				Grace:         pointer.To(timex.Duration(time.Minute)),
+				BootGrace:     pointer.To(timex.Duration(10 * time.Minute)),
+				MinUptime:     pointer.To(timex.Duration(30 * time.Minute)),
+				Sockets:       pointer.To(detect.SocketsNetlink),
+				Proc:          pointer.To("/proc"),
+				Sessions:      pointer.To("/run/systemd/sessions"),
+				Detectors:     []string{"vscode"},
+				State:         pointer.To(configuration.DefaultStateFile),
+				IdleSamples:   pointer.To(2),
+				ActiveSamples: pointer.To(1),
+				FailurePolicy: pointer.To(string(idle.FailOpen)),
+				Dev:           pointer.To("/dev"),
+				Wall:          pointer.To(true),
+				WarnAt:        configuration.DefaultWarnAt,
+				WarnMessage:   pointer.To(notify.DefaultTemplate),
+				MetadataURL:   pointer.To(instance.DefaultMetadataURL),
+				RunDir:        pointer.To(status.DefaultDir),
+				Actions:       configuration.DefaultActions,
+				Backend:       pointer.To("logind"),
+				WakeAlarm:     pointer.To(power.DefaultWakeAlarm),
+				WakeLead:      pointer.To(timex.Duration(5 * time.Minute)),
+				Hooks: &hooks.Hooks{
+					PreAction:  hooks.DefaultPreAction,
+					PostResume: hooks.DefaultPostResume,
+					Timeout:    pointer.To(timex.Duration(hooks.DefaultTimeout)),
+				},
 			}
 			if data, err := yaml.Marshal(cfg); err != nil {
 				slog.Error("failed to marshal configuration", "error", err)
@@ -49,6 +87,26 @@ func main() {
 				fmt.Print(string(data))
 			}
 			os.Exit(0)
+		case "capture", "-capture", "--capture":
+			slog.Info("executing capture")
+			os.Exit(run(&CaptureCommand{}, os.Args[2:]))
+		case "d", "detect", "-detect", "--detect":
+			slog.Info("executing detect")
+			os.Exit(run(&DetectCommand{}, os.Args[2:]))
+		case "doctor", "-doctor", "--doctor":
+			slog.Info("executing doctor")
+			os.Exit(run(&DoctorCommand{}, os.Args[2:]))
+		case "inhibit", "-inhibit", "--inhibit":
+			slog.Info("executing inhibit")
+			os.Exit(run(&InhibitCommand{}, os.Args[2:]))
+		case "postpone", "-postpone", "--postpone":
+			slog.Info("executing postpone")
+			os.Exit(run(&PostponeCommand{}, os.Args[2:]))
+		case "replay", "-replay", "--replay":
+			slog.Info("executing replay")
+			os.Exit(run(&ReplayCommand{}, os.Args[2:]))
+		case "shell-init", "-shell-init", "--shell-init":
+			os.Exit(run(&ShellInitCommand{}, os.Args[2:]))
 		}
 	}
 
@@ -75,3 +133,28 @@ func main() {
 	}
 
 }
+
+// run parses the arguments into the given command and executes it, returning
+// the process exit code; commands with a Description show it in their help.
+func run(command flags.Commander, args []string) int {
+	parser := flags.NewParser(command, flags.Default)
+	parser.Name = fmt.Sprintf("%s %s", parser.Name, os.Args[1])
+	if described, ok := command.(interface{ Description() string }); ok {
+		parser.LongDescription = described.Description()
+	}
+	args, err := parser.ParseArgs(args)
+	if err != nil {
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			return 0
+		}
+		return 1
+	}
+	if err := command.Execute(args); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			return int(code)
+		}
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/state"
+	"github.com/dihedron/slumberd/internal/status"
+	"github.com/dihedron/slumberd/timex"
+)
+
+// PostponeCommand postpones the power action: it records an inhibit in the
+// daemon state file if the user may write to it, and otherwise asks the
+// daemon to record it through the postpone spool, so that any user can
+// postpone the power action without administrative rights.
+type PostponeCommand struct {
+	// State is the daemon state file the inhibit is recorded in.
+	State string `short:"s" long:"state" description:"Daemon state file" default:"/var/lib/slumberd/state.json"`
+	// RunDir is the daemon runtime directory.
+	RunDir string `long:"run-dir" description:"Daemon runtime directory" default:"/run/slumberd"`
+	// Duration is how long the power action is postponed.
+	Duration timex.Duration `short:"d" long:"duration" description:"How long to postpone the power action" default:"1h"`
+}
+
+// Execute runs the postpone command.
+func (cmd *PostponeCommand) Execute(args []string) error {
+	if cmd.State == "" {
+		cmd.State = configuration.DefaultStateFile
+	}
+	if cmd.RunDir == "" {
+		cmd.RunDir = status.DefaultDir
+	}
+	if cmd.Duration <= 0 {
+		err := errors.New("postpone duration must be positive")
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+
+	now := time.Now()
+	inhibit := state.Inhibit{
+		Who:   username(),
+		Why:   "postponed",
+		Since: now,
+		Until: now.Add(time.Duration(cmd.Duration)),
+	}
+	err := state.Update(cmd.State, func(s *state.State) error {
+		s.Prune(now)
+		s.Inhibits = append(s.Inhibits, inhibit)
+		return nil
+	})
+	if err == nil {
+		fmt.Println(inhibit.String())
+		return nil
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		slog.Error("failed to update state file", "path", cmd.State, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to update state file: %v\n", err)
+		return err
+	}
+
+	// leave it to the daemon
+	if err := status.Postpone(cmd.RunDir, time.Duration(cmd.Duration)); err != nil {
+		slog.Error("failed to request postponement", "path", cmd.RunDir, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to request postponement: %v\n", err)
+		return err
+	}
+	if time.Duration(cmd.Duration) > maxPostpone {
+		fmt.Printf("power action postponement requested, for at most %s\n", maxPostpone)
+	} else {
+		fmt.Printf("power action postponement requested, for %s\n", time.Duration(cmd.Duration))
+	}
+	return nil
+}
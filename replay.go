@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+
+	"github.com/dihedron/slumberd/internal/capture"
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+// ReplayCommand runs the detectors against a capture archive and prints
+// each detector's verdict.
+type ReplayCommand struct {
+	// Detectors overrides the detectors recorded in the archive configuration.
+	Detectors []string `short:"d" long:"detector" description:"Detector to run (default: those in the captured configuration)"`
+	// Args holds the positional arguments.
+	Args struct {
+		Archive string `positional-arg-name:"ARCHIVE" description:"Capture archive to replay" required:"true"`
+	} `positional-args:"yes"`
+}
+
+// Execute runs the replay command.
+func (cmd *ReplayCommand) Execute(args []string) error {
+	slog.Info("replaying capture archive", "path", cmd.Args.Archive)
+
+	f, err := os.Open(path.Clean(cmd.Args.Archive))
+	if err != nil {
+		slog.Error("failed to open capture archive", "path", cmd.Args.Archive, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to open capture archive: %v\n", err)
+		return err
+	}
+	defer f.Close()
+
+	archive, err := capture.Read(f)
+	if err != nil {
+		slog.Error("failed to read capture archive", "path", cmd.Args.Archive, "error", err)
+		fmt.Fprintf(os.Stderr, "failed to read capture archive: %v\n", err)
+		return err
+	}
+
+	names := cmd.Detectors
+	if len(names) == 0 {
+		names = archive.Configuration.Detectors
+	}
+	detectors, err := detect.Lookup(names...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+
+	fmt.Printf("capture of %s taken at %s (version %s, redacted: %t)\n\n",
+		archive.Manifest.Host,
+		archive.Manifest.Time.Format("2006-01-02 15:04:05 MST"),
+		archive.Manifest.Version,
+		archive.Manifest.Redacted)
+	results := detect.Run(archive.Source, detectors)
+	printResults(os.Stdout, results)
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dihedron/slumberd/configuration"
+	"github.com/dihedron/slumberd/internal/detect"
+)
+
+// source returns the detection source described by the configuration.
+func source(cfg *configuration.Configuration) *detect.Source {
+	return &detect.Source{
+		Proc:     detect.NewProc(*cfg.Proc, *cfg.Sockets),
+		Sessions: os.DirFS(*cfg.Sessions),
+	}
+}
+
+// undetermined returns whether the results do not tell whether the system is
+// idle: some detector failed and none detected activity.
+func undetermined(results []detect.Result) bool {
+	if detect.IsActive(results) {
+		return false
+	}
+	for _, result := range results {
+		if result.Verdict == detect.Unknown {
+			return true
+		}
+	}
+	return false
+}
+
+// printResults prints the detector results as a table, followed by the
+// overall verdict.
+func printResults(w io.Writer, results []detect.Result) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DETECTOR\tVERDICT\tDURATION\tEVIDENCE")
+	for _, result := range results {
+		evidence := strings.Join(result.Evidence, ", ")
+		if result.Error != nil {
+			evidence = result.Error.Error()
+		}
+		if evidence == "" {
+			evidence = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Detector, result.Verdict, result.Duration.Round(time.Microsecond), evidence)
+	}
+	tw.Flush()
+
+	switch {
+	case detect.IsActive(results):
+		fmt.Fprintln(w, "\nthe system is active")
+	case undetermined(results):
+		fmt.Fprintln(w, "\nit could not be determined whether the system is idle: some detectors failed")
+	default:
+		fmt.Fprintln(w, "\nthe system is idle")
+	}
+}
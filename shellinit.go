@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/dihedron/slumberd/internal/status"
+)
+
+// ShellInitCommand prints a snippet for the rc file of the user's shell,
+// which shows the time left before the power action in the prompt once the
+// countdown has started, and defines a one-word command to postpone it.
+type ShellInitCommand struct {
+	// RunDir is the daemon runtime directory, where the status is published.
+	RunDir string `long:"run-dir" description:"Daemon runtime directory" default:"/run/slumberd"`
+	// Keyword is the name of the command that postpones the power action.
+	Keyword string `short:"k" long:"keyword" description:"Name of the command postponing the power action" default:"snooze"`
+	// Args holds the positional arguments.
+	Args struct {
+		Shell string `positional-arg-name:"SHELL" description:"Shell to print the snippet for (bash, zsh or fish)" required:"true"`
+	} `positional-args:"yes"`
+}
+
+// keyword is the syntax of the name of the postpone command.
+var keyword = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Execute runs the shell-init command.
+func (cmd *ShellInitCommand) Execute(args []string) error {
+	snippet, err := cmd.snippet()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return err
+	}
+	fmt.Print(snippet)
+	return nil
+}
+
+// snippet renders the snippet for the shell.
+func (cmd *ShellInitCommand) snippet() (string, error) {
+	if cmd.RunDir == "" {
+		cmd.RunDir = status.DefaultDir
+	}
+	text, ok := snippets[cmd.Args.Shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q: must be one of bash, zsh or fish", cmd.Args.Shell)
+	}
+	if !keyword.MatchString(cmd.Keyword) {
+		return "", fmt.Errorf("invalid keyword %q: must be a valid command name", cmd.Keyword)
+	}
+	// the snippets single-quote paths
+	if strings.ContainsAny(cmd.RunDir, "'\\\n") {
+		return "", fmt.Errorf("invalid runtime directory %q", cmd.RunDir)
+	}
+	postpone := "slumberd postpone"
+	if cmd.RunDir != status.DefaultDir {
+		postpone += " --run-dir '" + cmd.RunDir + "'"
+	}
+	var b strings.Builder
+	err := template.Must(template.New(cmd.Args.Shell).Parse(text)).Execute(&b, struct {
+		Status   string
+		Keyword  string
+		Postpone string
+	}{
+		Status:   filepath.Join(cmd.RunDir, "status"),
+		Keyword:  cmd.Keyword,
+		Postpone: postpone,
+	})
+	return b.String(), err
+}
+
+// snippets are the rc file snippets by shell; the status file holds the idle
+// state, the deadline of the power action in seconds since the epoch and the
+// action, if the daemon published it.
+var snippets = map[string]string{
+	"bash": `# slumberd: show the time left before the power action in the prompt, and
+# postpone it with '{{.Keyword}}'; add to ~/.bashrc:
+#   eval "$(slumberd shell-init bash)"
+__slumberd_prompt() {
+	__slumberd_status=
+	local state deadline action left
+	read -r state deadline action 2>/dev/null <'{{.Status}}' || return 0
+	case $state in
+	warning | grace | acting) ;;
+	*) return 0 ;;
+	esac
+	left=$((deadline - ${EPOCHSECONDS:-$(date +%s)}))
+	if ((left >= 60)); then
+		__slumberd_status="⏾ $((left / 60))m until ${action:-poweroff} "
+	else
+		__slumberd_status="⏾ $((left > 0 ? left : 0))s until ${action:-poweroff} "
+	fi
+}
+{{.Keyword}}() {
+	{{.Postpone}} "$@"
+}
+if [ -z "${__slumberd_init-}" ]; then
+	__slumberd_init=1
+	PROMPT_COMMAND="__slumberd_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+	PS1='${__slumberd_status}'"$PS1"
+fi
+`,
+	"zsh": `# slumberd: show the time left before the power action in the prompt, and
+# postpone it with '{{.Keyword}}'; add to ~/.zshrc:
+#   eval "$(slumberd shell-init zsh)"
+zmodload zsh/datetime 2>/dev/null
+__slumberd_prompt() {
+	__slumberd_status=
+	local state deadline action left
+	read -r state deadline action 2>/dev/null <'{{.Status}}' || return 0
+	case $state in
+	(warning|grace|acting) ;;
+	(*) return 0 ;;
+	esac
+	left=$((deadline - ${EPOCHSECONDS:-$(date +%s)}))
+	if ((left >= 60)); then
+		__slumberd_status="⏾ $((left / 60))m until ${action:-poweroff} "
+	else
+		__slumberd_status="⏾ $((left > 0 ? left : 0))s until ${action:-poweroff} "
+	fi
+}
+{{.Keyword}}() {
+	{{.Postpone}} "$@"
+}
+if [[ -z ${__slumberd_init-} ]]; then
+	__slumberd_init=1
+	autoload -Uz add-zsh-hook
+	add-zsh-hook precmd __slumberd_prompt
+	setopt prompt_subst
+	PROMPT='${__slumberd_status}'$PROMPT
+fi
+`,
+	"fish": `# slumberd: show the time left before the power action in the prompt, and
+# postpone it with '{{.Keyword}}'; add to ~/.config/fish/config.fish:
+#   slumberd shell-init fish | source
+function __slumberd_prompt
+	test -r '{{.Status}}'; or return
+	read -l state deadline action <'{{.Status}}'; or return
+	contains -- $state warning grace acting; or return
+	test -n "$action"; or set action poweroff
+	set -l left (math $deadline - (date +%s))
+	if test $left -ge 60
+		printf '⏾ %dm until %s ' (math "floor($left / 60)") $action
+	else if test $left -gt 0
+		printf '⏾ %ds until %s ' $left $action
+	else
+		printf '⏾ 0s until %s ' $action
+	end
+end
+function {{.Keyword}} --description 'Postpone the power action'
+	{{.Postpone}} $argv
+end
+if not functions -q __slumberd_fish_prompt
+	functions -c fish_prompt __slumberd_fish_prompt
+	function fish_prompt
+		__slumberd_prompt
+		__slumberd_fish_prompt
+	end
+end
+`,
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellInit(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		cmd := &ShellInitCommand{RunDir: "/run/slumberd", Keyword: "snooze"}
+		cmd.Args.Shell = shell
+		snippet, err := cmd.snippet()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", shell, err)
+		}
+		if !strings.Contains(snippet, "/run/slumberd/status") || !strings.Contains(snippet, "snooze") {
+			t.Errorf("%s: unexpected snippet:\n%s", shell, snippet)
+		}
+		if path, err := exec.LookPath(shell); err == nil {
+			// check the syntax
+			check := exec.Command(path, "-n")
+			check.Stdin = strings.NewReader(snippet)
+			if output, err := check.CombinedOutput(); err != nil {
+				t.Errorf("%s: invalid snippet: %v\n%s", shell, err, output)
+			}
+		}
+	}
+
+	invalid := []ShellInitCommand{
+		{RunDir: "/run/slumberd", Keyword: "snooze"},
+		{RunDir: "/run/slumberd", Keyword: "rm -rf"},
+		{RunDir: "/run/it's", Keyword: "snooze"},
+	}
+	invalid[1].Args.Shell = "bash"
+	invalid[2].Args.Shell = "bash"
+	for _, cmd := range invalid {
+		if _, err := cmd.snippet(); err == nil {
+			t.Errorf("%+v: expected an error", cmd)
+		}
+	}
+}
+
+func TestShellInitBash(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	dir := t.TempDir()
+	cmd := &ShellInitCommand{RunDir: dir, Keyword: "snooze"}
+	cmd.Args.Shell = "bash"
+	snippet, err := cmd.snippet()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Now().Unix()
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"", ""},
+		{"active 0\n", ""},
+		{"idle 0\n", ""},
+		{fmt.Sprintf("warning %d\n", now+12*60+30), "⏾ 12m until poweroff "},
+		{fmt.Sprintf("warning %d suspend\n", now+12*60+30), "⏾ 12m until suspend "},
+		{fmt.Sprintf("grace %d hibernate\n", now+20), "⏾ 2"},
+		{fmt.Sprintf("acting %d hybrid-sleep\n", now-5), "⏾ 0s until hybrid-sleep "},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "status")
+		os.Remove(path)
+		if tt.status != "" {
+			if err := os.WriteFile(path, []byte(tt.status), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		script := snippet + "\n__slumberd_prompt\nprintf '%s|%s' \"$__slumberd_status\" \"$(type -t snooze)\"\n"
+		output, err := exec.Command(bash, "--norc", "--noprofile", "-c", script).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v\n%s", tt.status, err, output)
+		}
+		prompt, kind, _ := strings.Cut(string(output), "|")
+		if !strings.HasPrefix(prompt, tt.want) || (tt.want == "" && prompt != "") {
+			t.Errorf("%q: expected prompt %q, got %q", tt.status, tt.want, prompt)
+		}
+		if kind != "function" {
+			t.Errorf("%q: expected the postpone function to be defined, got %q", tt.status, kind)
+		}
+	}
+}
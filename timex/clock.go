@@ -0,0 +1,199 @@
+package timex
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the passing of time, so that code that waits, schedules or
+// measures can be driven by a FakeClock in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+	// NewTicker returns a ticker that ticks with the given period.
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc calls f in its own goroutine after the given duration.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks at intervals, like time.Ticker.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// Timer is a single event scheduled by AfterFunc, like time.Timer.
+type Timer interface {
+	// Stop prevents the timer from firing; it returns false if the timer had
+	// already fired or been stopped.
+	Stop() bool
+}
+
+// RealClock is the Clock backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// FakeClock is a Clock whose time only moves when Advance or Set is called;
+// tickers and timers due in the meantime fire in chronological order, from
+// within the call. Ticks are delivered on channels with a buffer of one, and
+// dropped if the previous tick has not been received yet, like time.Ticker;
+// AfterFunc callbacks are run synchronously.
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a pending ticker or timer.
+type fakeWaiter struct {
+	clock  *FakeClock
+	when   time.Time
+	period time.Duration // tickers only
+	c      chan time.Time
+	f      func()
+}
+
+// NewFakeClock returns a fake clock set at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+// Since returns the fake time elapsed since t.
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// NewTicker returns a ticker driven by the fake clock.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := &fakeWaiter{clock: c, when: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return fakeTicker{w}
+}
+
+// AfterFunc schedules f to be called when the fake clock reaches the given
+// duration from now.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	w := &fakeWaiter{clock: c, when: c.now.Add(d), f: f}
+	c.waiters = append(c.waiters, w)
+	return fakeTimer{w}
+}
+
+// Advance moves the fake clock forward by the given duration, firing the
+// tickers and timers that become due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the fake clock to the given time, firing the tickers and timers
+// that become due; the clock never goes backwards.
+func (c *FakeClock) Set(t time.Time) {
+	for {
+		c.lock.Lock()
+		var next *fakeWaiter
+		for _, w := range c.waiters {
+			if !w.when.After(t) && (next == nil || w.when.Before(next.when)) {
+				next = w
+			}
+		}
+		if next == nil {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.lock.Unlock()
+			return
+		}
+		c.now = next.when
+		now := c.now
+		if next.period > 0 {
+			next.when = next.when.Add(next.period)
+		} else {
+			c.remove(next)
+		}
+		c.lock.Unlock()
+
+		if next.c != nil {
+			select {
+			case next.c <- now:
+			default:
+			}
+		} else {
+			next.f()
+		}
+	}
+}
+
+// Pending returns the number of tickers and timers that have not been stopped
+// or fired yet.
+func (c *FakeClock) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.waiters)
+}
+
+// remove removes the waiter, reporting whether it was pending; it must be
+// called with the lock held.
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	i := slices.Index(c.waiters, w)
+	if i < 0 {
+		return false
+	}
+	c.waiters = slices.Delete(c.waiters, i, i+1)
+	return true
+}
+
+// stop removes the ticker or timer from the fake clock.
+func (w *fakeWaiter) stop() bool {
+	w.clock.lock.Lock()
+	defer w.clock.lock.Unlock()
+	return w.clock.remove(w)
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time { return t.c }
+func (t fakeTicker) Stop()               { t.stop() }
+
+type fakeTimer struct {
+	*fakeWaiter
+}
+
+func (t fakeTimer) Stop() bool { return t.stop() }
@@ -0,0 +1,60 @@
+package timex
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	var fired []string
+	clock.AfterFunc(90*time.Second, func() {
+		fired = append(fired, "timer at "+clock.Now().Format("15:04:05"))
+	})
+	stopped := clock.AfterFunc(30*time.Second, func() {
+		fired = append(fired, "stopped timer")
+	})
+	if !stopped.Stop() {
+		t.Errorf("expected the timer to be pending")
+	}
+	ticker := clock.NewTicker(time.Minute)
+
+	clock.Advance(time.Minute)
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(start.Add(time.Minute)) {
+			t.Errorf("unexpected tick at %v", tick)
+		}
+	default:
+		t.Fatalf("expected a tick")
+	}
+
+	// ticks that are not received are dropped
+	clock.Advance(3 * time.Minute)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("unexpected tick at %v", tick)
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Errorf("unexpected tick at %v", tick)
+	default:
+	}
+
+	if want := []string{"timer at 09:01:30"}; !slices.Equal(fired, want) {
+		t.Errorf("expected %v, got %v", want, fired)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(4 * time.Minute)) {
+		t.Errorf("unexpected time %v", now)
+	}
+	if since := clock.Since(start); since != 4*time.Minute {
+		t.Errorf("unexpected elapsed time %v", since)
+	}
+
+	ticker.Stop()
+	if clock.Pending() != 0 {
+		t.Errorf("expected no pending tickers and timers, got %d", clock.Pending())
+	}
+}
@@ -0,0 +1,244 @@
+package timex
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cron represents a cron-like schedule expression, with the five standard
+// fields (minute, hour, day of month, month and day of week), each accepting
+// "*", values, ranges, lists and steps (e.g. "*/15", "1-5", "mon,wed"), or
+// one of the "@hourly", "@daily" (or "@midnight"), "@weekly", "@monthly" and
+// "@yearly" (or "@annually") shorthands. As in Vixie cron, when both the day
+// of month and the day of week are restricted, a day matching either field
+// matches.
+type Cron struct {
+	spec    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	anyDay  bool // day of month is "*"
+	anyWeek bool // day of week is "*"
+}
+
+// cronField describes the range of values of a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: weekdayNames},
+}
+
+var cronShorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// cronHorizon bounds the search for the next match of an expression, so that
+// expressions that can never match (e.g. "0 0 30 2 *") do not loop forever.
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// ParseCron parses a cron expression.
+func ParseCron(spec string) (Cron, error) {
+	spec = strings.TrimSpace(spec)
+	expanded := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expanded, ok = cronShorthands[strings.ToLower(spec)]; !ok {
+			return Cron{}, fmt.Errorf("invalid cron expression %q: unknown shorthand", spec)
+		}
+	}
+	fields := strings.Fields(expanded)
+	if len(fields) != len(cronFields) {
+		return Cron{}, fmt.Errorf("invalid cron expression %q: expected %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+	c := Cron{spec: spec}
+	bits := []*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, field := range fields {
+		set, err := cronFields[i].parse(field)
+		if err != nil {
+			return Cron{}, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		*bits[i] = set
+	}
+	// both 0 and 7 are Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	c.anyDay = fields[2] == "*"
+	c.anyWeek = fields[4] == "*"
+	return c, nil
+}
+
+// parse parses a field of a cron expression into a bit set of its values.
+func (f cronField) parse(field string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		expr, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+			}
+		}
+		from, to := f.min, f.max
+		if expr != "*" {
+			first, last, isRange := strings.Cut(expr, "-")
+			var err error
+			if from, err = f.value(first); err != nil {
+				return 0, err
+			}
+			to = from
+			if isRange {
+				if to, err = f.value(last); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				to = f.max
+			}
+			if to < from {
+				return 0, fmt.Errorf("invalid range %q in %s field", expr, f.name)
+			}
+		}
+		for v := from; v <= to; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value parses a single value, numeric or named, of a cron field.
+func (f cronField) value(s string) (int, error) {
+	s = strings.ToLower(s)
+	for i, name := range f.names {
+		if name != "" && s == name {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field: must be between %d and %d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// IsZero returns whether the expression is unset.
+func (c Cron) IsZero() bool {
+	return c.spec == ""
+}
+
+// matchesDay returns whether the expression matches the day of the given time.
+func (c Cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	switch {
+	case c.anyDay && c.anyWeek:
+		return true
+	case c.anyDay:
+		return dow
+	case c.anyWeek:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time strictly after the given one that matches the
+// expression, in the location of the given time; it returns the zero time if
+// the expression does not match within the next few years or is unset.
+func (c Cron) Next(t time.Time) time.Time {
+	if c.IsZero() {
+		return time.Time{}
+	}
+	limit := t.Add(cronHorizon)
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// String returns the string representation of the Cron value.
+func (c Cron) String() string {
+	return c.spec
+}
+
+// UnmarshalFlag unmarshals a string value into the Cron variable.
+// This method is used by the go-flags package to handle custom flag types.
+func (c *Cron) UnmarshalFlag(value string) error {
+	p, err := ParseCron(value)
+	if err == nil {
+		*c = p
+	}
+	return err
+}
+
+// MarshalJSON marshals the Cron value into a JSON string.
+func (c Cron) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON unmarshals a JSON string into the Cron variable.
+func (c *Cron) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return c.UnmarshalFlag(v)
+}
+
+// MarshalText marshals the Cron value into a text string.
+func (c Cron) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText unmarshals a text string into the Cron variable.
+func (c *Cron) UnmarshalText(text []byte) error {
+	return c.UnmarshalFlag(string(text))
+}
+
+// MarshalYAML marshals the Cron value into a YAML string.
+func (c Cron) MarshalYAML() (any, error) {
+	return c.String(), nil
+}
+
+// UnmarshalYAML unmarshals a YAML string into the Cron variable.
+func (c *Cron) UnmarshalYAML(value *yaml.Node) error {
+	var v string
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	return c.UnmarshalFlag(v)
+}
@@ -0,0 +1,108 @@
+package timex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCronNext(t *testing.T) {
+	// Monday, 5 January 2026
+	start := time.Date(2026, 1, 5, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 1, 5, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 1, 5, 10, 30, 0, 0, time.UTC)},
+		{"0 8 * * mon-fri", time.Date(2026, 1, 6, 8, 0, 0, 0, time.UTC)},
+		{"30 23 * * 1-5", time.Date(2026, 1, 5, 23, 30, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 1, 11, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@daily", time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 1, 5, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := c.Next(start); !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	c, _ := ParseCron("0 8 * * *")
+	// 07:30 UTC is 08:30 in Rome, so the next match is tomorrow
+	got := c.Next(time.Date(2026, 1, 5, 7, 30, 0, 0, time.UTC).In(rome))
+	if want := time.Date(2026, 1, 6, 8, 0, 0, 0, rome); !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * * foo", "@never"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%q: expected an error, got none", spec)
+		}
+	}
+}
+
+func TestCronRoundTrip(t *testing.T) {
+	type wrapper struct {
+		Cron Cron `json:"cron" yaml:"cron"`
+	}
+	for _, spec := range []string{"*/5 8-18 * * mon-fri", "@daily"} {
+		c, err := ParseCron(spec)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		in := wrapper{Cron: c}
+
+		var fromJSON wrapper
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := json.Unmarshal(data, &fromJSON); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fromJSON.Cron != c {
+			t.Errorf("JSON: expected %v, got %v", c, fromJSON.Cron)
+		}
+
+		var fromYAML wrapper
+		data, err = yaml.Marshal(in)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := yaml.Unmarshal(data, &fromYAML); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fromYAML.Cron != c {
+			t.Errorf("YAML: expected %v, got %v", c, fromYAML.Cron)
+		}
+
+		var fromText Cron
+		text, _ := c.MarshalText()
+		if err := fromText.UnmarshalText(text); err != nil || fromText != c {
+			t.Errorf("text: expected %v, got %v (%v)", c, fromText, err)
+		}
+	}
+}
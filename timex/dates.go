@@ -0,0 +1,183 @@
+package timex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Date represents a calendar day, either on a given year ("2006-01-02") or
+// recurring every year ("01-02"), in which case Year is zero.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ParseDate parses a date in "2006-01-02" format, or a yearly recurring date
+// in "01-02" format.
+func ParseDate(value string) (Date, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}, nil
+	}
+	// parse recurring dates on a leap year, so that "02-29" is valid
+	if t, err := time.Parse("2006-01-02", "2000-"+value); err == nil {
+		return Date{Month: t.Month(), Day: t.Day()}, nil
+	}
+	return Date{}, fmt.Errorf("invalid date %q: must be in YYYY-MM-DD or MM-DD format", value)
+}
+
+// DateOf returns the date of the given time, in its location.
+func DateOf(t time.Time) Date {
+	return Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+}
+
+// Matches returns whether the given time, in its location, falls on the date.
+func (d Date) Matches(t time.Time) bool {
+	return (d.Year == 0 || d.Year == t.Year()) && d.Month == t.Month() && d.Day == t.Day()
+}
+
+// String returns the string representation of the Date value.
+func (d Date) String() string {
+	if d.Year == 0 {
+		return fmt.Sprintf("%02d-%02d", int(d.Month), d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// UnmarshalFlag unmarshals a string value into the Date variable.
+// This method is used by the go-flags package to handle custom flag types.
+func (d *Date) UnmarshalFlag(value string) error {
+	p, err := ParseDate(value)
+	if err == nil {
+		*d = p
+	}
+	return err
+}
+
+// MarshalJSON marshals the Date value into a JSON string.
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON unmarshals a JSON string into the Date variable.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return d.UnmarshalFlag(v)
+}
+
+// MarshalText marshals the Date value into a text string.
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText unmarshals a text string into the Date variable.
+func (d *Date) UnmarshalText(text []byte) error {
+	return d.UnmarshalFlag(string(text))
+}
+
+// MarshalYAML marshals the Date value into a YAML string.
+func (d Date) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+// UnmarshalYAML unmarshals a YAML string into the Date variable.
+func (d *Date) UnmarshalYAML(value *yaml.Node) error {
+	var v string
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	return d.UnmarshalFlag(v)
+}
+
+// Dates represents a list of dates, such as holidays; it is parsed from a
+// comma separated list of dates (e.g. "12-25,2026-04-06").
+type Dates []Date
+
+// ParseDates parses a comma separated list of dates.
+func ParseDates(value string) (Dates, error) {
+	var dates Dates
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		d, err := ParseDate(item)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, d)
+	}
+	return dates, nil
+}
+
+// Contains returns whether the given time, in its location, falls on any of
+// the dates.
+func (d Dates) Contains(t time.Time) bool {
+	return slices.ContainsFunc(d, func(date Date) bool {
+		return date.Matches(t)
+	})
+}
+
+// String returns the string representation of the Dates value.
+func (d Dates) String() string {
+	items := make([]string, len(d))
+	for i, date := range d {
+		items[i] = date.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// UnmarshalFlag unmarshals a string value into the Dates variable.
+// This method is used by the go-flags package to handle custom flag types.
+func (d *Dates) UnmarshalFlag(value string) error {
+	p, err := ParseDates(value)
+	if err == nil {
+		*d = p
+	}
+	return err
+}
+
+// UnmarshalJSON unmarshals a JSON array of strings, or a comma separated
+// string, into the Dates variable; the array form is marshalled back.
+func (d *Dates) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		var list []Date
+		if err := json.Unmarshal(b, &list); err != nil {
+			return err
+		}
+		*d = list
+		return nil
+	}
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return d.UnmarshalFlag(v)
+}
+
+// UnmarshalYAML unmarshals a YAML sequence of strings, or a comma separated
+// string, into the Dates variable; the sequence form is marshalled back.
+func (d *Dates) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var list []Date
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*d = list
+		return nil
+	}
+	var v string
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	return d.UnmarshalFlag(v)
+}
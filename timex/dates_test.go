@@ -0,0 +1,105 @@
+package timex
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Date
+		err   bool
+	}{
+		{input: "2026-12-25", want: Date{Year: 2026, Month: time.December, Day: 25}},
+		{input: "12-25", want: Date{Month: time.December, Day: 25}},
+		{input: "02-29", want: Date{Month: time.February, Day: 29}},
+		{input: "2026-02-29", err: true},
+		{input: "13-01", err: true},
+		{input: "christmas", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestDatesContains(t *testing.T) {
+	dates, err := ParseDates("12-25, 2026-04-06")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		time time.Time
+		want bool
+	}{
+		{time.Date(2026, 12, 25, 10, 0, 0, 0, time.UTC), true},
+		{time.Date(2031, 12, 25, 23, 59, 0, 0, time.UTC), true},
+		{time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2027, 4, 6, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2026, 12, 24, 0, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := dates.Contains(tt.time); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.time, tt.want, got)
+		}
+	}
+}
+
+func TestDatesRoundTrip(t *testing.T) {
+	type wrapper struct {
+		Holidays Dates `json:"holidays" yaml:"holidays"`
+	}
+	in := wrapper{Holidays: Dates{{Month: time.January, Day: 1}, {Year: 2026, Month: time.April, Day: 6}}}
+
+	var fromJSON wrapper
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"holidays":["01-01","2026-04-06"]}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fromJSON.Holidays, in.Holidays) {
+		t.Errorf("JSON: expected %v, got %v", in.Holidays, fromJSON.Holidays)
+	}
+
+	var fromYAML wrapper
+	data, err = yaml.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fromYAML.Holidays, in.Holidays) {
+		t.Errorf("YAML: expected %v, got %v", in.Holidays, fromYAML.Holidays)
+	}
+
+	// a comma separated string is accepted too
+	var fromString wrapper
+	if err := yaml.Unmarshal([]byte(`holidays: "01-01,2026-04-06"`), &fromString); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fromString.Holidays, in.Holidays) {
+		t.Errorf("string: expected %v, got %v", in.Holidays, fromString.Holidays)
+	}
+
+	var fromFlag Dates
+	if err := fromFlag.UnmarshalFlag(in.Holidays.String()); err != nil || !slices.Equal(fromFlag, in.Holidays) {
+		t.Errorf("flag: expected %v, got %v (%v)", in.Holidays, fromFlag, err)
+	}
+}
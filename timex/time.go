@@ -2,13 +2,19 @@ package timex
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"gopkg.in/yaml.v3"
 )
 
-// Duration represents a time duration value, which can be parsed from a string
-// using the time.ParseDuration function.
+// Duration represents a time duration value; it is parsed by ParseDuration
+// and always marshalled in the canonical time.Duration format (e.g. "1h30m0s").
 type Duration time.Duration
 
 // String returns the string representation of the Duration value.
@@ -19,12 +25,12 @@ func (d *Duration) String() string {
 // UnmarshalFlag unmarshals a string value into the Duration variable.
 // This method is used by the go-flags package to handle custom flag types.
 // It takes a string value, which is expected to be in a format that can be
-// parsed by the time.ParseDuration function (e.g., "30m", "1h"), and
-// populates the Duration variable accordingly.
+// parsed by the ParseDuration function (e.g., "30m", "1d", "2 hours", "900"
+// or "PT15M"), and populates the Duration variable accordingly.
 func (d *Duration) UnmarshalFlag(value string) error {
-	p, err := time.ParseDuration(value)
+	p, err := ParseDuration(value)
 	if err == nil {
-		*d = Duration(p)
+		*d = p
 	}
 	return err
 }
@@ -34,17 +40,18 @@ func (d *Duration) MarshalJSON() ([]byte, error) {
 	return json.Marshal(time.Duration(*d).String())
 }
 
-// UnmarshalJSON unmarshals a JSON string into the Duration variable.
+// UnmarshalJSON unmarshals a JSON string, or a JSON number of seconds, into
+// the Duration variable.
 func (d *Duration) UnmarshalJSON(b []byte) error {
 	var v string
 	if err := json.Unmarshal(b, &v); err != nil {
-		return err
-	}
-	p, err := time.ParseDuration(v)
-	if err == nil {
-		*d = Duration(p)
+		var n json.Number
+		if json.Unmarshal(b, &n) != nil {
+			return err
+		}
+		v = n.String()
 	}
-	return err
+	return d.UnmarshalFlag(v)
 }
 
 // MarshalText marshals the Duration value into a text string.
@@ -54,12 +61,7 @@ func (d *Duration) MarshalText() ([]byte, error) {
 
 // UnmarshalText unmarshals a text string into the Duration variable.
 func (d *Duration) UnmarshalText(text []byte) error {
-	v := string(text)
-	p, err := time.ParseDuration(v)
-	if err == nil {
-		*d = Duration(p)
-	}
-	return err
+	return d.UnmarshalFlag(string(text))
 }
 
 // MarshalYAML marshals the Duration value into a YAML string.
@@ -67,15 +69,235 @@ func (d Duration) MarshalYAML() (any, error) {
 	return time.Duration(d).String(), nil
 }
 
-// UnmarshalYAML unmarshals a YAML string into the Duration variable.
+// UnmarshalYAML unmarshals a YAML string, or a YAML integer number of
+// seconds, into the Duration variable.
 func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
 	var v string
 	if err := value.Decode(&v); err != nil {
 		return err
 	}
-	p, err := time.ParseDuration(v)
-	if err == nil {
-		*d = Duration(p)
+	return d.UnmarshalFlag(v)
+}
+
+// durationUnits maps the units accepted by ParseDuration to their length.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond, "nanosecond": time.Nanosecond, "nanoseconds": time.Nanosecond,
+	"us": time.Microsecond, "µs": time.Microsecond, "μs": time.Microsecond, "microsecond": time.Microsecond, "microseconds": time.Microsecond,
+	"ms": time.Millisecond, "millisecond": time.Millisecond, "milliseconds": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": Day, "day": Day, "days": Day,
+	"w": Week, "wk": Week, "wks": Week, "week": Week, "weeks": Week,
+}
+
+const (
+	// Day is the length of a day, ignoring daylight saving time changes.
+	Day = 24 * time.Hour
+	// Week is the length of a week, ignoring daylight saving time changes.
+	Week = 7 * Day
+)
+
+// ParseDuration parses a duration, which can be expressed as:
+//   - a sequence of numbers with units, as accepted by time.ParseDuration and
+//     extended with days ("d") and weeks ("w"), e.g. "1d12h" or "1.5h";
+//   - the same with spelled-out units, optionally separated by spaces, commas
+//     or "and", e.g. "2 hours", "1 hour and 30 minutes";
+//   - a bare integer number of seconds, e.g. "900";
+//   - an ISO-8601 duration with weeks, days, hours, minutes and seconds, e.g.
+//     "PT15M" or "P1DT12H"; years and months are rejected, having no fixed
+//     length.
+func ParseDuration(value string) (Duration, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q: empty", value)
 	}
-	return err
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > math.MaxInt64/int64(time.Second) || n < math.MinInt64/int64(time.Second) {
+			return 0, fmt.Errorf("invalid duration %q: out of range", value)
+		}
+		return Duration(time.Duration(n) * time.Second), nil
+	}
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = strings.TrimSpace(s[1:])
+	}
+	var (
+		ns  uint64
+		err error
+	)
+	if len(s) > 1 && (s[0] == 'P' || s[0] == 'p') {
+		ns, err = parseISO8601(s[1:])
+	} else {
+		ns, err = parseUnits(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	if negative {
+		return Duration(-int64(ns)), nil
+	}
+	if ns > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid duration %q: out of range", value)
+	}
+	return Duration(ns), nil
+}
+
+// errRange is returned when a duration does not fit in a time.Duration.
+var errRange = errors.New("out of range")
+
+// component returns the number of nanoseconds in a decimal number of units,
+// computed exactly as time.ParseDuration does: the whole part in integer
+// arithmetic, and the fraction scaled to the unit; the result is at most
+// 1<<63, the magnitude of the most negative duration.
+func component(number string, unit time.Duration) (uint64, error) {
+	whole, fraction, _ := strings.Cut(number, ".")
+	if whole == "" && fraction == "" || strings.Contains(fraction, ".") {
+		return 0, fmt.Errorf("invalid number %q", number)
+	}
+	var v uint64
+	for _, c := range whole {
+		if v > 1<<63/10 {
+			return 0, errRange
+		}
+		v = v*10 + uint64(c-'0')
+		if v > 1<<63 {
+			return 0, errRange
+		}
+	}
+	if v > 1<<63/uint64(unit) {
+		return 0, errRange
+	}
+	v *= uint64(unit)
+	// digits beyond the precision of the fraction are ignored
+	var f uint64
+	scale := 1.0
+	for _, c := range fraction {
+		if f > (1<<63-1)/10 {
+			continue
+		}
+		f = f*10 + uint64(c-'0')
+		scale *= 10
+	}
+	if f > 0 {
+		v += uint64(float64(f) * (float64(unit) / scale))
+		if v > 1<<63 {
+			return 0, errRange
+		}
+	}
+	return v, nil
+}
+
+// add returns the sum of two magnitudes of durations, checking that it is
+// at most 1<<63.
+func add(total, v uint64) (uint64, error) {
+	if total += v; total > 1<<63 || total < v {
+		return 0, errRange
+	}
+	return total, nil
+}
+
+// parseUnits parses a sequence of numbers with units into a number of
+// nanoseconds.
+func parseUnits(s string) (uint64, error) {
+	var total uint64
+	for s != "" {
+		// skip separators
+		s = strings.TrimLeft(s, " \t,")
+		if rest, ok := strings.CutPrefix(s, "and "); ok {
+			s = strings.TrimLeft(rest, " \t")
+		}
+		if s == "" {
+			break
+		}
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if i == 0 {
+			return 0, fmt.Errorf("expected a number at %q", s)
+		}
+		if i < 0 {
+			return 0, fmt.Errorf("missing unit after %q", s)
+		}
+		number := s[:i]
+		s = strings.TrimLeft(s[i:], " \t")
+		j := strings.IndexFunc(s, func(r rune) bool {
+			return (r >= '0' && r <= '9') || r == '.' || r == ' ' || r == '\t' || r == ','
+		})
+		if j < 0 {
+			j = len(s)
+		}
+		unit, ok := durationUnits[strings.ToLower(s[:j])]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q", s[:j])
+		}
+		n, err := component(number, unit)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = add(total, n); err != nil {
+			return 0, err
+		}
+		s = s[j:]
+	}
+	return total, nil
+}
+
+// parseISO8601 parses the part of an ISO-8601 duration following the "P"
+// into a number of nanoseconds.
+func parseISO8601(s string) (uint64, error) {
+	var total uint64
+	inTime := false
+	empty := true
+	for s != "" {
+		if s[0] == 'T' || s[0] == 't' {
+			if inTime {
+				return 0, errors.New("duplicate time designator")
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.' && r != ','
+		})
+		if i == 0 {
+			return 0, fmt.Errorf("expected a number at %q", s)
+		}
+		if i < 0 {
+			return 0, fmt.Errorf("missing designator after %q", s)
+		}
+		number := strings.Replace(s[:i], ",", ".", 1)
+		var unit time.Duration
+		switch designator := unicode.ToUpper(rune(s[i])); {
+		case designator == 'W' && !inTime:
+			unit = Week
+		case designator == 'D' && !inTime:
+			unit = Day
+		case designator == 'H' && inTime:
+			unit = time.Hour
+		case designator == 'M' && inTime:
+			unit = time.Minute
+		case designator == 'S' && inTime:
+			unit = time.Second
+		case (designator == 'Y' || designator == 'M') && !inTime:
+			return 0, errors.New("years and months have no fixed length")
+		default:
+			return 0, fmt.Errorf("unexpected designator %q", s[i])
+		}
+		n, err := component(number, unit)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = add(total, n); err != nil {
+			return 0, err
+		}
+		empty = false
+		s = s[i+1:]
+	}
+	if empty {
+		return 0, errors.New("no components")
+	}
+	return total, nil
 }
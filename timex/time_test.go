@@ -1,9 +1,15 @@
 package timex
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
 	"slices"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestDuration(t *testing.T) {
@@ -140,3 +146,138 @@ func TestDurationMarshalYAML(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Duration
+		err   bool
+	}{
+		{input: "90s", want: Duration(90 * time.Second)},
+		{input: "1h30m", want: Duration(90 * time.Minute)},
+		{input: "1.5h", want: Duration(90 * time.Minute)},
+		{input: "1d", want: Duration(24 * time.Hour)},
+		{input: "1d12h", want: Duration(36 * time.Hour)},
+		{input: "2w", want: Duration(14 * 24 * time.Hour)},
+		{input: "2 hours", want: Duration(2 * time.Hour)},
+		{input: "1 hour and 30 minutes", want: Duration(90 * time.Minute)},
+		{input: "1 day, 2 hrs, 5 mins", want: Duration(26*time.Hour + 5*time.Minute)},
+		{input: "3 Weeks", want: Duration(21 * 24 * time.Hour)},
+		{input: "500ms", want: Duration(500 * time.Millisecond)},
+		{input: "900", want: Duration(15 * time.Minute)},
+		{input: "0", want: 0},
+		{input: "-5m", want: Duration(-5 * time.Minute)},
+		{input: "PT15M", want: Duration(15 * time.Minute)},
+		{input: "P1DT12H", want: Duration(36 * time.Hour)},
+		{input: "P1W", want: Duration(7 * 24 * time.Hour)},
+		{input: "PT0.5S", want: Duration(500 * time.Millisecond)},
+		{input: "pt1h", want: Duration(time.Hour)},
+		{input: "", err: true},
+		{input: "1 fortnight", err: true},
+		{input: "hour", err: true},
+		{input: "1.5", err: true},
+		{input: "P1M", err: true},
+		{input: "P1Y", err: true},
+		{input: "PT", err: true},
+		{input: "PT15", err: true},
+		{input: "P15H", err: true},
+		{input: "9999999999999999999", err: true},
+		{input: "1000000w", err: true},
+		{input: "1.005s", want: Duration(1005 * time.Millisecond)},
+		{input: "0.071m", want: Duration(4260 * time.Millisecond)},
+		{input: ".5s", want: Duration(500 * time.Millisecond)},
+		{input: "PT0,25S", want: Duration(250 * time.Millisecond)},
+		{input: "2562047h47m16.854775807s", want: Duration(math.MaxInt64)},
+		{input: "9223372036854775807ns", want: Duration(math.MaxInt64)},
+		{input: "-9223372036854775808ns", want: Duration(math.MinInt64)},
+		{input: "PT9223372036.854775807S", want: Duration(math.MaxInt64)},
+		{input: "9223372036854775808ns", err: true},
+		{input: "2562047h47m16.854775808s", err: true},
+		{input: "PT9223372036.854775808S", err: true},
+		{input: "1.2.3s", err: true},
+		{input: ".s", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.input, time.Duration(tt.want), time.Duration(got))
+		}
+	}
+}
+
+func TestParseDurationExact(t *testing.T) {
+	// decimal durations parse to the same value as with time.ParseDuration
+	r := rand.New(rand.NewPCG(1, 2))
+	units := []string{"ns", "us", "ms", "s", "m", "h"}
+	for range 10000 {
+		input := fmt.Sprintf("%d.%0*d%s", r.IntN(1000), 1+r.IntN(9), r.IntN(1000000), units[r.IntN(len(units))])
+		want, err := time.ParseDuration(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseDuration(input); err != nil || time.Duration(got) != want {
+			t.Errorf("%q: expected %v, got %v (%v)", input, want, time.Duration(got), err)
+		}
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+	type wrapper struct {
+		Timeout *Duration `json:"timeout" yaml:"timeout"`
+	}
+	tests := []struct {
+		json      string
+		yaml      string
+		canonical string
+	}{
+		{`{"timeout":"1d"}`, "timeout: 1d", "24h0m0s"},
+		{`{"timeout":"2 hours"}`, "timeout: 2 hours", "2h0m0s"},
+		{`{"timeout":900}`, "timeout: 900", "15m0s"},
+		{`{"timeout":"900"}`, `timeout: "900"`, "15m0s"},
+		{`{"timeout":"PT15M"}`, "timeout: PT15M", "15m0s"},
+		{`{"timeout":"1h30m"}`, "timeout: 1h30m", "1h30m0s"},
+	}
+	for _, tt := range tests {
+		var fromJSON, fromYAML wrapper
+		if err := json.Unmarshal([]byte(tt.json), &fromJSON); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.json, err)
+			continue
+		}
+		if err := yaml.Unmarshal([]byte(tt.yaml), &fromYAML); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.yaml, err)
+			continue
+		}
+		if *fromJSON.Timeout != *fromYAML.Timeout {
+			t.Errorf("%s: JSON and YAML disagree: %v != %v", tt.yaml, fromJSON.Timeout, fromYAML.Timeout)
+		}
+
+		// marshalling is canonical, and reads back the same value
+		data, err := json.Marshal(fromJSON)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := `{"timeout":"` + tt.canonical + `"}`; string(data) != want {
+			t.Errorf("expected %s, got %s", want, data)
+		}
+		var again wrapper
+		if err := json.Unmarshal(data, &again); err != nil || *again.Timeout != *fromJSON.Timeout {
+			t.Errorf("JSON round trip: expected %v, got %v (%v)", fromJSON.Timeout, again.Timeout, err)
+		}
+
+		data, err = yaml.Marshal(fromYAML)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "timeout: " + tt.canonical + "\n"; string(data) != want {
+			t.Errorf("expected %q, got %q", want, data)
+		}
+		again = wrapper{}
+		if err := yaml.Unmarshal(data, &again); err != nil || *again.Timeout != *fromYAML.Timeout {
+			t.Errorf("YAML round trip: expected %v, got %v (%v)", fromYAML.Timeout, again.Timeout, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package timex
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TimeOfDay represents a wall-clock time within a day, as an offset from
+// midnight; it is parsed from and formatted as "15:04" or "15:04:05".
+type TimeOfDay time.Duration
+
+// ParseTimeOfDay parses a time of day in "15:04" or "15:04:05" format.
+func ParseTimeOfDay(value string) (TimeOfDay, error) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time of day %q: must be in HH:MM or HH:MM:SS format", value)
+}
+
+// String returns the string representation of the TimeOfDay value.
+func (t TimeOfDay) String() string {
+	d := time.Duration(t)
+	h, m, s := int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second)
+	if s != 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", h, m)
+}
+
+// On returns the instant at this time of day on the date of the given time,
+// in its location.
+func (t TimeOfDay) On(date time.Time) time.Time {
+	d := time.Duration(t)
+	return time.Date(date.Year(), date.Month(), date.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second), 0, date.Location())
+}
+
+// Of returns the time of day of the given time, in its location.
+func Of(t time.Time) TimeOfDay {
+	return TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second)
+}
+
+// UnmarshalFlag unmarshals a string value into the TimeOfDay variable.
+// This method is used by the go-flags package to handle custom flag types.
+func (t *TimeOfDay) UnmarshalFlag(value string) error {
+	p, err := ParseTimeOfDay(value)
+	if err == nil {
+		*t = p
+	}
+	return err
+}
+
+// MarshalJSON marshals the TimeOfDay value into a JSON string.
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON unmarshals a JSON string into the TimeOfDay variable.
+func (t *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return t.UnmarshalFlag(v)
+}
+
+// MarshalText marshals the TimeOfDay value into a text string.
+func (t TimeOfDay) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText unmarshals a text string into the TimeOfDay variable.
+func (t *TimeOfDay) UnmarshalText(text []byte) error {
+	return t.UnmarshalFlag(string(text))
+}
+
+// MarshalYAML marshals the TimeOfDay value into a YAML string.
+func (t TimeOfDay) MarshalYAML() (any, error) {
+	return t.String(), nil
+}
+
+// UnmarshalYAML unmarshals a YAML string into the TimeOfDay variable.
+func (t *TimeOfDay) UnmarshalYAML(value *yaml.Node) error {
+	var v string
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	return t.UnmarshalFlag(v)
+}
@@ -0,0 +1,85 @@
+package timex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		input string
+		want  TimeOfDay
+		err   bool
+	}{
+		{input: "00:00", want: 0},
+		{input: "08:30", want: TimeOfDay(8*time.Hour + 30*time.Minute)},
+		{input: "23:59:59", want: TimeOfDay(24*time.Hour - time.Second)},
+		{input: "24:00", err: true},
+		{input: "8.30", err: true},
+		{input: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeOfDay(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestTimeOfDayOn(t *testing.T) {
+	tod, _ := ParseTimeOfDay("23:30")
+	date := time.Date(2026, 1, 5, 10, 17, 0, 0, time.UTC)
+	if got, want := tod.On(date), time.Date(2026, 1, 5, 23, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got := Of(date); got.String() != "10:17" {
+		t.Errorf("expected 10:17, got %v", got)
+	}
+}
+
+func TestTimeOfDayRoundTrip(t *testing.T) {
+	type wrapper struct {
+		At TimeOfDay `json:"at" yaml:"at"`
+	}
+	for _, value := range []string{"00:00", "08:30", "17:45:10"} {
+		tod, err := ParseTimeOfDay(value)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if tod.String() != value {
+			t.Errorf("expected %q, got %q", value, tod.String())
+		}
+		in := wrapper{At: tod}
+
+		var fromJSON wrapper
+		data, err := json.Marshal(in)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := json.Unmarshal(data, &fromJSON); err != nil || fromJSON != in {
+			t.Errorf("JSON: expected %v, got %v (%v)", in, fromJSON, err)
+		}
+
+		var fromYAML wrapper
+		data, err = yaml.Marshal(in)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := yaml.Unmarshal(data, &fromYAML); err != nil || fromYAML != in {
+			t.Errorf("YAML: expected %v, got %v (%v)", in, fromYAML, err)
+		}
+
+		var fromText TimeOfDay
+		text, _ := tod.MarshalText()
+		if err := fromText.UnmarshalText(text); err != nil || fromText != tod {
+			t.Errorf("text: expected %v, got %v (%v)", tod, fromText, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package timex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Weekdays represents a set of days of the week; it is parsed from a comma
+// separated list of day names (e.g. "mon,wed,fri") or ranges (e.g. "mon-fri"),
+// or from the "weekdays", "weekends" and "all" shorthands.
+type Weekdays uint8
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// NewWeekdays returns the set of the given days.
+func NewWeekdays(days ...time.Weekday) Weekdays {
+	var w Weekdays
+	for _, day := range days {
+		w |= 1 << day
+	}
+	return w
+}
+
+// ParseWeekdays parses a set of days of the week.
+func ParseWeekdays(value string) (Weekdays, error) {
+	var w Weekdays
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch item {
+		case "":
+			continue
+		case "all", "*", "daily":
+			w |= NewWeekdays(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday)
+			continue
+		case "weekdays":
+			w |= NewWeekdays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+			continue
+		case "weekends", "weekend":
+			w |= NewWeekdays(time.Saturday, time.Sunday)
+			continue
+		}
+		first, last, isRange := strings.Cut(item, "-")
+		from, err := parseWeekday(first)
+		if err != nil {
+			return 0, err
+		}
+		to := from
+		if isRange {
+			if to, err = parseWeekday(last); err != nil {
+				return 0, err
+			}
+		}
+		// ranges may wrap around the end of the week (e.g. "fri-mon")
+		for day := from; ; day = (day + 1) % 7 {
+			w |= 1 << day
+			if day == to {
+				break
+			}
+		}
+	}
+	return w, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for i, n := range weekdayNames {
+		if strings.HasPrefix(name, n) && strings.HasPrefix(strings.ToLower(time.Weekday(i).String()), name) {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day of the week %q", name)
+}
+
+// Contains returns whether the given day is in the set.
+func (w Weekdays) Contains(day time.Weekday) bool {
+	return w&(1<<day) != 0
+}
+
+// IsEmpty returns whether the set contains no days.
+func (w Weekdays) IsEmpty() bool {
+	return w == 0
+}
+
+// String returns the string representation of the Weekdays value, listing
+// the days from Monday to Sunday.
+func (w Weekdays) String() string {
+	var days []string
+	for i := 1; i <= 7; i++ {
+		if day := time.Weekday(i % 7); w.Contains(day) {
+			days = append(days, weekdayNames[day])
+		}
+	}
+	return strings.Join(days, ",")
+}
+
+// UnmarshalFlag unmarshals a string value into the Weekdays variable.
+// This method is used by the go-flags package to handle custom flag types.
+func (w *Weekdays) UnmarshalFlag(value string) error {
+	p, err := ParseWeekdays(value)
+	if err == nil {
+		*w = p
+	}
+	return err
+}
+
+// MarshalJSON marshals the Weekdays value into a JSON string.
+func (w Weekdays) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.String())
+}
+
+// UnmarshalJSON unmarshals a JSON string, or an array of strings, into the
+// Weekdays variable.
+func (w *Weekdays) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		var list []string
+		if err := json.Unmarshal(b, &list); err != nil {
+			return err
+		}
+		return w.UnmarshalFlag(strings.Join(list, ","))
+	}
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	return w.UnmarshalFlag(v)
+}
+
+// MarshalText marshals the Weekdays value into a text string.
+func (w Weekdays) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText unmarshals a text string into the Weekdays variable.
+func (w *Weekdays) UnmarshalText(text []byte) error {
+	return w.UnmarshalFlag(string(text))
+}
+
+// MarshalYAML marshals the Weekdays value into a YAML string.
+func (w Weekdays) MarshalYAML() (any, error) {
+	return w.String(), nil
+}
+
+// UnmarshalYAML unmarshals a YAML string, or a sequence of strings, into the
+// Weekdays variable.
+func (w *Weekdays) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		return w.UnmarshalFlag(strings.Join(list, ","))
+	}
+	var v string
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+	return w.UnmarshalFlag(v)
+}
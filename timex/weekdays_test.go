@@ -0,0 +1,87 @@
+package timex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "mon-fri", want: "mon,tue,wed,thu,fri"},
+		{input: "Monday, Wednesday,fri", want: "mon,wed,fri"},
+		{input: "fri-mon", want: "mon,fri,sat,sun"},
+		{input: "weekdays", want: "mon,tue,wed,thu,fri"},
+		{input: "weekends", want: "sat,sun"},
+		{input: "all", want: "mon,tue,wed,thu,fri,sat,sun"},
+		{input: "", want: ""},
+		{input: "mo", err: true},
+		{input: "mon-fry", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWeekdays(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.want, got.String())
+		}
+	}
+}
+
+func TestWeekdaysContains(t *testing.T) {
+	w := NewWeekdays(time.Saturday, time.Sunday)
+	if !w.Contains(time.Sunday) || !w.Contains(time.Saturday) || w.Contains(time.Monday) {
+		t.Errorf("unexpected set %v", w)
+	}
+	if w.IsEmpty() || !Weekdays(0).IsEmpty() {
+		t.Errorf("unexpected emptiness")
+	}
+}
+
+func TestWeekdaysRoundTrip(t *testing.T) {
+	type wrapper struct {
+		Days Weekdays `json:"days" yaml:"days"`
+	}
+	in := wrapper{Days: NewWeekdays(time.Monday, time.Wednesday, time.Sunday)}
+
+	var fromJSON wrapper
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := json.Unmarshal(data, &fromJSON); err != nil || fromJSON != in {
+		t.Errorf("JSON: expected %v, got %v (%v)", in, fromJSON, err)
+	}
+
+	var fromYAML wrapper
+	data, err = yaml.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil || fromYAML != in {
+		t.Errorf("YAML: expected %v, got %v (%v)", in, fromYAML, err)
+	}
+
+	// lists are accepted too
+	var fromList wrapper
+	if err := json.Unmarshal([]byte(`{"days":["mon","wed","sun"]}`), &fromList); err != nil || fromList != in {
+		t.Errorf("JSON list: expected %v, got %v (%v)", in, fromList, err)
+	}
+	if err := yaml.Unmarshal([]byte("days: [mon, wed, sun]"), &fromList); err != nil || fromList != in {
+		t.Errorf("YAML list: expected %v, got %v (%v)", in, fromList, err)
+	}
+
+	var fromText Weekdays
+	text, _ := in.Days.MarshalText()
+	if err := fromText.UnmarshalText(text); err != nil || fromText != in.Days {
+		t.Errorf("text: expected %v, got %v (%v)", in.Days, fromText, err)
+	}
+}